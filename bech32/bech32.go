@@ -0,0 +1,158 @@
+// Package bech32 encodes byte strings as BIP-173 bech32, as used by Nostr's
+// npub, nsec and ncryptsec strings.
+//
+// Unlike BIP-173, the length of a string is not limited to 90 characters.
+package bech32
+
+import (
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"strings"
+)
+
+// Charset is the alphabet of the data part, in order of value.
+const Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+var generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+// Encode returns the bech32 encoding of data with a lowercase human-readable
+// prefix.
+func Encode(hrp string, data []byte) (string, error) {
+	if err := checkHRP(hrp); err != nil {
+		return "", err
+	}
+	if strings.ToLower(hrp) != hrp {
+		return "", fmt.Errorf("%w: prefix must be lowercase", errors.MalformedBech32)
+	}
+
+	values := convertBits(data, 8, 5, true)
+	values = append(values, checksum(hrp, values)...)
+
+	var b strings.Builder
+	b.Grow(len(hrp) + 1 + len(values))
+	b.WriteString(hrp)
+	b.WriteByte('1')
+	for _, v := range values {
+		b.WriteByte(Charset[v])
+	}
+	return b.String(), nil
+}
+
+// Decode returns the human-readable prefix, in lowercase, and the data of a
+// bech32 string. Strings in mixed case are rejected.
+func Decode(s string) (string, []byte, error) {
+	lower := []byte(s)
+	hasLower, hasUpper := false, false
+	for i, c := range lower {
+		switch {
+		case c >= 'a' && c <= 'z':
+			hasLower = true
+		case c >= 'A' && c <= 'Z':
+			hasUpper = true
+			lower[i] = c + 'a' - 'A'
+		}
+	}
+	if hasLower && hasUpper {
+		return "", nil, fmt.Errorf("%w: mixed case", errors.MalformedBech32)
+	}
+	s = string(lower)
+
+	sep := strings.LastIndexByte(s, '1')
+	if sep < 1 || sep+7 > len(s) {
+		return "", nil, fmt.Errorf("%w: missing separator or checksum", errors.MalformedBech32)
+	}
+	hrp := s[:sep]
+	if err := checkHRP(hrp); err != nil {
+		return "", nil, err
+	}
+
+	values := make([]byte, 0, len(s)-sep-1)
+	for i := sep + 1; i < len(s); i++ {
+		v := strings.IndexByte(Charset, s[i])
+		if v < 0 {
+			return "", nil, fmt.Errorf("%w: invalid character %q", errors.MalformedBech32, s[i])
+		}
+		values = append(values, byte(v))
+	}
+	if polymod(append(expandHRP(hrp), values...)) != 1 {
+		return "", nil, fmt.Errorf("%w: invalid checksum", errors.MalformedBech32)
+	}
+
+	data := convertBits(values[:len(values)-6], 5, 8, false)
+	if data == nil {
+		return "", nil, fmt.Errorf("%w: invalid padding", errors.MalformedBech32)
+	}
+	return hrp, data, nil
+}
+
+func checkHRP(hrp string) error {
+	if hrp == "" {
+		return fmt.Errorf("%w: empty prefix", errors.MalformedBech32)
+	}
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return fmt.Errorf("%w: invalid prefix character %q", errors.MalformedBech32, hrp[i])
+		}
+	}
+	return nil
+}
+
+func polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i, g := range generator {
+			if (top>>i)&1 == 1 {
+				chk ^= g
+			}
+		}
+	}
+	return chk
+}
+
+func expandHRP(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+func checksum(hrp string, values []byte) []byte {
+	mod := polymod(append(append(expandHRP(hrp), values...), 0, 0, 0, 0, 0, 0)) ^ 1
+	sum := make([]byte, 6)
+	for i := range sum {
+		sum[i] = byte(mod>>(5*(5-i))) & 31
+	}
+	return sum
+}
+
+// convertBits regroups values of fromBits bits into values of toBits bits.
+// Without padding, it returns nil if the leftover bits are not zero padding.
+func convertBits(data []byte, fromBits, toBits uint, pad bool) []byte {
+	var acc uint32
+	var bits uint
+	maxValue := uint32(1)<<toBits - 1
+	out := make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+	for _, v := range data {
+		acc = acc<<fromBits | uint32(v)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte(acc>>bits&maxValue))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(toBits-bits)&maxValue))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxValue != 0 {
+		return nil
+	}
+	return out
+}
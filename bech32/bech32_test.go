@@ -0,0 +1,98 @@
+package bech32
+
+import (
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+type DecodeTestCase struct {
+	name    string
+	input   string
+	hrp     string
+	dataHex string
+}
+
+// Valid strings from BIP-173 and NIP-19
+var decodeTestCases = []DecodeTestCase{
+	{"uppercase", "A12UEL5L", "a", ""},
+	{"lowercase", "a12uel5l", "a", ""},
+	{"symbol prefix", "?1ezyfcl", "?", ""},
+	{"every character", "abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw", "abcdef", "00443214c74254b635cf84653a56d7c675be77df"},
+	{"words", "split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w", "split", "c5f38b70305f519bf66d85fb6cf03058f3dde463ecd7918f2dc743918f2d"},
+	{"npub", "npub10elfcs4fr0l0r8af98jlmgdh9c8tcxjvz9qkw038js35mp4dma8qzvjptg", "npub", "7e7e9c42a91bfef19fa929e5fda1b72e0ebc1a4c1141673e2794234d86addf4e"},
+	{"nsec", "nsec1vl029mgpspedva04g90vltkh6fvh240zqtv9k0t9af8935ke9laqsnlfe5", "nsec", "67dea2ed018072d675f5415ecfaed7d2597555e202d85b3d65ea4e58d2d92ffa"},
+}
+
+func TestDecode(t *testing.T) {
+	for _, tc := range decodeTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hrp, data, err := Decode(tc.input)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.hrp, hrp)
+			assert.Equal(t, tc.dataHex, hex.EncodeToString(data))
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	for _, tc := range decodeTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, _ := hex.DecodeString(tc.dataHex)
+			encoded, err := Encode(tc.hrp, data)
+			assert.NoError(t, err)
+			assert.Equal(t, strings.ToLower(tc.input), encoded)
+		})
+	}
+}
+
+func TestLongRoundTrip(t *testing.T) {
+	data := make([]byte, 200)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	encoded, err := Encode("long", data)
+	assert.NoError(t, err)
+	assert.Greater(t, len(encoded), 90)
+
+	hrp, decoded, err := Decode(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, "long", hrp)
+	assert.Equal(t, data, decoded)
+}
+
+type DecodeErrorTestCase struct {
+	name          string
+	input         string
+	expectedError string
+}
+
+// Invalid strings from BIP-173
+var decodeErrorTestCases = []DecodeErrorTestCase{
+	{"no separator", "pzry9x0s0muk", "malformed bech32 string: missing separator or checksum"},
+	{"empty prefix", "1pzry9x0s0muk", "malformed bech32 string: missing separator or checksum"},
+	{"invalid data character", "x1b4n0q5v", "malformed bech32 string: invalid character 'b'"},
+	{"short checksum", "li1dgmt3", "malformed bech32 string: missing separator or checksum"},
+	{"invalid checksum character", "de1lg7wt\xff", "malformed bech32 string: invalid character"},
+	{"checksum of uppercase prefix", "A1G7SGD8", "malformed bech32 string: invalid checksum"},
+	{"prefix control character", "\x201nwldj5", "malformed bech32 string: invalid prefix character"},
+	{"mixed case", "A12uEL5L", "malformed bech32 string: mixed case"},
+	{"nonzero padding", "a1qpamnt9j", "malformed bech32 string: invalid padding"},
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, tc := range decodeErrorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := Decode(tc.input)
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	_, err := Encode("", []byte{1})
+	assert.ErrorContains(t, err, "malformed bech32 string: empty prefix")
+	_, err = Encode("NPUB", []byte{1})
+	assert.ErrorContains(t, err, "malformed bech32 string: prefix must be lowercase")
+}
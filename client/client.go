@@ -0,0 +1,372 @@
+// Package client connects to a relay over WebSocket to publish events and
+// open subscriptions.
+//
+// A client keeps its connection open until it is closed. When the connection
+// drops, it reconnects with exponential backoff, reopens every subscription
+// with Since advanced to the newest event already received, and resends
+// events that are still waiting for an OK.
+package client
+
+import (
+	"context"
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"git.wisehodl.dev/jay/go-roots/messages"
+	"github.com/gorilla/websocket"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Options configures a client. The zero value uses the defaults.
+type Options struct {
+	// MinBackoff is the delay before the first reconnection attempt.
+	// Defaults to 250 milliseconds.
+	MinBackoff time.Duration
+
+	// MaxBackoff caps the delay between reconnection attempts, which doubles
+	// after every failure. Defaults to 30 seconds.
+	MaxBackoff time.Duration
+
+	// EventBuffer is the number of received events queued per subscription.
+	// When a subscription's queue is full, reading from the relay waits for
+	// the consumer. Defaults to 256.
+	EventBuffer int
+
+	// Dialer opens WebSocket connections. Nil uses websocket.DefaultDialer.
+	Dialer *websocket.Dialer
+
+	// OnNotice is called with the text of every NOTICE from the relay.
+	OnNotice func(message string)
+}
+
+// Client is a connection to a single relay.
+type Client struct {
+	url  string
+	opts Options
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// writeMu serializes writes, since a websocket allows a single writer
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	ws      *websocket.Conn
+	subs    map[string]*Subscription
+	pending map[string]*publication
+	nextID  int
+}
+
+// publication is an event waiting for an OK from the relay.
+type publication struct {
+	event   events.Event
+	waiters []chan messages.OK
+}
+
+// Dial connects to the relay at url. The context only bounds the initial
+// connection; reconnections continue until the client is closed.
+func Dial(ctx context.Context, url string, opts Options) (*Client, error) {
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = 250 * time.Millisecond
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 30 * time.Second
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = opts.MinBackoff
+	}
+	if opts.EventBuffer <= 0 {
+		opts.EventBuffer = 256
+	}
+	if opts.Dialer == nil {
+		opts.Dialer = websocket.DefaultDialer
+	}
+
+	ws, _, err := opts.Dialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	clientCtx, cancel := context.WithCancel(context.Background())
+	c := &Client{
+		url:     url,
+		opts:    opts,
+		ctx:     clientCtx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		ws:      ws,
+		subs:    make(map[string]*Subscription),
+		pending: make(map[string]*publication),
+	}
+	go c.run(ws)
+	return c, nil
+}
+
+// URL returns the relay URL the client connects to.
+func (c *Client) URL() string {
+	return c.url
+}
+
+// Close disconnects from the relay and closes every open subscription.
+func (c *Client) Close() error {
+	c.cancel()
+	c.mu.Lock()
+	if c.ws != nil {
+		c.ws.Close()
+	}
+	c.mu.Unlock()
+	<-c.done
+	return nil
+}
+
+// Publish sends an event and waits for the relay's OK. It returns nil if the
+// event was accepted, including as a duplicate, and an error wrapping
+// errors.EventRejected with the relay's reason otherwise. If the connection
+// drops first, the event is sent again after reconnecting.
+func (c *Client) Publish(ctx context.Context, e events.Event) error {
+	ok := make(chan messages.OK, 1)
+
+	c.mu.Lock()
+	if c.ctx.Err() != nil {
+		c.mu.Unlock()
+		return errors.ClientClosed
+	}
+	p, exists := c.pending[e.ID]
+	if !exists {
+		p = &publication{event: e}
+		c.pending[e.ID] = p
+	}
+	p.waiters = append(p.waiters, ok)
+	c.mu.Unlock()
+
+	if !exists {
+		c.write(messages.Event{Event: e})
+	}
+
+	select {
+	case result := <-ok:
+		if !result.Accepted {
+			return fmt.Errorf("%w: %s", errors.EventRejected, result.Message)
+		}
+		return nil
+	case <-ctx.Done():
+		c.abandon(e.ID, ok)
+		return ctx.Err()
+	case <-c.ctx.Done():
+		return errors.ClientClosed
+	}
+}
+
+// abandon stops waiting for an OK, forgetting the event if no one else is
+// waiting for it.
+func (c *Client) abandon(id string, ok chan messages.OK) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, exists := c.pending[id]
+	if !exists {
+		return
+	}
+	for i, waiter := range p.waiters {
+		if waiter == ok {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			break
+		}
+	}
+	if len(p.waiters) == 0 {
+		delete(c.pending, id)
+	}
+}
+
+// Subscribe opens a subscription with the given filters. Stored events are
+// delivered first, followed by a signal on EOSE, then live events.
+func (c *Client) Subscribe(fs ...filters.Filter) (*Subscription, error) {
+	c.mu.Lock()
+	if c.ctx.Err() != nil {
+		c.mu.Unlock()
+		return nil, errors.ClientClosed
+	}
+	c.nextID++
+	sub := newSubscription(c, strconv.Itoa(c.nextID), fs, c.opts.EventBuffer)
+	c.subs[sub.id] = sub
+	c.mu.Unlock()
+
+	c.write(messages.Req{SubscriptionID: sub.id, Filters: sub.Filters()})
+	return sub, nil
+}
+
+// write sends a message on the current connection. Failures are ignored:
+// a broken connection is noticed by the read loop, which reconnects and
+// resends subscriptions and pending events.
+func (c *Client) write(m messages.Message) {
+	data, err := messages.Marshal(m)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	ws := c.ws
+	c.mu.Unlock()
+	if ws == nil {
+		return
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	ws.WriteMessage(websocket.TextMessage, data)
+}
+
+// run reads from the connection, reconnecting whenever it drops, until the
+// client is closed.
+func (c *Client) run(ws *websocket.Conn) {
+	defer close(c.done)
+	defer c.shutdown()
+
+	for {
+		c.read(ws)
+		ws.Close()
+
+		c.mu.Lock()
+		c.ws = nil
+		c.mu.Unlock()
+
+		ws = c.reconnect()
+		if ws == nil {
+			return
+		}
+		c.restore()
+	}
+}
+
+// reconnect dials the relay until it succeeds or the client is closed.
+func (c *Client) reconnect() *websocket.Conn {
+	delay := c.opts.MinBackoff
+	for {
+		select {
+		case <-c.ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+
+		ws, _, err := c.opts.Dialer.DialContext(c.ctx, c.url, nil)
+		if err == nil {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if c.ctx.Err() != nil {
+				ws.Close()
+				return nil
+			}
+			c.ws = ws
+			return ws
+		}
+
+		delay *= 2
+		if delay > c.opts.MaxBackoff {
+			delay = c.opts.MaxBackoff
+		}
+	}
+}
+
+// restore reopens subscriptions and resends pending events on a new
+// connection.
+func (c *Client) restore() {
+	c.mu.Lock()
+	reqs := make([]messages.Message, 0, len(c.subs)+len(c.pending))
+	for _, sub := range c.subs {
+		reqs = append(reqs, messages.Req{SubscriptionID: sub.id, Filters: sub.resumeFilters()})
+	}
+	for _, p := range c.pending {
+		reqs = append(reqs, messages.Event{Event: p.event})
+	}
+	c.mu.Unlock()
+
+	for _, m := range reqs {
+		c.write(m)
+	}
+}
+
+// shutdown closes every subscription once the client is closed.
+func (c *Client) shutdown() {
+	c.mu.Lock()
+	subs := make([]*Subscription, 0, len(c.subs))
+	for _, sub := range c.subs {
+		subs = append(subs, sub)
+	}
+	c.subs = make(map[string]*Subscription)
+	c.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.end(errors.ClientClosed)
+	}
+}
+
+func (c *Client) read(ws *websocket.Conn) {
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		m, err := messages.Unmarshal(data)
+		if err != nil {
+			continue
+		}
+		c.handle(m)
+	}
+}
+
+func (c *Client) handle(m messages.Message) {
+	switch m := m.(type) {
+	case messages.Event:
+		if sub := c.subscription(m.SubscriptionID); sub != nil {
+			sub.deliver(m.Event)
+		}
+	case messages.EOSE:
+		if sub := c.subscription(m.SubscriptionID); sub != nil {
+			sub.markEOSE()
+		}
+	case messages.Closed:
+		c.mu.Lock()
+		sub := c.subs[m.SubscriptionID]
+		delete(c.subs, m.SubscriptionID)
+		c.mu.Unlock()
+		if sub != nil {
+			sub.end(fmt.Errorf("%w: %s", errors.SubscriptionClosed, m.Message))
+		}
+	case messages.OK:
+		c.mu.Lock()
+		p := c.pending[m.EventID]
+		delete(c.pending, m.EventID)
+		c.mu.Unlock()
+		if p != nil {
+			for _, waiter := range p.waiters {
+				waiter <- m
+			}
+		}
+	case messages.Notice:
+		if c.opts.OnNotice != nil {
+			c.opts.OnNotice(m.Message)
+		}
+	}
+}
+
+func (c *Client) subscription(id string) *Subscription {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.subs[id]
+}
+
+// unsubscribe forgets a subscription and asks the relay to close it.
+func (c *Client) unsubscribe(id string) {
+	c.mu.Lock()
+	_, open := c.subs[id]
+	delete(c.subs, id)
+	c.mu.Unlock()
+
+	if open {
+		c.write(messages.Close{SubscriptionID: id})
+	}
+}
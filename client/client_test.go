@@ -0,0 +1,343 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"git.wisehodl.dev/jay/go-roots/messages"
+	"git.wisehodl.dev/jay/go-roots/relay"
+	"git.wisehodl.dev/jay/go-roots/store/memory"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testSK = "f43a0435f69529f310bbd1d6263d2fbf0977f54bfe2310cc37ae5904b83bb167"
+const testPK = "cfa87f35acbde29ba1ab3ee42de527b2cad33ac487e80cf2d6405ea0042c8fef"
+
+func signedEvent(t *testing.T, kind, createdAt int, content string) events.Event {
+	e := events.Event{
+		PubKey:    testPK,
+		CreatedAt: createdAt,
+		Kind:      kind,
+		Tags:      []events.Tag{},
+		Content:   content,
+	}
+	var err error
+	if e.ID, err = events.GetID(e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Sig, err = events.SignEvent(e.ID, testSK); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// trackingListener records accepted connections so that tests can drop them
+// while the relay keeps running.
+type trackingListener struct {
+	net.Listener
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func (l *trackingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.mu.Lock()
+		l.conns = append(l.conns, conn)
+		l.mu.Unlock()
+	}
+	return conn, err
+}
+
+func (l *trackingListener) dropAll() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, conn := range l.conns {
+		conn.Close()
+	}
+	l.conns = nil
+}
+
+type testRelay struct {
+	url      string
+	listener *trackingListener
+}
+
+func startRelay(t *testing.T, opts relay.Options) *testRelay {
+	r := relay.New(memory.New(), opts)
+	server := httptest.NewUnstartedServer(r)
+	listener := &trackingListener{Listener: server.Listener}
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(func() {
+		r.Close()
+		server.Close()
+	})
+	return &testRelay{
+		url:      "ws" + strings.TrimPrefix(server.URL, "http"),
+		listener: listener,
+	}
+}
+
+// startScriptedRelay serves a relay that passes every message it receives
+// to respond, for simulating relays that misbehave.
+func startScriptedRelay(t *testing.T, respond func(ws *websocket.Conn, m messages.Message)) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		for {
+			_, data, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			if m, err := messages.Unmarshal(data); err == nil {
+				respond(ws, m)
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func dial(t *testing.T, url string) *Client {
+	c, err := Dial(context.Background(), url, Options{MinBackoff: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func publish(t *testing.T, c *Client, e events.Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := c.Publish(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func receive(t *testing.T, sub *Subscription) events.Event {
+	select {
+	case e, ok := <-sub.Events:
+		if !ok {
+			t.Fatalf("subscription ended: %v", sub.Err())
+		}
+		return e
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for event")
+		return events.Event{}
+	}
+}
+
+func waitEOSE(t *testing.T, sub *Subscription) {
+	select {
+	case <-sub.EOSE:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for EOSE")
+	}
+}
+
+func expectNoEvent(t *testing.T, sub *Subscription) {
+	select {
+	case e := <-sub.Events:
+		t.Fatalf("unexpected event %q", e.Content)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func expectEnded(t *testing.T, sub *Subscription) {
+	select {
+	case _, ok := <-sub.Events:
+		assert.False(t, ok, "expected subscription to end")
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscription did not end")
+	}
+}
+
+func TestDialError(t *testing.T) {
+	_, err := Dial(context.Background(), "ws://127.0.0.1:1", Options{})
+	assert.Error(t, err)
+}
+
+func TestPublish(t *testing.T) {
+	r := startRelay(t, relay.Options{})
+	c := dial(t, r.url)
+	ctx := context.Background()
+	e := signedEvent(t, 1, 1000, "hello")
+
+	assert.NoError(t, c.Publish(ctx, e))
+
+	// Duplicates are accepted
+	assert.NoError(t, c.Publish(ctx, e))
+
+	tampered := signedEvent(t, 1, 1000, "hello again")
+	tampered.Content = "tampered"
+	err := c.Publish(ctx, tampered)
+	assert.ErrorContains(t, err, "event was rejected: invalid:")
+}
+
+func TestPublishTimeout(t *testing.T) {
+	url := startScriptedRelay(t, func(*websocket.Conn, messages.Message) {})
+	c := dial(t, url)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := c.Publish(ctx, signedEvent(t, 1, 1000, "unanswered"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Empty(t, c.pending)
+}
+
+func TestPublishAfterClose(t *testing.T) {
+	r := startRelay(t, relay.Options{})
+	c := dial(t, r.url)
+	assert.NoError(t, c.Close())
+
+	err := c.Publish(context.Background(), signedEvent(t, 1, 1000, "late"))
+	assert.ErrorContains(t, err, "client is closed")
+}
+
+func TestSubscribe(t *testing.T) {
+	r := startRelay(t, relay.Options{})
+	c := dial(t, r.url)
+	notes := []events.Event{}
+	for i := 0; i < 3; i++ {
+		notes = append(notes, signedEvent(t, 1, 1000+i, fmt.Sprintf("note %d", i)))
+		publish(t, c, notes[i])
+	}
+
+	sub, err := c.Subscribe(filters.Filter{Kinds: []int{1}})
+	assert.NoError(t, err)
+
+	// Stored events arrive before EOSE, newest first
+	assert.Equal(t, notes[2], receive(t, sub))
+	assert.Equal(t, notes[1], receive(t, sub))
+	assert.Equal(t, notes[0], receive(t, sub))
+	waitEOSE(t, sub)
+
+	live := signedEvent(t, 1, 2000, "live")
+	publish(t, dial(t, r.url), live)
+	assert.Equal(t, live, receive(t, sub))
+
+	sub.Close()
+	expectEnded(t, sub)
+	assert.NoError(t, sub.Err())
+}
+
+func TestSubscriptionClosedByRelay(t *testing.T) {
+	r := startRelay(t, relay.Options{MaxSubscriptions: 1})
+	c := dial(t, r.url)
+
+	first, err := c.Subscribe(filters.Filter{})
+	assert.NoError(t, err)
+	waitEOSE(t, first)
+
+	second, err := c.Subscribe(filters.Filter{})
+	assert.NoError(t, err)
+	expectEnded(t, second)
+	assert.ErrorContains(t, second.Err(), "subscription was closed by relay: blocked:")
+}
+
+func TestClose(t *testing.T) {
+	r := startRelay(t, relay.Options{})
+	c := dial(t, r.url)
+	sub, err := c.Subscribe(filters.Filter{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.Close())
+	expectEnded(t, sub)
+	assert.ErrorContains(t, sub.Err(), "client is closed")
+
+	_, err = c.Subscribe(filters.Filter{})
+	assert.ErrorContains(t, err, "client is closed")
+}
+
+func TestReconnect(t *testing.T) {
+	r := startRelay(t, relay.Options{})
+	c := dial(t, r.url)
+	publisher := dial(t, r.url)
+	publish(t, publisher, signedEvent(t, 1, 1000, "before"))
+
+	sub, err := c.Subscribe(filters.Filter{Kinds: []int{1}})
+	assert.NoError(t, err)
+	receive(t, sub)
+	waitEOSE(t, sub)
+
+	r.listener.dropAll()
+
+	// Events seen before the connection dropped are not delivered again
+	publisher = dial(t, r.url)
+	after := signedEvent(t, 1, 2000, "after")
+	publish(t, publisher, after)
+
+	assert.Equal(t, after, receive(t, sub))
+	expectNoEvent(t, sub)
+
+	live := signedEvent(t, 1, 3000, "live")
+	publish(t, publisher, live)
+	assert.Equal(t, live, receive(t, sub))
+}
+
+func TestResumeFilters(t *testing.T) {
+	r := startRelay(t, relay.Options{})
+	c := dial(t, r.url)
+	sub, err := c.Subscribe(
+		filters.Filter{Kinds: []int{1}},
+		filters.Filter{Kinds: []int{1}, Since: intPtr(5000)},
+	)
+	assert.NoError(t, err)
+	waitEOSE(t, sub)
+	assert.Equal(t, sub.Filters(), sub.resumeFilters())
+
+	publish(t, c, signedEvent(t, 1, 1000, "note"))
+	receive(t, sub)
+
+	resumed := sub.resumeFilters()
+	assert.Equal(t, 1000, *resumed[0].Since)
+	assert.Equal(t, 5000, *resumed[1].Since)
+	assert.Nil(t, sub.Filters()[0].Since)
+}
+
+func TestResumeSkipsRepeatedEvents(t *testing.T) {
+	r := startRelay(t, relay.Options{})
+	c := dial(t, r.url)
+	sub, err := c.Subscribe(filters.Filter{Kinds: []int{1}})
+	assert.NoError(t, err)
+	waitEOSE(t, sub)
+
+	// Both events share the timestamp the subscription resumes from
+	first := signedEvent(t, 1, 1000, "first")
+	publish(t, c, first)
+	assert.Equal(t, first, receive(t, sub))
+
+	r.listener.dropAll()
+	second := signedEvent(t, 1, 1000, "second")
+	publish(t, dial(t, r.url), second)
+
+	assert.Equal(t, second, receive(t, sub))
+	expectNoEvent(t, sub)
+}
+
+func TestPublishAcrossReconnect(t *testing.T) {
+	r := startRelay(t, relay.Options{})
+	c := dial(t, r.url)
+	sub, err := c.Subscribe(filters.Filter{Kinds: []int{1}})
+	assert.NoError(t, err)
+	waitEOSE(t, sub)
+
+	r.listener.dropAll()
+	e := signedEvent(t, 1, 1000, "resent")
+	publish(t, c, e)
+	assert.Equal(t, e, receive(t, sub))
+}
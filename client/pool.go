@@ -0,0 +1,383 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"sort"
+	"sync"
+)
+
+// PoolOptions configures a pool.
+type PoolOptions struct {
+	// Client configures the connection to each relay.
+	Client Options
+
+	// Quorum is the number of relays that must finish sending stored events
+	// before a pool subscription signals EOSE. Zero waits for every relay.
+	Quorum int
+
+	// MaxSeen limits the events the pool remembers for validation and relay
+	// hints. The oldest are forgotten first. Zero remembers 65536.
+	MaxSeen int
+}
+
+// Pool fans subscriptions out to several relays and merges their events.
+//
+// Each event is validated when a relay first delivers it, and delivered to
+// a subscription once. Later copies are validated again unless they are
+// identical to the first. The pool remembers which relays delivered each
+// event, for use as relay hints.
+type Pool struct {
+	opts PoolOptions
+
+	mu      sync.Mutex
+	urls    []string
+	clients map[string]*Client
+	seen    map[string]*sighting
+	order   []string
+	closed  bool
+}
+
+// sighting records a validated event and the relays that delivered it.
+type sighting struct {
+	raw    []byte
+	relays map[string]struct{}
+}
+
+// NewPool returns an empty pool.
+func NewPool(opts PoolOptions) *Pool {
+	if opts.Client.EventBuffer <= 0 {
+		opts.Client.EventBuffer = 256
+	}
+	if opts.MaxSeen <= 0 {
+		opts.MaxSeen = 65536
+	}
+	return &Pool{
+		opts:    opts,
+		clients: make(map[string]*Client),
+		seen:    make(map[string]*sighting),
+	}
+}
+
+// Add connects to a relay. Relays already in the pool are ignored.
+// Subscriptions opened before the relay was added do not include it.
+func (p *Pool) Add(ctx context.Context, url string) error {
+	p.mu.Lock()
+	_, exists := p.clients[url]
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		return errors.ClientClosed
+	}
+	if exists {
+		return nil
+	}
+
+	c, err := Dial(ctx, url, p.opts.Client)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, exists := p.clients[url]; exists || p.closed {
+		c.Close()
+		if p.closed {
+			return errors.ClientClosed
+		}
+		return nil
+	}
+	p.clients[url] = c
+	p.urls = append(p.urls, url)
+	return nil
+}
+
+// URLs returns the relays in the pool, in the order they were added.
+func (p *Pool) URLs() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string{}, p.urls...)
+}
+
+// Relays returns the sorted URLs of the relays that delivered an event.
+func (p *Pool) Relays(id string) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.seen[id]
+	if !ok {
+		return []string{}
+	}
+	urls := make([]string, 0, len(s.relays))
+	for url := range s.relays {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// Close disconnects from every relay, ending all subscriptions.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	clients := make([]*Client, 0, len(p.clients))
+	for _, c := range p.clients {
+		clients = append(clients, c)
+	}
+	p.mu.Unlock()
+
+	for _, c := range clients {
+		c.Close()
+	}
+	return nil
+}
+
+// Publish sends an event to every relay in the pool and waits for their
+// OKs. It returns nil if any relay accepted the event, and otherwise the
+// error of the last relay to answer. A pool without relays returns
+// errors.NoRelays.
+func (p *Pool) Publish(ctx context.Context, e events.Event) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return errors.ClientClosed
+	}
+	clients := make([]*Client, 0, len(p.urls))
+	for _, url := range p.urls {
+		clients = append(clients, p.clients[url])
+	}
+	p.mu.Unlock()
+	if len(clients) == 0 {
+		return errors.NoRelays
+	}
+
+	results := make(chan error, len(clients))
+	for _, c := range clients {
+		go func(c *Client) {
+			results <- c.Publish(ctx, e)
+		}(c)
+	}
+
+	var err error
+	for range clients {
+		if err = <-results; err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// accept reports whether an event delivered by a relay is valid, recording
+// the relay. Copies identical to an event already validated are not
+// validated again.
+func (p *Pool) accept(url string, e events.Event) bool {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return false
+	}
+
+	p.mu.Lock()
+	s, ok := p.seen[e.ID]
+	p.mu.Unlock()
+
+	if !ok || !bytes.Equal(s.raw, raw) {
+		if events.Validate(e) != nil {
+			return false
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok = p.seen[e.ID]
+	if !ok {
+		s = &sighting{raw: raw, relays: make(map[string]struct{})}
+		p.seen[e.ID] = s
+		p.order = append(p.order, e.ID)
+		for len(p.order) > p.opts.MaxSeen {
+			delete(p.seen, p.order[0])
+			p.order = p.order[1:]
+		}
+	}
+	s.relays[url] = struct{}{}
+	return true
+}
+
+// Subscribe opens a subscription on every relay in the pool. It fails only
+// if no relay accepted the subscription, with errors.NoRelays if the pool
+// has none.
+func (p *Pool) Subscribe(fs ...filters.Filter) (*PoolSubscription, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, errors.ClientClosed
+	}
+	urls := append([]string{}, p.urls...)
+	clients := make([]*Client, len(urls))
+	for i, url := range urls {
+		clients[i] = p.clients[url]
+	}
+	p.mu.Unlock()
+	if len(clients) == 0 {
+		return nil, errors.NoRelays
+	}
+
+	subs := make(map[string]*Subscription)
+	var err error
+	for i, c := range clients {
+		sub, subErr := c.Subscribe(fs...)
+		if subErr != nil {
+			err = subErr
+			continue
+		}
+		subs[urls[i]] = sub
+	}
+	if len(subs) == 0 {
+		return nil, err
+	}
+
+	quorum := p.opts.Quorum
+	if quorum <= 0 || quorum > len(subs) {
+		quorum = len(subs)
+	}
+	return newPoolSubscription(p, subs, quorum), nil
+}
+
+// PoolSubscription is a subscription open on several relays.
+type PoolSubscription struct {
+	// Events delivers each matching event once, from whichever relay sent it
+	// first. It is closed when every relay's subscription has ended.
+	Events <-chan events.Event
+
+	// EOSE is closed when a quorum of relays has sent every stored event.
+	// A relay whose subscription ends counts as finished.
+	EOSE <-chan struct{}
+
+	pool   *Pool
+	subs   map[string]*Subscription
+	events chan events.Event
+	eose   chan struct{}
+	done   chan struct{}
+	quorum int
+
+	closeOnce sync.Once
+
+	mu        sync.Mutex
+	finished  int
+	delivered map[string]struct{}
+}
+
+func newPoolSubscription(p *Pool, subs map[string]*Subscription, quorum int) *PoolSubscription {
+	ps := &PoolSubscription{
+		pool:      p,
+		subs:      subs,
+		events:    make(chan events.Event, p.opts.Client.EventBuffer),
+		eose:      make(chan struct{}),
+		done:      make(chan struct{}),
+		quorum:    quorum,
+		delivered: make(map[string]struct{}),
+	}
+	ps.Events = ps.events
+	ps.EOSE = ps.eose
+
+	var wg sync.WaitGroup
+	for url, sub := range subs {
+		wg.Add(1)
+		go func(url string, sub *Subscription) {
+			defer wg.Done()
+			ps.forward(url, sub)
+		}(url, sub)
+	}
+	go func() {
+		wg.Wait()
+		close(ps.events)
+	}()
+	return ps
+}
+
+// URLs returns the relays the subscription is open on.
+func (ps *PoolSubscription) URLs() []string {
+	urls := make([]string, 0, len(ps.subs))
+	for url := range ps.subs {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// Close ends the subscription on every relay.
+func (ps *PoolSubscription) Close() {
+	ps.closeOnce.Do(func() { close(ps.done) })
+	for _, sub := range ps.subs {
+		sub.Close()
+	}
+}
+
+// forward relays one relay's events into the merged channel until its
+// subscription ends.
+func (ps *PoolSubscription) forward(url string, sub *Subscription) {
+	eose := sub.EOSE
+	for {
+		select {
+		case <-eose:
+			// Stored events are queued before EOSE is signaled
+			for drained := false; !drained; {
+				select {
+				case e, ok := <-sub.Events:
+					if !ok {
+						ps.finish()
+						return
+					}
+					ps.deliver(url, e)
+				default:
+					drained = true
+				}
+			}
+			eose = nil
+			ps.finish()
+		case e, ok := <-sub.Events:
+			if !ok {
+				if eose != nil {
+					ps.finish()
+				}
+				return
+			}
+			ps.deliver(url, e)
+		}
+	}
+}
+
+func (ps *PoolSubscription) deliver(url string, e events.Event) {
+	if !ps.pool.accept(url, e) || !ps.first(e.ID) {
+		return
+	}
+	select {
+	case ps.events <- e:
+	case <-ps.done:
+	}
+}
+
+// first reports whether an event has not yet been delivered, marking it
+// delivered.
+func (ps *PoolSubscription) first(id string) bool {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if _, ok := ps.delivered[id]; ok {
+		return false
+	}
+	ps.delivered[id] = struct{}{}
+	return true
+}
+
+// finish counts a relay as finished, signaling EOSE at the quorum.
+func (ps *PoolSubscription) finish() {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.finished++
+	if ps.finished == ps.quorum {
+		close(ps.eose)
+	}
+}
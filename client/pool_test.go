@@ -0,0 +1,260 @@
+package client
+
+import (
+	"context"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"git.wisehodl.dev/jay/go-roots/messages"
+	"git.wisehodl.dev/jay/go-roots/relay"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"sort"
+	"testing"
+	"time"
+)
+
+func newTestPool(t *testing.T, opts PoolOptions, urls ...string) *Pool {
+	opts.Client.MinBackoff = 10 * time.Millisecond
+	p := NewPool(opts)
+	t.Cleanup(func() { p.Close() })
+	for _, url := range urls {
+		if err := p.Add(context.Background(), url); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return p
+}
+
+// collect receives events until the subscription signals EOSE.
+func collect(t *testing.T, sub *PoolSubscription) []events.Event {
+	received := []events.Event{}
+	for {
+		select {
+		case e := <-sub.Events:
+			received = append(received, e)
+		case <-sub.EOSE:
+			return received
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for EOSE")
+		}
+	}
+}
+
+func contents(evs []events.Event) []string {
+	cs := []string{}
+	for _, e := range evs {
+		cs = append(cs, e.Content)
+	}
+	sort.Strings(cs)
+	return cs
+}
+
+// sendStored answers every REQ with the given events followed by EOSE.
+func sendStored(evs ...events.Event) func(*websocket.Conn, messages.Message) {
+	return func(ws *websocket.Conn, m messages.Message) {
+		req, ok := m.(messages.Req)
+		if !ok {
+			return
+		}
+		for _, e := range evs {
+			data, _ := messages.Marshal(messages.Event{SubscriptionID: req.SubscriptionID, Event: e})
+			ws.WriteMessage(websocket.TextMessage, data)
+		}
+		data, _ := messages.Marshal(messages.EOSE{SubscriptionID: req.SubscriptionID})
+		ws.WriteMessage(websocket.TextMessage, data)
+	}
+}
+
+func TestPoolDeduplicates(t *testing.T) {
+	relays := []*testRelay{
+		startRelay(t, relay.Options{}),
+		startRelay(t, relay.Options{}),
+		startRelay(t, relay.Options{}),
+	}
+	shared := signedEvent(t, 1, 1000, "shared")
+	partial := signedEvent(t, 1, 1001, "partial")
+	single := signedEvent(t, 1, 1002, "single")
+	for i, r := range relays {
+		c := dial(t, r.url)
+		publish(t, c, shared)
+		if i < 2 {
+			publish(t, c, partial)
+		}
+		if i == 2 {
+			publish(t, c, single)
+		}
+	}
+
+	p := newTestPool(t, PoolOptions{}, relays[0].url, relays[1].url, relays[2].url)
+	sub, err := p.Subscribe(filters.Filter{Kinds: []int{1}})
+	assert.NoError(t, err)
+
+	received := collect(t, sub)
+	assert.Equal(t, []string{"partial", "shared", "single"}, contents(received))
+
+	all := []string{relays[0].url, relays[1].url, relays[2].url}
+	sort.Strings(all)
+	assert.Equal(t, all, p.Relays(shared.ID))
+	assert.Equal(t, []string{relays[2].url}, p.Relays(single.ID))
+	assert.Len(t, p.Relays(partial.ID), 2)
+	assert.Empty(t, p.Relays("unknown"))
+
+	// Live events are deduplicated too
+	live := signedEvent(t, 1, 2000, "live")
+	for _, r := range relays {
+		publish(t, dial(t, r.url), live)
+	}
+	assert.Equal(t, live, <-sub.Events)
+	select {
+	case e := <-sub.Events:
+		t.Fatalf("unexpected event %q", e.Content)
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Len(t, p.Relays(live.ID), 3)
+}
+
+func TestPoolDropsInvalidEvents(t *testing.T) {
+	valid := signedEvent(t, 1, 1000, "valid")
+	forged := valid
+	forged.Sig = signedEvent(t, 1, 1000, "other").Sig
+
+	liar := startScriptedRelay(t, sendStored(forged))
+	honest := startScriptedRelay(t, sendStored(valid))
+	p := newTestPool(t, PoolOptions{}, liar, honest)
+
+	sub, err := p.Subscribe(filters.Filter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []events.Event{valid}, collect(t, sub))
+	assert.Equal(t, []string{honest}, p.Relays(valid.ID))
+}
+
+func TestPoolValidatesChangedCopies(t *testing.T) {
+	valid := signedEvent(t, 1, 1000, "valid")
+	p := NewPool(PoolOptions{})
+	assert.True(t, p.accept("wss://a", valid))
+
+	// A copy reusing the ID and signature with other content is forged
+	forged := valid
+	forged.Content = "forged"
+	assert.False(t, p.accept("wss://b", forged))
+	forged = valid
+	forged.Tags = []events.Tag{{"t", "forged"}}
+	assert.False(t, p.accept("wss://b", forged))
+
+	assert.True(t, p.accept("wss://c", valid))
+	assert.Equal(t, []string{"wss://a", "wss://c"}, p.Relays(valid.ID))
+}
+
+func TestPoolMaxSeen(t *testing.T) {
+	p := NewPool(PoolOptions{MaxSeen: 2})
+	evs := []events.Event{
+		signedEvent(t, 1, 1000, "a"),
+		signedEvent(t, 1, 1000, "b"),
+		signedEvent(t, 1, 1000, "c"),
+	}
+	for _, e := range evs {
+		assert.True(t, p.accept("wss://a", e))
+	}
+	assert.Equal(t, []string{}, p.Relays(evs[0].ID))
+	assert.Equal(t, []string{"wss://a"}, p.Relays(evs[2].ID))
+	assert.Len(t, p.seen, 2)
+}
+
+func TestPoolQuorum(t *testing.T) {
+	note := signedEvent(t, 1, 1000, "note")
+	fast := startScriptedRelay(t, sendStored(note))
+	slow := startScriptedRelay(t, func(*websocket.Conn, messages.Message) {})
+
+	p := newTestPool(t, PoolOptions{}, fast, slow)
+	sub, err := p.Subscribe(filters.Filter{})
+	assert.NoError(t, err)
+	assert.Equal(t, note, <-sub.Events)
+	select {
+	case <-sub.EOSE:
+		t.Fatal("EOSE before every relay finished")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	p = newTestPool(t, PoolOptions{Quorum: 1}, fast, slow)
+	sub, err = p.Subscribe(filters.Filter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []events.Event{note}, collect(t, sub))
+}
+
+func TestPoolClosedSubscriptionFinishes(t *testing.T) {
+	open := startRelay(t, relay.Options{})
+	full := startRelay(t, relay.Options{MaxSubscriptions: 1})
+	p := newTestPool(t, PoolOptions{}, open.url, full.url)
+
+	first, err := p.Subscribe(filters.Filter{})
+	assert.NoError(t, err)
+	collect(t, first)
+
+	// The second relay refuses the subscription, which still finishes
+	second, err := p.Subscribe(filters.Filter{})
+	assert.NoError(t, err)
+	collect(t, second)
+}
+
+func TestPoolClose(t *testing.T) {
+	r := startRelay(t, relay.Options{})
+	p := newTestPool(t, PoolOptions{}, r.url, r.url)
+	assert.Equal(t, []string{r.url}, p.URLs())
+
+	sub, err := p.Subscribe(filters.Filter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{r.url}, sub.URLs())
+
+	assert.NoError(t, p.Close())
+	select {
+	case _, ok := <-sub.Events:
+		assert.False(t, ok)
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscription did not end")
+	}
+
+	_, err = p.Subscribe(filters.Filter{})
+	assert.ErrorContains(t, err, "client is closed")
+	assert.ErrorContains(t, p.Add(context.Background(), r.url), "client is closed")
+}
+
+func TestPoolEmpty(t *testing.T) {
+	p := newTestPool(t, PoolOptions{})
+	_, err := p.Subscribe(filters.Filter{})
+	assert.ErrorContains(t, err, "pool has no relays")
+}
+
+// rejectEvents answers every EVENT with a rejecting OK.
+func rejectEvents(ws *websocket.Conn, m messages.Message) {
+	e, ok := m.(messages.Event)
+	if !ok {
+		return
+	}
+	data, _ := messages.Marshal(messages.OK{EventID: e.Event.ID, Message: "blocked: not allowed"})
+	ws.WriteMessage(websocket.TextMessage, data)
+}
+
+func TestPoolPublish(t *testing.T) {
+	r := startRelay(t, relay.Options{})
+	rejecting := startScriptedRelay(t, rejectEvents)
+	e := signedEvent(t, 1, 1000, "published")
+
+	// One relay accepting is enough
+	p := newTestPool(t, PoolOptions{}, r.url, rejecting)
+	assert.NoError(t, p.Publish(context.Background(), e))
+
+	check := newTestPool(t, PoolOptions{}, r.url)
+	sub, err := check.Subscribe(filters.Filter{IDs: []string{e.ID}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"published"}, contents(collect(t, sub)))
+
+	rejected := newTestPool(t, PoolOptions{}, rejecting)
+	err = rejected.Publish(context.Background(), e)
+	assert.ErrorContains(t, err, "event was rejected: blocked: not allowed")
+
+	empty := newTestPool(t, PoolOptions{})
+	assert.ErrorContains(t, empty.Publish(context.Background(), e), "pool has no relays")
+
+	assert.NoError(t, p.Close())
+	assert.ErrorContains(t, p.Publish(context.Background(), e), "client is closed")
+}
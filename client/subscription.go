@@ -0,0 +1,164 @@
+package client
+
+import (
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"sync"
+)
+
+// Subscription is an open subscription on a relay.
+type Subscription struct {
+	// Events delivers matching events. It is closed when the subscription
+	// ends; Err then reports why.
+	Events <-chan events.Event
+
+	// EOSE is closed when the relay has sent every stored event.
+	EOSE <-chan struct{}
+
+	id     string
+	client *Client
+	events chan events.Event
+	eose   chan struct{}
+
+	// done is closed when the subscription ends, releasing a blocked send.
+	// sendMu is held while sending, so that events is never closed mid-send.
+	done     chan struct{}
+	doneOnce sync.Once
+	sendMu   sync.Mutex
+
+	mu      sync.Mutex
+	filters []filters.Filter
+	gotEOSE bool
+	ended   bool
+	err     error
+
+	// latest is the newest CreatedAt delivered, and seen holds the IDs
+	// delivered with that timestamp. A resumed subscription repeats the
+	// events in seen, so they are kept in resumed until it ends.
+	latest  int
+	seen    map[string]struct{}
+	resumed map[string]struct{}
+}
+
+func newSubscription(c *Client, id string, fs []filters.Filter, buffer int) *Subscription {
+	sub := &Subscription{
+		id:      id,
+		client:  c,
+		events:  make(chan events.Event, buffer),
+		eose:    make(chan struct{}),
+		done:    make(chan struct{}),
+		filters: fs,
+		seen:    make(map[string]struct{}),
+		resumed: make(map[string]struct{}),
+	}
+	sub.Events = sub.events
+	sub.EOSE = sub.eose
+	return sub
+}
+
+// ID returns the subscription ID sent to the relay.
+func (s *Subscription) ID() string {
+	return s.id
+}
+
+// Filters returns the filters the subscription was opened with.
+func (s *Subscription) Filters() []filters.Filter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]filters.Filter{}, s.filters...)
+}
+
+// Err returns why the subscription ended: nil after Close, an error wrapping
+// errors.SubscriptionClosed if the relay closed it, or errors.ClientClosed.
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close ends the subscription and asks the relay to stop sending events.
+func (s *Subscription) Close() {
+	s.client.unsubscribe(s.id)
+	s.end(nil)
+}
+
+// resumeFilters returns the filters with Since advanced to the newest event
+// already delivered.
+func (s *Subscription) resumeFilters() []filters.Filter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id := range s.seen {
+		s.resumed[id] = struct{}{}
+	}
+
+	fs := make([]filters.Filter, len(s.filters))
+	for i, f := range s.filters {
+		if s.latest > 0 && (f.Since == nil || *f.Since < s.latest) {
+			since := s.latest
+			f.Since = &since
+		}
+		fs[i] = f
+	}
+	return fs
+}
+
+func (s *Subscription) deliver(e events.Event) {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+
+	// Skip events repeated by a resumed subscription
+	_, repeated := s.resumed[e.ID]
+	if _, seen := s.seen[e.ID]; repeated || seen {
+		s.mu.Unlock()
+		return
+	}
+	switch {
+	case e.CreatedAt > s.latest:
+		s.latest = e.CreatedAt
+		s.seen = map[string]struct{}{e.ID: {}}
+	case e.CreatedAt == s.latest:
+		s.seen[e.ID] = struct{}{}
+	}
+	s.mu.Unlock()
+
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+	select {
+	case s.events <- e:
+	case <-s.done:
+	}
+}
+
+func (s *Subscription) markEOSE() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.gotEOSE && !s.ended {
+		s.gotEOSE = true
+		close(s.eose)
+	}
+}
+
+// end closes the subscription's channels, recording the reason.
+func (s *Subscription) end(err error) {
+	s.doneOnce.Do(func() { close(s.done) })
+
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	s.ended = true
+	s.err = err
+	close(s.events)
+}
@@ -0,0 +1,5 @@
+package client
+
+func intPtr(i int) *int {
+	return &i
+}
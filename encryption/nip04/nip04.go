@@ -0,0 +1,111 @@
+// Package nip04 implements the legacy NIP-04 encrypted direct message
+// format, for reading and answering kind 4 messages.
+//
+// Content is encrypted with AES-256-CBC under the x-coordinate of the ECDH
+// shared point, and encoded as base64 ciphertext followed by "?iv=" and the
+// base64 initialization vector.
+//
+// Deprecated: NIP-04 is unauthenticated and leaks message length and
+// metadata. Use encryption/nip44 for new messages.
+package nip04
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/keys"
+	"strings"
+)
+
+// Kind is the event kind of a NIP-04 direct message.
+const Kind = 4
+
+// SharedSecret derives the secret shared by the owner of the private key and
+// the owner of the public key. Both are hex encoded; the public key is the
+// 32-byte x-coordinate used by Nostr. Unlike NIP-44, the secret is the
+// unhashed x-coordinate of the shared point, as given by keys.SharedSecret.
+func SharedSecret(privateKeyHex, publicKeyHex string) ([]byte, error) {
+	return keys.SharedSecret(privateKeyHex, publicKeyHex)
+}
+
+// Encrypt encrypts a plaintext with a shared secret and a random
+// initialization vector, returning the message content.
+func Encrypt(plaintext string, sharedSecret []byte) (string, error) {
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	return encrypt(plaintext, sharedSecret, iv)
+}
+
+func encrypt(plaintext string, sharedSecret, iv []byte) (string, error) {
+	block, err := newCipher(sharedSecret)
+	if err != nil {
+		return "", err
+	}
+
+	padded := pad([]byte(plaintext))
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+
+	return base64.StdEncoding.EncodeToString(ciphertext) +
+		"?iv=" + base64.StdEncoding.EncodeToString(iv), nil
+}
+
+// Decrypt decrypts message content with a shared secret.
+func Decrypt(content string, sharedSecret []byte) (string, error) {
+	block, err := newCipher(sharedSecret)
+	if err != nil {
+		return "", err
+	}
+
+	encoded, encodedIV, found := strings.Cut(content, "?iv=")
+	if !found {
+		return "", errors.MalformedPayload
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return "", errors.MalformedPayload
+	}
+	iv, err := base64.StdEncoding.DecodeString(encodedIV)
+	if err != nil || len(iv) != aes.BlockSize {
+		return "", errors.MalformedPayload
+	}
+
+	padded := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(padded, ciphertext)
+	plaintext, err := unpad(padded)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func newCipher(sharedSecret []byte) (cipher.Block, error) {
+	if len(sharedSecret) != 32 {
+		return nil, errors.MalformedSharedSecret
+	}
+	return aes.NewCipher(sharedSecret)
+}
+
+// pad appends PKCS#7 padding, filling up to a whole number of blocks.
+func pad(data []byte) []byte {
+	n := aes.BlockSize - len(data)%aes.BlockSize
+	return append(data, bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+func unpad(padded []byte) ([]byte, error) {
+	n := int(padded[len(padded)-1])
+	if n == 0 || n > aes.BlockSize {
+		return nil, errors.InvalidPadding
+	}
+	for _, b := range padded[len(padded)-n:] {
+		if int(b) != n {
+			return nil, errors.InvalidPadding
+		}
+	}
+	return padded[:len(padded)-n], nil
+}
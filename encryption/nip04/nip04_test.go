@@ -0,0 +1,172 @@
+package nip04
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"git.wisehodl.dev/jay/go-roots/keys"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// Keys and payload from the nostr-tools compatibility test, with the shared
+// secret and fixed-IV ciphertext computed independently with Node's crypto.
+const (
+	sk1          = "92996316beebf94171065a714cbf164d1f56d7ad9b35b329d9fc97535bf25352"
+	sk2          = "591c0c249adfb9346f8d37dfeed65725e2eea1d7a6e99fa503342f367138de84"
+	sharedHex    = "4ce3fe601d8a7437a40005067790761a172e7b50d80d296cc7ee9e26cbad8dee"
+	nostrTools   = "A+fRnU4aXS4kbTLfowqAww==?iv=QFYUrl5or/n/qamY79ze0A=="
+	fixedIV      = "000102030405060708090a0b0c0d0e0f"
+	fixedPayload = "rXZ4o/Fd17w2e5JdlaQzkLEpKB0SBd+BR4Nl2EKnHMw=?iv=AAECAwQFBgcICQoLDA0ODw=="
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestSharedSecret(t *testing.T) {
+	pk1, err := keys.GetPublicKey(sk1)
+	assert.NoError(t, err)
+	pk2, err := keys.GetPublicKey(sk2)
+	assert.NoError(t, err)
+
+	ss1, err := SharedSecret(sk1, pk2)
+	assert.NoError(t, err)
+	ss2, err := SharedSecret(sk2, pk1)
+	assert.NoError(t, err)
+	assert.Equal(t, sharedHex, hex.EncodeToString(ss1))
+	assert.Equal(t, ss1, ss2)
+}
+
+type SharedSecretErrorTestCase struct {
+	name          string
+	privateKey    string
+	publicKey     string
+	expectedError string
+}
+
+var sharedSecretErrorTestCases = []SharedSecretErrorTestCase{
+	{
+		name:          "short private key",
+		privateKey:    sk1[:62],
+		publicKey:     "cfa87f35acbde29ba1ab3ee42de527b2cad33ac487e80cf2d6405ea0042c8fef",
+		expectedError: "private key must be 64 lowercase hex characters",
+	},
+	{
+		name:          "zero private key",
+		privateKey:    strings.Repeat("0", 64),
+		publicKey:     "cfa87f35acbde29ba1ab3ee42de527b2cad33ac487e80cf2d6405ea0042c8fef",
+		expectedError: "private key is out of range",
+	},
+	{
+		name:          "non-hex public key",
+		privateKey:    sk1,
+		publicKey:     strings.Repeat("z", 64),
+		expectedError: "public key must be 64 lowercase hex characters",
+	},
+	{
+		name:          "public key off the curve",
+		privateKey:    sk1,
+		publicKey:     strings.Repeat("f", 64),
+		expectedError: "public key is not on the curve",
+	},
+}
+
+func TestSharedSecretErrors(t *testing.T) {
+	for _, tc := range sharedSecretErrorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := SharedSecret(tc.privateKey, tc.publicKey)
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestDecryptNostrTools(t *testing.T) {
+	plaintext, err := Decrypt(nostrTools, mustHex(t, sharedHex))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", plaintext)
+}
+
+func TestEncryptFixedIV(t *testing.T) {
+	payload, err := encrypt("hello, world! this spans blocks", mustHex(t, sharedHex), mustHex(t, fixedIV))
+	assert.NoError(t, err)
+	assert.Equal(t, fixedPayload, payload)
+}
+
+func TestRoundTrip(t *testing.T) {
+	secret := mustHex(t, sharedHex)
+	for i := 0; i < 40; i++ {
+		message := strings.Repeat("a", i)
+		payload, err := Encrypt(message, secret)
+		assert.NoError(t, err)
+
+		plaintext, err := Decrypt(payload, secret)
+		assert.NoError(t, err)
+		assert.Equal(t, message, plaintext)
+	}
+
+	// Each message uses a fresh IV
+	first, _ := Encrypt("same", secret)
+	second, _ := Encrypt("same", secret)
+	assert.NotEqual(t, first, second)
+}
+
+type DecryptErrorTestCase struct {
+	name          string
+	content       string
+	expectedError string
+}
+
+var decryptErrorTestCases = []DecryptErrorTestCase{
+	{
+		name:          "missing iv",
+		content:       "A+fRnU4aXS4kbTLfowqAww==",
+		expectedError: "malformed payload",
+	},
+	{
+		name:          "invalid ciphertext encoding",
+		content:       "not base64!?iv=QFYUrl5or/n/qamY79ze0A==",
+		expectedError: "malformed payload",
+	},
+	{
+		name:          "empty ciphertext",
+		content:       "?iv=QFYUrl5or/n/qamY79ze0A==",
+		expectedError: "malformed payload",
+	},
+	{
+		name:          "partial block",
+		content:       base64.StdEncoding.EncodeToString(make([]byte, 15)) + "?iv=QFYUrl5or/n/qamY79ze0A==",
+		expectedError: "malformed payload",
+	},
+	{
+		name:          "short iv",
+		content:       "A+fRnU4aXS4kbTLfowqAww==?iv=QFYUrl5or/n/qamY",
+		expectedError: "malformed payload",
+	},
+	{
+		name:          "wrong iv",
+		content:       "A+fRnU4aXS4kbTLfowqAww==?iv=AAECAwQFBgcICQoLDA0ODw==",
+		expectedError: "invalid padding",
+	},
+}
+
+func TestDecryptErrors(t *testing.T) {
+	secret := mustHex(t, sharedHex)
+	for _, tc := range decryptErrorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Decrypt(tc.content, secret)
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestMalformedSharedSecret(t *testing.T) {
+	_, err := Encrypt("hello", make([]byte, 16))
+	assert.ErrorContains(t, err, "shared secret must be 32 bytes")
+	_, err = Decrypt(nostrTools, make([]byte, 31))
+	assert.ErrorContains(t, err, "shared secret must be 32 bytes")
+}
@@ -0,0 +1,196 @@
+// Package nip44 implements version 2 of the NIP-44 encrypted payload format.
+//
+// Both parties derive the same conversation key from their own private key
+// and the other's public key. Messages are padded, encrypted with ChaCha20
+// and authenticated with HMAC-SHA256 under keys derived per message with
+// HKDF, and encoded as base64.
+package nip44
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/keys"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"math/bits"
+)
+
+const (
+	// Version is the payload version implemented by this package.
+	Version = 2
+
+	// MinPlaintextSize and MaxPlaintextSize bound the plaintext length in
+	// bytes.
+	MinPlaintextSize = 1
+	MaxPlaintextSize = 65535
+)
+
+// Payload and decoded data size bounds, for the smallest and largest
+// padded plaintexts.
+const (
+	minPayloadSize = 132
+	maxPayloadSize = 87472
+	minDataSize    = 99
+	maxDataSize    = 65603
+)
+
+// ConversationKey derives the key shared by the owner of the private key and
+// the owner of the public key. Both are hex encoded; the public key is the
+// 32-byte x-coordinate used by Nostr.
+func ConversationKey(privateKeyHex, publicKeyHex string) ([]byte, error) {
+	shared, err := keys.SharedSecret(privateKeyHex, publicKeyHex)
+	if err != nil {
+		return nil, err
+	}
+	return hkdf.Extract(sha256.New, shared, []byte("nip44-v2")), nil
+}
+
+// Encrypt encrypts a plaintext of 1 to 65535 bytes with a conversation key
+// and a random nonce, returning the base64 payload.
+func Encrypt(plaintext string, conversationKey []byte) (string, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return encrypt(plaintext, conversationKey, nonce)
+}
+
+func encrypt(plaintext string, conversationKey, nonce []byte) (string, error) {
+	chachaKey, chachaNonce, hmacKey, err := messageKeys(conversationKey, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	padded, err := pad(plaintext)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := xorChaCha20(chachaKey, chachaNonce, padded)
+	if err != nil {
+		return "", err
+	}
+	mac := hmacAAD(hmacKey, nonce, ciphertext)
+
+	data := make([]byte, 0, 1+len(nonce)+len(ciphertext)+len(mac))
+	data = append(data, Version)
+	data = append(data, nonce...)
+	data = append(data, ciphertext...)
+	data = append(data, mac...)
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// Decrypt authenticates and decrypts a base64 payload with a conversation
+// key.
+func Decrypt(payload string, conversationKey []byte) (string, error) {
+	if payload == "" || payload[0] == '#' {
+		return "", errors.UnsupportedVersion
+	}
+	if len(payload) < minPayloadSize || len(payload) > maxPayloadSize {
+		return "", errors.MalformedPayload
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", errors.MalformedPayload
+	}
+	if len(data) < minDataSize || len(data) > maxDataSize {
+		return "", errors.MalformedPayload
+	}
+	if data[0] != Version {
+		return "", errors.UnsupportedVersion
+	}
+
+	nonce := data[1:33]
+	ciphertext := data[33 : len(data)-32]
+	mac := data[len(data)-32:]
+
+	chachaKey, chachaNonce, hmacKey, err := messageKeys(conversationKey, nonce)
+	if err != nil {
+		return "", err
+	}
+	if !hmac.Equal(mac, hmacAAD(hmacKey, nonce, ciphertext)) {
+		return "", errors.InvalidMAC
+	}
+
+	padded, err := xorChaCha20(chachaKey, chachaNonce, ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return unpad(padded)
+}
+
+// messageKeys derives the per-message ChaCha20 key and nonce and HMAC key.
+func messageKeys(conversationKey, nonce []byte) (chachaKey, chachaNonce, hmacKey []byte, err error) {
+	if len(conversationKey) != 32 {
+		return nil, nil, nil, errors.MalformedConversationKey
+	}
+	if len(nonce) != 32 {
+		return nil, nil, nil, errors.MalformedPayload
+	}
+
+	keys := make([]byte, 76)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, conversationKey, nonce), keys); err != nil {
+		return nil, nil, nil, err
+	}
+	return keys[:32], keys[32:44], keys[44:76], nil
+}
+
+func xorChaCha20(key, nonce, input []byte) ([]byte, error) {
+	cipher, err := chacha20.NewUnauthenticatedCipher(key, nonce)
+	if err != nil {
+		return nil, err
+	}
+	output := make([]byte, len(input))
+	cipher.XORKeyStream(output, input)
+	return output, nil
+}
+
+// hmacAAD authenticates the ciphertext together with the nonce.
+func hmacAAD(key, nonce, ciphertext []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(nonce)
+	h.Write(ciphertext)
+	return h.Sum(nil)
+}
+
+// calcPaddedLen returns the length a plaintext is padded to, hiding its
+// exact size: 32 bytes for short messages, then chunks of an eighth of the
+// next power of two.
+func calcPaddedLen(length int) int {
+	if length <= 32 {
+		return 32
+	}
+	nextPower := 1 << bits.Len(uint(length-1))
+	chunk := 32
+	if nextPower > 256 {
+		chunk = nextPower / 8
+	}
+	return chunk * ((length-1)/chunk + 1)
+}
+
+// pad prefixes the plaintext with its big-endian length and appends zeros.
+func pad(plaintext string) ([]byte, error) {
+	length := len(plaintext)
+	if length < MinPlaintextSize || length > MaxPlaintextSize {
+		return nil, errors.InvalidPlaintextLength
+	}
+	padded := make([]byte, 2+calcPaddedLen(length))
+	binary.BigEndian.PutUint16(padded, uint16(length))
+	copy(padded[2:], plaintext)
+	return padded, nil
+}
+
+func unpad(padded []byte) (string, error) {
+	if len(padded) < 2 {
+		return "", errors.InvalidPadding
+	}
+	length := int(binary.BigEndian.Uint16(padded))
+	if length < MinPlaintextSize || len(padded) != 2+calcPaddedLen(length) {
+		return "", errors.InvalidPadding
+	}
+	return string(padded[2 : 2+length]), nil
+}
@@ -0,0 +1,240 @@
+package nip44
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"git.wisehodl.dev/jay/go-roots/keys"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"strings"
+	"testing"
+)
+
+// vectors holds the official NIP-44 v2 test vectors from
+// github.com/paulmillr/nip44, in testdata/vectors.json.
+type vectors struct {
+	V2 struct {
+		Valid struct {
+			GetConversationKey []struct {
+				Sec1            string `json:"sec1"`
+				Pub2            string `json:"pub2"`
+				ConversationKey string `json:"conversation_key"`
+			} `json:"get_conversation_key"`
+			GetMessageKeys struct {
+				ConversationKey string `json:"conversation_key"`
+				Keys            []struct {
+					Nonce       string `json:"nonce"`
+					ChaChaKey   string `json:"chacha_key"`
+					ChaChaNonce string `json:"chacha_nonce"`
+					HMACKey     string `json:"hmac_key"`
+				} `json:"keys"`
+			} `json:"get_message_keys"`
+			CalcPaddedLen  [][2]int `json:"calc_padded_len"`
+			EncryptDecrypt []struct {
+				Sec1            string `json:"sec1"`
+				Sec2            string `json:"sec2"`
+				ConversationKey string `json:"conversation_key"`
+				Nonce           string `json:"nonce"`
+				Plaintext       string `json:"plaintext"`
+				Payload         string `json:"payload"`
+			} `json:"encrypt_decrypt"`
+			EncryptDecryptLongMsg []struct {
+				ConversationKey string `json:"conversation_key"`
+				Nonce           string `json:"nonce"`
+				Pattern         string `json:"pattern"`
+				Repeat          int    `json:"repeat"`
+				PlaintextSHA256 string `json:"plaintext_sha256"`
+				PayloadSHA256   string `json:"payload_sha256"`
+			} `json:"encrypt_decrypt_long_msg"`
+		} `json:"valid"`
+		Invalid struct {
+			EncryptMsgLengths  []int `json:"encrypt_msg_lengths"`
+			GetConversationKey []struct {
+				Sec1 string `json:"sec1"`
+				Pub2 string `json:"pub2"`
+				Note string `json:"note"`
+			} `json:"get_conversation_key"`
+			Decrypt []struct {
+				ConversationKey string `json:"conversation_key"`
+				Nonce           string `json:"nonce"`
+				Plaintext       string `json:"plaintext"`
+				Payload         string `json:"payload"`
+				Note            string `json:"note"`
+			} `json:"decrypt"`
+		} `json:"invalid"`
+	} `json:"v2"`
+}
+
+func loadVectors(t *testing.T) vectors {
+	data, err := os.ReadFile("testdata/vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v vectors
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func sha256Hex(s string) string {
+	hash := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(hash[:])
+}
+
+func TestConversationKeyVectors(t *testing.T) {
+	for _, v := range loadVectors(t).V2.Valid.GetConversationKey {
+		key, err := ConversationKey(v.Sec1, v.Pub2)
+		assert.NoError(t, err)
+		assert.Equal(t, v.ConversationKey, hex.EncodeToString(key), "sec1 %s pub2 %s", v.Sec1, v.Pub2)
+	}
+}
+
+func TestInvalidConversationKeyVectors(t *testing.T) {
+	for _, v := range loadVectors(t).V2.Invalid.GetConversationKey {
+		t.Run(v.Note, func(t *testing.T) {
+			_, err := ConversationKey(v.Sec1, v.Pub2)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestConversationKeyErrors(t *testing.T) {
+	sk := strings.Repeat("0", 63) + "1"
+	pk := "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+
+	_, err := ConversationKey("abc", pk)
+	assert.ErrorContains(t, err, "private key must be 64 lowercase hex characters")
+	_, err = ConversationKey(strings.Repeat("0", 64), pk)
+	assert.ErrorContains(t, err, "private key is out of range")
+	_, err = ConversationKey(sk, "zz"+pk[2:])
+	assert.ErrorContains(t, err, "public key must be 64 lowercase hex characters")
+	_, err = ConversationKey(sk, strings.Repeat("f", 64))
+	assert.ErrorContains(t, err, "public key is not on the curve")
+}
+
+func TestConversationKeySymmetry(t *testing.T) {
+	sk1, _ := keys.GeneratePrivateKey()
+	sk2, _ := keys.GeneratePrivateKey()
+	pk1, _ := keys.GetPublicKey(sk1)
+	pk2, _ := keys.GetPublicKey(sk2)
+
+	key1, err := ConversationKey(sk1, pk2)
+	assert.NoError(t, err)
+	key2, err := ConversationKey(sk2, pk1)
+	assert.NoError(t, err)
+	assert.Equal(t, key1, key2)
+}
+
+func TestMessageKeyVectors(t *testing.T) {
+	mk := loadVectors(t).V2.Valid.GetMessageKeys
+	conversationKey := mustHex(t, mk.ConversationKey)
+	for _, v := range mk.Keys {
+		chachaKey, chachaNonce, hmacKey, err := messageKeys(conversationKey, mustHex(t, v.Nonce))
+		assert.NoError(t, err)
+		assert.Equal(t, v.ChaChaKey, hex.EncodeToString(chachaKey))
+		assert.Equal(t, v.ChaChaNonce, hex.EncodeToString(chachaNonce))
+		assert.Equal(t, v.HMACKey, hex.EncodeToString(hmacKey))
+	}
+}
+
+func TestCalcPaddedLenVectors(t *testing.T) {
+	for _, v := range loadVectors(t).V2.Valid.CalcPaddedLen {
+		assert.Equal(t, v[1], calcPaddedLen(v[0]), "length %d", v[0])
+	}
+}
+
+func TestEncryptDecryptVectors(t *testing.T) {
+	for _, v := range loadVectors(t).V2.Valid.EncryptDecrypt {
+		t.Run(v.Plaintext, func(t *testing.T) {
+			pub2, err := keys.GetPublicKey(v.Sec2)
+			assert.NoError(t, err)
+			key, err := ConversationKey(v.Sec1, pub2)
+			assert.NoError(t, err)
+			assert.Equal(t, v.ConversationKey, hex.EncodeToString(key))
+
+			payload, err := encrypt(v.Plaintext, key, mustHex(t, v.Nonce))
+			assert.NoError(t, err)
+			assert.Equal(t, v.Payload, payload)
+
+			plaintext, err := Decrypt(v.Payload, key)
+			assert.NoError(t, err)
+			assert.Equal(t, v.Plaintext, plaintext)
+		})
+	}
+}
+
+func TestEncryptDecryptLongVectors(t *testing.T) {
+	for _, v := range loadVectors(t).V2.Valid.EncryptDecryptLongMsg {
+		plaintext := strings.Repeat(v.Pattern, v.Repeat)
+		assert.Equal(t, v.PlaintextSHA256, sha256Hex(plaintext))
+
+		key := mustHex(t, v.ConversationKey)
+		payload, err := encrypt(plaintext, key, mustHex(t, v.Nonce))
+		assert.NoError(t, err)
+		assert.Equal(t, v.PayloadSHA256, sha256Hex(payload))
+
+		decrypted, err := Decrypt(payload, key)
+		assert.NoError(t, err)
+		assert.Equal(t, plaintext, decrypted)
+	}
+}
+
+func TestInvalidDecryptVectors(t *testing.T) {
+	for _, v := range loadVectors(t).V2.Invalid.Decrypt {
+		t.Run(v.Note, func(t *testing.T) {
+			_, err := Decrypt(v.Payload, mustHex(t, v.ConversationKey))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestInvalidEncryptLengthVectors(t *testing.T) {
+	key := make([]byte, 32)
+	for _, length := range loadVectors(t).V2.Invalid.EncryptMsgLengths {
+		_, err := Encrypt(strings.Repeat("a", length), key)
+		assert.ErrorContains(t, err, "invalid plaintext length")
+	}
+}
+
+func TestEncryptRandomNonce(t *testing.T) {
+	key := make([]byte, 32)
+	first, err := Encrypt("hello", key)
+	assert.NoError(t, err)
+	second, err := Encrypt("hello", key)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, second)
+
+	plaintext, err := Decrypt(first, key)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", plaintext)
+
+	_, err = Encrypt("hello", key[:16])
+	assert.ErrorContains(t, err, "conversation key must be 32 bytes")
+}
+
+func TestDecryptErrors(t *testing.T) {
+	key := make([]byte, 32)
+	payload, _ := Encrypt("hello", key)
+
+	_, err := Decrypt("", key)
+	assert.ErrorContains(t, err, "unknown encryption version")
+	_, err = Decrypt("#"+payload[1:], key)
+	assert.ErrorContains(t, err, "unknown encryption version")
+	_, err = Decrypt(payload[:100], key)
+	assert.ErrorContains(t, err, "malformed payload")
+
+	otherKey := make([]byte, 32)
+	otherKey[0] = 1
+	_, err = Decrypt(payload, otherKey)
+	assert.ErrorContains(t, err, "invalid mac")
+}
@@ -0,0 +1,196 @@
+// Package nip49 encrypts private keys with a password, as NIP-49 ncryptsec
+// strings, for storing keys at rest.
+//
+// The password is normalized to NFKC and stretched with scrypt into a key
+// for XChaCha20-Poly1305, which encrypts the private key and authenticates
+// a byte recording how securely the key has been handled.
+package nip49
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/bech32"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/keys"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// Version is the encrypted key version implemented by this package.
+	Version = 0x02
+
+	// HRP is the bech32 prefix of encrypted keys.
+	HRP = "ncryptsec"
+
+	// DefaultLogN is a scrypt cost of 2^16 rounds, which takes 64 MiB of
+	// memory and around 100 milliseconds.
+	DefaultLogN = 16
+
+	// MaxLogN bounds the scrypt cost by default, at 1 GiB of memory, since
+	// any ncryptsec string can ask for the cost it likes. Each step up
+	// doubles the memory.
+	MaxLogN = 20
+)
+
+// EncryptOptions configures encryption.
+type EncryptOptions struct {
+	// MaxLogN bounds the scrypt cost a key may be encrypted with. Zero uses
+	// the package MaxLogN. Strings above the package MaxLogN can only be
+	// decrypted with a DecryptOptions.MaxLogN at least as large.
+	MaxLogN uint8
+}
+
+// DecryptOptions configures decryption.
+type DecryptOptions struct {
+	// MaxLogN bounds the scrypt cost of strings that will be decrypted.
+	// Zero uses the package MaxLogN. Larger values admit strings that can
+	// require 2^(MaxLogN+10) bytes of memory.
+	MaxLogN uint8
+}
+
+// KeySecurity records whether a key is known to have been handled
+// insecurely, such as stored unencrypted or pasted into a website.
+type KeySecurity byte
+
+const (
+	// KeyInsecure marks a key known to have been handled insecurely.
+	KeyInsecure KeySecurity = 0x00
+
+	// KeySecure marks a key known not to have been handled insecurely.
+	KeySecure KeySecurity = 0x01
+
+	// KeySecurityUnknown marks a key whose handling is not tracked.
+	KeySecurityUnknown KeySecurity = 0x02
+)
+
+// Sizes of the fields of an encrypted key: version, log_n, salt, nonce,
+// key security and the ciphertext with its tag.
+const (
+	saltSize       = 16
+	ciphertextSize = 32 + chacha20poly1305.Overhead
+
+	saltOffset       = 2
+	nonceOffset      = saltOffset + saltSize
+	securityOffset   = nonceOffset + chacha20poly1305.NonceSizeX
+	ciphertextOffset = securityOffset + 1
+	encodedSize      = ciphertextOffset + ciphertextSize
+)
+
+// Encrypt encrypts a hex private key with a password and a scrypt cost of
+// 2^logN, returning an ncryptsec string. A logN of zero or above MaxLogN
+// fails with errors.InvalidLogN.
+func Encrypt(privateKeyHex, password string, logN uint8, security KeySecurity) (string, error) {
+	return EncryptWithOptions(privateKeyHex, password, logN, security, EncryptOptions{})
+}
+
+// EncryptWithOptions encrypts a hex private key like Encrypt, with options.
+func EncryptWithOptions(privateKeyHex, password string, logN uint8, security KeySecurity, opts EncryptOptions) (string, error) {
+	if opts.MaxLogN == 0 {
+		opts.MaxLogN = MaxLogN
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return encrypt(privateKeyHex, password, logN, opts.MaxLogN, security, salt, nonce)
+}
+
+func encrypt(privateKeyHex, password string, logN, maxLogN uint8, security KeySecurity, salt, nonce []byte) (string, error) {
+	sk, err := keys.ParsePrivateKey(privateKeyHex)
+	if err != nil {
+		return "", err
+	}
+	if security > KeySecurityUnknown {
+		return "", fmt.Errorf("%w: key security byte %d", errors.MalformedEncryptedKey, security)
+	}
+
+	aead, err := newCipher(password, salt, logN, maxLogN)
+	if err != nil {
+		return "", err
+	}
+
+	data := make([]byte, 0, encodedSize)
+	data = append(data, Version, logN)
+	data = append(data, salt...)
+	data = append(data, nonce...)
+	data = append(data, byte(security))
+	data = aead.Seal(data, nonce, sk[:], []byte{byte(security)})
+	return bech32.Encode(HRP, data)
+}
+
+// Decrypt decrypts an ncryptsec string with a password, returning the hex
+// private key. A wrong password fails with errors.WrongPassword.
+func Decrypt(ncryptsec, password string) (string, error) {
+	return DecryptWithOptions(ncryptsec, password, DecryptOptions{})
+}
+
+// DecryptWithOptions decrypts an ncryptsec string like Decrypt, with options.
+func DecryptWithOptions(ncryptsec, password string, opts DecryptOptions) (string, error) {
+	if opts.MaxLogN == 0 {
+		opts.MaxLogN = MaxLogN
+	}
+	data, err := decode(ncryptsec)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newCipher(password, data[saltOffset:nonceOffset], data[1], opts.MaxLogN)
+	if err != nil {
+		return "", err
+	}
+	nonce := data[nonceOffset:securityOffset]
+	ad := data[securityOffset:ciphertextOffset]
+	sk, err := aead.Open(nil, nonce, data[ciphertextOffset:], ad)
+	if err != nil {
+		return "", errors.WrongPassword
+	}
+	return hex.EncodeToString(sk), nil
+}
+
+// Inspect returns the scrypt cost and key security of an ncryptsec string
+// without decrypting it.
+func Inspect(ncryptsec string) (logN uint8, security KeySecurity, err error) {
+	data, err := decode(ncryptsec)
+	if err != nil {
+		return 0, 0, err
+	}
+	return data[1], KeySecurity(data[securityOffset]), nil
+}
+
+// decode returns the bytes of an ncryptsec string, checking its prefix,
+// length and version.
+func decode(ncryptsec string) ([]byte, error) {
+	hrp, data, err := bech32.Decode(ncryptsec)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errors.MalformedEncryptedKey, err)
+	}
+	if hrp != HRP {
+		return nil, fmt.Errorf("%w: prefix %q", errors.MalformedEncryptedKey, hrp)
+	}
+	if len(data) != encodedSize {
+		return nil, fmt.Errorf("%w: %d bytes", errors.MalformedEncryptedKey, len(data))
+	}
+	if data[0] != Version {
+		return nil, errors.UnsupportedVersion
+	}
+	return data, nil
+}
+
+func newCipher(password string, salt []byte, logN, maxLogN uint8) (cipher.AEAD, error) {
+	if logN < 1 || logN > maxLogN {
+		return nil, fmt.Errorf("%w: %d", errors.InvalidLogN, logN)
+	}
+	key, err := scrypt.Key([]byte(norm.NFKC.String(password)), salt, 1<<logN, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.NewX(key)
+}
@@ -0,0 +1,176 @@
+package nip49
+
+import (
+	"git.wisehodl.dev/jay/go-roots/bech32"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+const testSK = "f43a0435f69529f310bbd1d6263d2fbf0977f54bfe2310cc37ae5904b83bb167"
+
+// The example from NIP-49, encrypted with log_n 16 and password "nostr"
+const (
+	specEncrypted  = "ncryptsec1qgg9947rlpvqu76pj5ecreduf9jxhselq2nae2kghhvd5g7dgjtcxfqtd67p9m0w57lspw8gsq6yphnm8623nsl8xn9j4jdzz84zm3frztj3z7s35vpzmqf6ksu8r89qk5z2zxfmu5gv8th8wclt0h4p"
+	specPrivateKey = "3501454135014541350145413501453fefb02227e449e57cf4d3a3ce05378683"
+)
+
+func TestDecryptSpecExample(t *testing.T) {
+	sk, err := Decrypt(specEncrypted, "nostr")
+	assert.NoError(t, err)
+	assert.Equal(t, specPrivateKey, sk)
+
+	logN, _, err := Inspect(specEncrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(16), logN)
+
+	_, err = Decrypt(specEncrypted, "Nostr")
+	assert.ErrorContains(t, err, "wrong password or corrupt encrypted key")
+}
+
+type RoundTripTestCase struct {
+	name     string
+	password string
+	logN     uint8
+	security KeySecurity
+}
+
+var roundTripTestCases = []RoundTripTestCase{
+	{"ascii password", ".ksjabdk.aselqwe", 1, KeyInsecure},
+	{"accented password", "skjdaklrnçurbç l", 2, KeySecure},
+	{"empty password", "", 4, KeySecurityUnknown},
+	{"combining marks", "\u212b\u2126\u1e9b\u0323", 8, KeySecure},
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, tc := range roundTripTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			encrypted, err := Encrypt(testSK, tc.password, tc.logN, tc.security)
+			assert.NoError(t, err)
+			assert.True(t, strings.HasPrefix(encrypted, "ncryptsec1"))
+			assert.Len(t, encrypted, 162)
+
+			logN, security, err := Inspect(encrypted)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.logN, logN)
+			assert.Equal(t, tc.security, security)
+
+			sk, err := Decrypt(encrypted, tc.password)
+			assert.NoError(t, err)
+			assert.Equal(t, testSK, sk)
+		})
+	}
+}
+
+func TestRandomSaltAndNonce(t *testing.T) {
+	a, _ := Encrypt(testSK, "password", 1, KeySecure)
+	b, _ := Encrypt(testSK, "password", 1, KeySecure)
+	assert.NotEqual(t, a, b)
+}
+
+func TestPasswordNormalization(t *testing.T) {
+	// The password from NIP-49, with the angstrom and ohm signs and a long s
+	// with dots, decrypts with its NFKC and NFD forms
+	encrypted, err := encrypt(testSK, "\u212b\u2126\u1e9b\u0323", 4, MaxLogN, KeySecure, make([]byte, saltSize), make([]byte, 24))
+	assert.NoError(t, err)
+
+	for _, password := range []string{"\u00c5\u03a9\u1e69", "A\u030a\u03a9s\u0323\u0307"} {
+		sk, err := Decrypt(encrypted, password)
+		assert.NoError(t, err)
+		assert.Equal(t, testSK, sk)
+	}
+
+	_, err = Decrypt(encrypted, "\u00c5\u03a9s")
+	assert.ErrorContains(t, err, "wrong password or corrupt encrypted key")
+}
+
+func TestSecurityByteIsAuthenticated(t *testing.T) {
+	encrypted, err := encrypt(testSK, "password", 1, MaxLogN, KeySecure, make([]byte, saltSize), make([]byte, 24))
+	assert.NoError(t, err)
+
+	_, data, _ := bech32.Decode(encrypted)
+	data[securityOffset] = byte(KeyInsecure)
+	tampered, _ := bech32.Encode(HRP, data)
+	_, err = Decrypt(tampered, "password")
+	assert.ErrorContains(t, err, "wrong password or corrupt encrypted key")
+}
+
+type EncryptErrorTestCase struct {
+	name          string
+	privateKey    string
+	logN          uint8
+	security      KeySecurity
+	expectedError string
+}
+
+var encryptErrorTestCases = []EncryptErrorTestCase{
+	{"short private key", testSK[:62], 1, KeySecure, "private key must be 64 lowercase hex characters"},
+	{"non-hex private key", strings.Repeat("z", 64), 1, KeySecure, "private key must be 64 lowercase hex characters"},
+	{"zero log_n", testSK, 0, KeySecure, "log_n is out of range: 0"},
+	{"uppercase private key", strings.ToUpper(testSK), 1, KeySecure, "private key must be 64 lowercase hex characters"},
+	{"zero private key", strings.Repeat("0", 64), 1, KeySecure, "private key is out of range"},
+	{"private key above order", strings.Repeat("f", 64), 1, KeySecure, "private key is out of range"},
+	{"log_n too large", testSK, MaxLogN + 1, KeySecure, "log_n is out of range: 21"},
+	{"unknown key security", testSK, 1, 3, "malformed encrypted key: key security byte 3"},
+}
+
+func TestEncryptErrors(t *testing.T) {
+	for _, tc := range encryptErrorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Encrypt(tc.privateKey, "password", tc.logN, tc.security)
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
+
+func reencode(t *testing.T, hrp string, edit func([]byte) []byte) string {
+	_, data, err := bech32.Decode(specEncrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := bech32.Encode(hrp, edit(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestDecryptErrors(t *testing.T) {
+	_, err := Decrypt("ncryptsec1qqqq", "nostr")
+	assert.ErrorContains(t, err, "malformed encrypted key: malformed bech32 string")
+
+	_, err = Decrypt(reencode(t, "nsec", func(d []byte) []byte { return d }), "nostr")
+	assert.ErrorContains(t, err, `malformed encrypted key: prefix "nsec"`)
+
+	_, err = Decrypt(reencode(t, HRP, func(d []byte) []byte { return d[:90] }), "nostr")
+	assert.ErrorContains(t, err, "malformed encrypted key: 90 bytes")
+
+	_, err = Decrypt(reencode(t, HRP, func(d []byte) []byte { d[0] = 1; return d }), "nostr")
+	assert.ErrorContains(t, err, "unknown encryption version")
+
+	_, err = Decrypt(reencode(t, HRP, func(d []byte) []byte { d[1] = 64; return d }), "nostr")
+	assert.ErrorContains(t, err, "log_n is out of range: 64")
+}
+
+func TestDecryptMaxLogN(t *testing.T) {
+	_, err := Decrypt(reencode(t, HRP, func(d []byte) []byte { d[1] = 21; return d }), "nostr")
+	assert.ErrorContains(t, err, "log_n is out of range: 21")
+
+	_, err = DecryptWithOptions(specEncrypted, "nostr", DecryptOptions{MaxLogN: 15})
+	assert.ErrorContains(t, err, "log_n is out of range: 16")
+
+	sk, err := DecryptWithOptions(specEncrypted, "nostr", DecryptOptions{MaxLogN: 16})
+	assert.NoError(t, err)
+	assert.Equal(t, specPrivateKey, sk)
+}
+
+func TestEncryptMaxLogN(t *testing.T) {
+	_, err := EncryptWithOptions(testSK, "password", 3, KeySecure, EncryptOptions{MaxLogN: 2})
+	assert.ErrorContains(t, err, "log_n is out of range: 3")
+
+	encrypted, err := EncryptWithOptions(testSK, "password", 2, KeySecure, EncryptOptions{MaxLogN: 2})
+	assert.NoError(t, err)
+	sk, err := DecryptWithOptions(encrypted, "password", DecryptOptions{MaxLogN: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, testSK, sk)
+}
@@ -28,4 +28,167 @@ var (
 
 	// InvalidSig indicates the event signature failed cryptographic validation.
 	InvalidSig = errors.New("event signature is invalid")
+
+	// MalformedSearch indicates a filter's search extension is not a JSON string.
+	MalformedSearch = errors.New("search must be a string")
+
+	// UnsupportedDialect indicates a SQL dialect is not recognized by the compiler.
+	UnsupportedDialect = errors.New("unsupported sql dialect")
+
+	// DuplicateEvent indicates an event with the same ID is already stored.
+	DuplicateEvent = errors.New("event is already stored")
+
+	// ReplacedEvent indicates a newer version of a replaceable or addressable
+	// event is already stored.
+	ReplacedEvent = errors.New("event is replaced by a newer version")
+
+	// EventNotFound indicates no stored event has the requested ID.
+	EventNotFound = errors.New("event not found")
+
+	// CorruptLog indicates an event log contains a damaged record before its end.
+	CorruptLog = errors.New("event log is corrupt")
+
+	// StoreClosed indicates an operation on a closed or failed store.
+	StoreClosed = errors.New("store is closed")
+
+	// MalformedMessage indicates a protocol message is not a well-formed array
+	// for its label.
+	MalformedMessage = errors.New("malformed message")
+
+	// UnknownMessage indicates a protocol message has an unrecognized label.
+	UnknownMessage = errors.New("unknown message type")
+
+	// EventRejected indicates a relay did not accept a published event.
+	EventRejected = errors.New("event was rejected")
+
+	// SubscriptionClosed indicates a relay ended a subscription.
+	SubscriptionClosed = errors.New("subscription was closed by relay")
+
+	// ClientClosed indicates an operation on a closed relay client.
+	ClientClosed = errors.New("client is closed")
+
+	// MessageTooLong indicates a message exceeds a relay's maximum length.
+	MessageTooLong = errors.New("message is too long")
+
+	// TooManyTags indicates an event has more tags than a relay allows.
+	TooManyTags = errors.New("event has too many tags")
+
+	// ContentTooLong indicates an event's content exceeds a relay's maximum length.
+	ContentTooLong = errors.New("event content is too long")
+
+	// InsufficientPoW indicates an event ID has fewer leading zero bits than
+	// required.
+	InsufficientPoW = errors.New("event proof of work is insufficient")
+
+	// CreatedAtOutOfRange indicates an event timestamp is too far in the past
+	// or future.
+	CreatedAtOutOfRange = errors.New("event created_at is out of range")
+
+	// LimitTooHigh indicates a filter limit exceeds a relay's maximum.
+	LimitTooHigh = errors.New("filter limit is too high")
+
+	// InvalidAuth indicates an authentication event does not prove the client
+	// answered the relay's challenge.
+	InvalidAuth = errors.New("auth event is invalid")
+
+	// MissingPoWTarget indicates an event has no nonce tag committing to a
+	// proof of work target.
+	MissingPoWTarget = errors.New("event has no committed proof of work target")
+
+	// InvalidPrivKey indicates a private key is zero or not less than the
+	// secp256k1 group order.
+	InvalidPrivKey = errors.New("private key is out of range")
+
+	// InvalidPubKey indicates a public key is not the x-coordinate of a point
+	// on the secp256k1 curve.
+	InvalidPubKey = errors.New("public key is not on the curve")
+
+	// UnsupportedVersion indicates an encrypted payload uses an unknown
+	// encryption version.
+	UnsupportedVersion = errors.New("unknown encryption version")
+
+	// MalformedPayload indicates an encrypted payload has an invalid length or
+	// encoding.
+	MalformedPayload = errors.New("malformed payload")
+
+	// InvalidMAC indicates an encrypted payload failed authentication.
+	InvalidMAC = errors.New("invalid mac")
+
+	// InvalidPadding indicates a decrypted payload is not correctly padded.
+	InvalidPadding = errors.New("invalid padding")
+
+	// InvalidPlaintextLength indicates a plaintext is empty or too long to
+	// encrypt.
+	InvalidPlaintextLength = errors.New("invalid plaintext length")
+
+	// MalformedConversationKey indicates a conversation key is not 32 bytes.
+	MalformedConversationKey = errors.New("conversation key must be 32 bytes")
+
+	// MalformedSharedSecret indicates a NIP-04 shared secret is not 32 bytes.
+	MalformedSharedSecret = errors.New("shared secret must be 32 bytes")
+
+	// InvalidGiftWrap indicates a gift wrap, its seal or its rumor is invalid.
+	InvalidGiftWrap = errors.New("gift wrap is invalid")
+
+	// UnexpectedKind indicates an event is not of the kind a function reads.
+	UnexpectedKind = errors.New("unexpected event kind")
+
+	// InvalidMnemonic indicates a mnemonic has an unknown word, the wrong
+	// number of words, or a bad checksum.
+	InvalidMnemonic = errors.New("mnemonic is invalid")
+
+	// InvalidSeed indicates a seed cannot derive keys.
+	InvalidSeed = errors.New("seed is invalid")
+
+	// MalformedDerivationPath indicates a key derivation path cannot be parsed.
+	MalformedDerivationPath = errors.New("derivation path is malformed")
+
+	// MalformedBech32 indicates a string is not valid bech32.
+	MalformedBech32 = errors.New("malformed bech32 string")
+
+	// MalformedEncryptedKey indicates an ncryptsec string cannot be decoded.
+	MalformedEncryptedKey = errors.New("malformed encrypted key")
+
+	// InvalidLogN indicates a scrypt cost parameter is out of range.
+	InvalidLogN = errors.New("log_n is out of range")
+
+	// WrongPassword indicates an encrypted key failed to decrypt, because the
+	// password is wrong or the key is corrupt.
+	WrongPassword = errors.New("wrong password or corrupt encrypted key")
+
+	// WipedKey indicates a private key was used after it was wiped.
+	WipedKey = errors.New("private key has been wiped")
+
+	// UnknownKeyFormat indicates a string is not a hex, npub or nsec key.
+	UnknownKeyFormat = errors.New("unrecognized key format")
+
+	// InvalidVanityPattern indicates a vanity pattern cannot occur in an
+	// encoded public key.
+	InvalidVanityPattern = errors.New("invalid vanity pattern")
+
+	// MalformedDelegation indicates a delegation tag or its conditions cannot
+	// be parsed.
+	MalformedDelegation = errors.New("malformed delegation")
+
+	// InvalidDelegation indicates a delegation signature is invalid, or an
+	// event does not meet the delegation conditions.
+	InvalidDelegation = errors.New("delegation is invalid")
+
+	// MalformedURI indicates a bunker:// or nostrconnect:// URI cannot be
+	// parsed.
+	MalformedURI = errors.New("malformed connection uri")
+
+	// PermissionDenied indicates a remote signer refused a client's request.
+	PermissionDenied = errors.New("permission denied")
+
+	// RemoteSignerError indicates a remote signer answered a request with an
+	// error.
+	RemoteSignerError = errors.New("remote signer returned an error")
+
+	// NoRelays indicates a relay pool has no relays to use.
+	NoRelays = errors.New("pool has no relays")
+
+	// InvalidDifficulty indicates a proof of work difficulty is not between
+	// 0 and 256 bits.
+	InvalidDifficulty = errors.New("difficulty must be between 0 and 256")
 )
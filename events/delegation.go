@@ -0,0 +1,174 @@
+package events
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"strconv"
+	"strings"
+)
+
+// DelegationTagName is the name of the NIP-26 delegation tag,
+// ["delegation", <delegator pubkey>, <conditions>, <token signature>].
+const DelegationTagName = "delegation"
+
+// Conditions restricts the events a NIP-26 delegation allows. The zero value
+// allows every event.
+type Conditions struct {
+	// Kinds, if not empty, are the allowed event kinds.
+	Kinds []int
+
+	// CreatedAfter and CreatedBefore, if set, are exclusive bounds on
+	// created_at.
+	CreatedAfter  *int
+	CreatedBefore *int
+}
+
+// ParseConditions parses a conditions query string such as
+// "kind=1&created_at>1700000000&created_at<1800000000". Repeated kind
+// clauses allow any of the kinds; repeated bounds must all hold.
+func ParseConditions(s string) (Conditions, error) {
+	var c Conditions
+	if s == "" {
+		return c, fmt.Errorf("%w: empty conditions", errors.MalformedDelegation)
+	}
+	for _, clause := range strings.Split(s, "&") {
+		var field, op, value string
+		if i := strings.IndexAny(clause, "=<>"); i >= 0 {
+			field, op, value = clause[:i], clause[i:i+1], clause[i+1:]
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || value != strconv.Itoa(n) {
+			return Conditions{}, fmt.Errorf("%w: condition %q", errors.MalformedDelegation, clause)
+		}
+
+		switch {
+		case field == "kind" && op == "=":
+			c.Kinds = append(c.Kinds, n)
+		case field == "created_at" && op == ">":
+			if c.CreatedAfter == nil || n > *c.CreatedAfter {
+				c.CreatedAfter = &n
+			}
+		case field == "created_at" && op == "<":
+			if c.CreatedBefore == nil || n < *c.CreatedBefore {
+				c.CreatedBefore = &n
+			}
+		default:
+			return Conditions{}, fmt.Errorf("%w: condition %q", errors.MalformedDelegation, clause)
+		}
+	}
+	return c, nil
+}
+
+// String encodes the conditions as a query string.
+func (c Conditions) String() string {
+	var clauses []string
+	for _, kind := range c.Kinds {
+		clauses = append(clauses, "kind="+strconv.Itoa(kind))
+	}
+	if c.CreatedAfter != nil {
+		clauses = append(clauses, "created_at>"+strconv.Itoa(*c.CreatedAfter))
+	}
+	if c.CreatedBefore != nil {
+		clauses = append(clauses, "created_at<"+strconv.Itoa(*c.CreatedBefore))
+	}
+	return strings.Join(clauses, "&")
+}
+
+// Allows reports whether the event's kind and created_at meet the
+// conditions.
+func (c Conditions) Allows(e Event) bool {
+	if len(c.Kinds) > 0 {
+		allowed := false
+		for _, kind := range c.Kinds {
+			if e.Kind == kind {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	if c.CreatedAfter != nil && e.CreatedAt <= *c.CreatedAfter {
+		return false
+	}
+	if c.CreatedBefore != nil && e.CreatedAt >= *c.CreatedBefore {
+		return false
+	}
+	return true
+}
+
+// DelegationToken returns the string a delegator signs to delegate to the
+// delegatee under the conditions.
+func DelegationToken(delegateePubKey, conditions string) string {
+	return "nostr:delegation:" + delegateePubKey + ":" + conditions
+}
+
+// delegationHash returns the hex SHA-256 hash of a delegation token.
+func delegationHash(delegateePubKey, conditions string) string {
+	hash := sha256.Sum256([]byte(DelegationToken(delegateePubKey, conditions)))
+	return hex.EncodeToString(hash[:])
+}
+
+// SignDelegation signs the delegation token with the delegator's private
+// key. Returns the signature as 128 lowercase hex characters.
+func SignDelegation(delegatorPrivateKeyHex, delegateePubKey, conditions string) (string, error) {
+	if !Hex64Pattern.MatchString(delegateePubKey) {
+		return "", errors.MalformedPubKey
+	}
+	if _, err := ParseConditions(conditions); err != nil {
+		return "", err
+	}
+	return SignEvent(delegationHash(delegateePubKey, conditions), delegatorPrivateKeyHex)
+}
+
+// DelegationTag builds the delegation tag for a delegatee's events.
+func DelegationTag(delegatorPubKey, conditions, sig string) Tag {
+	return Tag{DelegationTagName, delegatorPubKey, conditions, sig}
+}
+
+// Delegator returns the public key named by the event's delegation tag, or
+// an empty string if it has none. The delegation is not validated.
+func Delegator(e Event) string {
+	if tag := delegationTag(e); len(tag) >= 2 {
+		return tag[1]
+	}
+	return ""
+}
+
+func delegationTag(e Event) Tag {
+	for _, tag := range e.Tags {
+		if len(tag) >= 1 && tag[0] == DelegationTagName {
+			return tag
+		}
+	}
+	return nil
+}
+
+// ValidateDelegation checks that the event's delegation tag is signed by
+// the delegator for the event's author, and that the event meets its
+// conditions. Events without a delegation tag are valid.
+func ValidateDelegation(e Event) error {
+	tag := delegationTag(e)
+	if tag == nil {
+		return nil
+	}
+	if len(tag) < 4 || !Hex64Pattern.MatchString(tag[1]) || !Hex128Pattern.MatchString(tag[3]) {
+		return fmt.Errorf("%w: tag must contain delegator, conditions and signature", errors.MalformedDelegation)
+	}
+	delegator, conditions, sig := tag[1], tag[2], tag[3]
+
+	parsed, err := ParseConditions(conditions)
+	if err != nil {
+		return err
+	}
+	if err := verifySignature(delegationHash(e.PubKey, conditions), delegator, sig); err != nil {
+		return fmt.Errorf("%w: %w", errors.InvalidDelegation, err)
+	}
+	if !parsed.Allows(e) {
+		return fmt.Errorf("%w: event does not meet conditions %q", errors.InvalidDelegation, conditions)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package events
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// NIP-26 example delegation
+const (
+	delegatorSK    = "ee35e8bb71131c02c1d7e73231daa48e9953d329a4b701f7133c8f46dd21139c"
+	delegatorPK    = "8e0d3d3eb2881ec137a11debe736a9086715a8c8beeeda615780064d68bc25dd"
+	delegateeSK    = "777e4f60b4aa87937e13acc84f7abcc3c93cc035cb4c1e9f7a9086dd78fffce1"
+	delegateePK    = "477318cfb5427b9cfc66a9fa376150c1ddbc62115ae27cef72417eb959691396"
+	delegationCond = "kind=1&created_at>1674834236&created_at<1677426236"
+	delegationSig  = "6f44d7fe4f1c09f3954640fb58bd12bae8bb8ff4120853c4693106c82e920e2b898f1f9ba9bd65449a987c39c0423426ab7b53910c0c6abfb41b30bc16e5f524"
+)
+
+// delegatedEvent returns an event signed by the delegatee with the tag.
+func delegatedEvent(t *testing.T, kind, createdAt int, tag Tag) Event {
+	e := Event{
+		PubKey:    delegateePK,
+		CreatedAt: createdAt,
+		Kind:      kind,
+		Tags:      []Tag{tag},
+		Content:   "delegated",
+	}
+	var err error
+	e.ID, err = GetID(e)
+	assert.NoError(t, err)
+	e.Sig, err = SignEvent(e.ID, delegateeSK)
+	assert.NoError(t, err)
+	return e
+}
+
+type ParseConditionsTestCase struct {
+	name          string
+	input         string
+	expected      Conditions
+	expectedError string
+}
+
+var parseConditionsTestCases = []ParseConditionsTestCase{
+	{"example", delegationCond, Conditions{
+		Kinds:         []int{1},
+		CreatedAfter:  intPtr(1674834236),
+		CreatedBefore: intPtr(1677426236),
+	}, ""},
+	{"several kinds", "kind=1&kind=7", Conditions{Kinds: []int{1, 7}}, ""},
+	{"strictest bounds", "created_at>5&created_at>10&created_at<30&created_at<20", Conditions{
+		CreatedAfter:  intPtr(10),
+		CreatedBefore: intPtr(20),
+	}, ""},
+	{"empty", "", Conditions{}, "malformed delegation: empty conditions"},
+	{"empty clause", "kind=1&", Conditions{}, `malformed delegation: condition ""`},
+	{"unknown field", "author=abc", Conditions{}, `malformed delegation: condition "author=abc"`},
+	{"wrong operator", "kind>1", Conditions{}, `malformed delegation: condition "kind>1"`},
+	{"created_at equals", "created_at=5", Conditions{}, `malformed delegation: condition "created_at=5"`},
+	{"negative", "kind=-1", Conditions{}, `malformed delegation: condition "kind=-1"`},
+	{"not a number", "created_at<soon", Conditions{}, `malformed delegation: condition "created_at<soon"`},
+	{"leading zero", "kind=01", Conditions{}, `malformed delegation: condition "kind=01"`},
+}
+
+func TestParseConditions(t *testing.T) {
+	for _, tc := range parseConditionsTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := ParseConditions(tc.input)
+			if tc.expectedError != "" {
+				assert.ErrorContains(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, c)
+		})
+	}
+}
+
+func TestConditionsString(t *testing.T) {
+	c := Conditions{
+		Kinds:         []int{1},
+		CreatedAfter:  intPtr(1674834236),
+		CreatedBefore: intPtr(1677426236),
+	}
+	assert.Equal(t, delegationCond, c.String())
+	assert.Equal(t, "", Conditions{}.String())
+}
+
+type ConditionsAllowsTestCase struct {
+	name      string
+	kind      int
+	createdAt int
+	expected  bool
+}
+
+var conditionsAllowsTestCases = []ConditionsAllowsTestCase{
+	{"within", 1, 1674834237, true},
+	{"wrong kind", 7, 1674834237, false},
+	{"at lower bound", 1, 1674834236, false},
+	{"at upper bound", 1, 1677426236, false},
+	{"after upper bound", 1, 1677426237, false},
+}
+
+func TestConditionsAllows(t *testing.T) {
+	c, _ := ParseConditions(delegationCond)
+	for _, tc := range conditionsAllowsTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, c.Allows(Event{Kind: tc.kind, CreatedAt: tc.createdAt}))
+		})
+	}
+	assert.True(t, Conditions{}.Allows(Event{Kind: 30023}))
+}
+
+func TestDelegationToken(t *testing.T) {
+	assert.Equal(t,
+		"nostr:delegation:"+delegateePK+":"+delegationCond,
+		DelegationToken(delegateePK, delegationCond))
+}
+
+func TestSignDelegation(t *testing.T) {
+	sig, err := SignDelegation(delegatorSK, delegateePK, delegationCond)
+	assert.NoError(t, err)
+	assert.NoError(t, verifySignature(delegationHash(delegateePK, delegationCond), delegatorPK, sig))
+
+	_, err = SignDelegation(delegatorSK, "abc", delegationCond)
+	assert.ErrorContains(t, err, "public key must be 64 lowercase hex characters")
+	_, err = SignDelegation(delegatorSK, delegateePK, "kind=x")
+	assert.ErrorContains(t, err, "malformed delegation")
+}
+
+func TestDelegationTag(t *testing.T) {
+	tag := DelegationTag(delegatorPK, delegationCond, delegationSig)
+	assert.Equal(t, Tag{"delegation", delegatorPK, delegationCond, delegationSig}, tag)
+
+	e := delegatedEvent(t, 1, 1674834237, tag)
+	assert.Equal(t, delegatorPK, Delegator(e))
+	assert.Equal(t, "", Delegator(testEvent))
+}
+
+type ValidateDelegationTestCase struct {
+	name          string
+	kind          int
+	createdAt     int
+	tag           Tag
+	expectedError string
+}
+
+var validateDelegationTestCases = []ValidateDelegationTestCase{
+	{"valid", 1, 1674834237,
+		Tag{"delegation", delegatorPK, delegationCond, delegationSig}, ""},
+	{"no delegation", 1, 1674834237,
+		Tag{"t", "nostr"}, ""},
+	{"wrong kind", 7, 1674834237,
+		Tag{"delegation", delegatorPK, delegationCond, delegationSig},
+		"delegation is invalid: event does not meet conditions"},
+	{"too late", 1, 1677426236,
+		Tag{"delegation", delegatorPK, delegationCond, delegationSig},
+		"delegation is invalid: event does not meet conditions"},
+	{"altered conditions", 1, 1674834237,
+		Tag{"delegation", delegatorPK, "kind=1", delegationSig},
+		"delegation is invalid: event signature is invalid"},
+	{"other delegator", 1, 1674834237,
+		Tag{"delegation", testEvent.PubKey, delegationCond, delegationSig},
+		"delegation is invalid"},
+	{"short tag", 1, 1674834237,
+		Tag{"delegation", delegatorPK, delegationCond},
+		"malformed delegation"},
+	{"malformed delegator", 1, 1674834237,
+		Tag{"delegation", "abc", delegationCond, delegationSig},
+		"malformed delegation"},
+	{"malformed conditions", 1, 1674834237,
+		Tag{"delegation", delegatorPK, "kind", delegationSig},
+		"malformed delegation"},
+}
+
+func TestValidateDelegation(t *testing.T) {
+	for _, tc := range validateDelegationTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := delegatedEvent(t, tc.kind, tc.createdAt, tc.tag)
+			assert.NoError(t, Validate(e))
+
+			err := ValidateDelegation(e)
+			optErr := ValidateWithOptions(e, ValidateOptions{Delegation: true})
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+				assert.NoError(t, optErr)
+				return
+			}
+			assert.ErrorContains(t, err, tc.expectedError)
+			assert.ErrorContains(t, optErr, tc.expectedError)
+		})
+	}
+}
+
+func TestValidateDelegationForOtherDelegatee(t *testing.T) {
+	// A delegation to the delegatee does not cover other authors
+	e := testEvent
+	e.Tags = []Tag{DelegationTag(delegatorPK, delegationCond, delegationSig)}
+	e.Kind = 1
+	e.CreatedAt = 1674834237
+	err := ValidateDelegation(e)
+	assert.ErrorContains(t, err, "delegation is invalid: event signature is invalid")
+}
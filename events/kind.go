@@ -0,0 +1,27 @@
+package events
+
+// IsRegular reports whether events of the kind are expected to be stored by
+// relays without replacement.
+func IsRegular(kind int) bool {
+	return kind == 1 || kind == 2 ||
+		(kind >= 4 && kind < 45) ||
+		(kind >= 1000 && kind < 10000)
+}
+
+// IsReplaceable reports whether, for each combination of pubkey and kind,
+// only the latest event is expected to be stored.
+func IsReplaceable(kind int) bool {
+	return kind == 0 || kind == 3 || (kind >= 10000 && kind < 20000)
+}
+
+// IsEphemeral reports whether events of the kind are not expected to be
+// stored by relays.
+func IsEphemeral(kind int) bool {
+	return kind >= 20000 && kind < 30000
+}
+
+// IsAddressable reports whether, for each combination of pubkey, kind and
+// "d" tag value, only the latest event is expected to be stored.
+func IsAddressable(kind int) bool {
+	return kind >= 30000 && kind < 40000
+}
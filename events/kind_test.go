@@ -0,0 +1,43 @@
+package events
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type KindTestCase struct {
+	kind        int
+	regular     bool
+	replaceable bool
+	ephemeral   bool
+	addressable bool
+}
+
+var kindTestCases = []KindTestCase{
+	{kind: 0, replaceable: true},
+	{kind: 1, regular: true},
+	{kind: 2, regular: true},
+	{kind: 3, replaceable: true},
+	{kind: 4, regular: true},
+	{kind: 44, regular: true},
+	{kind: 45},
+	{kind: 999},
+	{kind: 1000, regular: true},
+	{kind: 9999, regular: true},
+	{kind: 10000, replaceable: true},
+	{kind: 19999, replaceable: true},
+	{kind: 20000, ephemeral: true},
+	{kind: 29999, ephemeral: true},
+	{kind: 30000, addressable: true},
+	{kind: 39999, addressable: true},
+	{kind: 40000},
+}
+
+func TestKindClassification(t *testing.T) {
+	for _, tc := range kindTestCases {
+		assert.Equal(t, tc.regular, IsRegular(tc.kind), "regular %d", tc.kind)
+		assert.Equal(t, tc.replaceable, IsReplaceable(tc.kind), "replaceable %d", tc.kind)
+		assert.Equal(t, tc.ephemeral, IsEphemeral(tc.kind), "ephemeral %d", tc.kind)
+		assert.Equal(t, tc.addressable, IsAddressable(tc.kind), "addressable %d", tc.kind)
+	}
+}
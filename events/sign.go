@@ -4,13 +4,31 @@ import (
 	"encoding/hex"
 	"fmt"
 	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/keys"
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcec/v2/schnorr"
 )
 
+// SignOptions configures signing. The zero value signs like SignEvent.
+type SignOptions struct {
+	// AuxRand is the BIP-340 auxiliary randomness mixed into the nonce.
+	//
+	// When nil, the nonce is derived from the key and event ID with RFC 6979,
+	// which is deterministic but specific to this library. Set it to fresh
+	// random bytes for the nonce BIP-340 recommends, or to fixed bytes, such
+	// as zeros, to reproduce the signatures of other BIP-340 implementations.
+	AuxRand *[32]byte
+}
+
 // SignEvent generates a Schnorr signature for the given event ID using the
 // provided private key. Returns the signature as 128 lowercase hex characters.
 func SignEvent(eventID, privateKeyHex string) (string, error) {
+	return SignEventWithOptions(eventID, privateKeyHex, SignOptions{})
+}
+
+// SignEventWithOptions generates a Schnorr signature like SignEvent,
+// configured by the options.
+func SignEventWithOptions(eventID, privateKeyHex string, opts SignOptions) (string, error) {
 	skBytes, err := hex.DecodeString(privateKeyHex)
 	if err != nil {
 		return "", errors.MalformedPrivKey
@@ -23,10 +41,40 @@ func SignEvent(eventID, privateKeyHex string) (string, error) {
 
 	// discard public key return value
 	sk, _ := btcec.PrivKeyFromBytes(skBytes)
-	sig, err := schnorr.Sign(sk, idBytes)
+	defer sk.Zero()
+	return sign(sk, idBytes, opts)
+}
+
+// SignEventWithKey generates a Schnorr signature for the given event ID using
+// a private key held in a keys.SecureKey. Returns the signature as 128
+// lowercase hex characters.
+func SignEventWithKey(eventID string, key *keys.SecureKey) (string, error) {
+	idBytes, err := hex.DecodeString(eventID)
 	if err != nil {
-		return "", fmt.Errorf("schnorr signature error: %w", err)
+		return "", errors.MalformedID
 	}
 
+	var sig string
+	err = key.Use(func(k *keys.PrivateKey) error {
+		sk, _ := btcec.PrivKeyFromBytes(k[:])
+		defer sk.Zero()
+		sig, err = sign(sk, idBytes, SignOptions{})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return sig, nil
+}
+
+func sign(sk *btcec.PrivateKey, idBytes []byte, opts SignOptions) (string, error) {
+	var signOpts []schnorr.SignOption
+	if opts.AuxRand != nil {
+		signOpts = append(signOpts, schnorr.CustomNonce(*opts.AuxRand))
+	}
+	sig, err := schnorr.Sign(sk, idBytes, signOpts...)
+	if err != nil {
+		return "", fmt.Errorf("schnorr signature error: %w", err)
+	}
 	return hex.EncodeToString(sig.Serialize()), nil
 }
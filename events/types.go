@@ -0,0 +1,92 @@
+package events
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"git.wisehodl.dev/jay/go-roots/errors"
+)
+
+// EventID is the SHA-256 hash identifying an event.
+type EventID [32]byte
+
+// Signature is a 64-byte Schnorr signature of an event ID.
+type Signature [64]byte
+
+// ParseEventID parses an event ID from 64 lowercase hex characters.
+func ParseEventID(id string) (EventID, error) {
+	var parsed EventID
+	if !Hex64Pattern.MatchString(id) {
+		return EventID{}, errors.MalformedID
+	}
+	hex.Decode(parsed[:], []byte(id))
+	return parsed, nil
+}
+
+// ParseSignature parses a signature from 128 lowercase hex characters.
+func ParseSignature(sig string) (Signature, error) {
+	var parsed Signature
+	if !Hex128Pattern.MatchString(sig) {
+		return Signature{}, errors.MalformedSig
+	}
+	hex.Decode(parsed[:], []byte(sig))
+	return parsed, nil
+}
+
+// String returns the event ID as 64 lowercase hex characters.
+func (id EventID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// Equal reports whether two event IDs are equal, in constant time.
+func (id EventID) Equal(other EventID) bool {
+	return subtle.ConstantTimeCompare(id[:], other[:]) == 1
+}
+
+// MarshalText encodes the event ID as hex.
+func (id EventID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText parses an event ID from hex.
+func (id *EventID) UnmarshalText(text []byte) error {
+	parsed, err := ParseEventID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// String returns the signature as 128 lowercase hex characters.
+func (sig Signature) String() string {
+	return hex.EncodeToString(sig[:])
+}
+
+// Equal reports whether two signatures are equal, in constant time.
+func (sig Signature) Equal(other Signature) bool {
+	return subtle.ConstantTimeCompare(sig[:], other[:]) == 1
+}
+
+// MarshalText encodes the signature as hex.
+func (sig Signature) MarshalText() ([]byte, error) {
+	return []byte(sig.String()), nil
+}
+
+// UnmarshalText parses a signature from hex.
+func (sig *Signature) UnmarshalText(text []byte) error {
+	parsed, err := ParseSignature(string(text))
+	if err != nil {
+		return err
+	}
+	*sig = parsed
+	return nil
+}
+
+// ComputeID computes the event ID.
+func ComputeID(e Event) (EventID, error) {
+	id, err := GetID(e)
+	if err != nil {
+		return EventID{}, err
+	}
+	return ParseEventID(id)
+}
@@ -0,0 +1,73 @@
+package events
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestParseEventID(t *testing.T) {
+	id, err := ParseEventID(testEvent.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, testEvent.ID, id.String())
+
+	for _, input := range []string{testEvent.ID[:62], strings.ToUpper(testEvent.ID), "z" + testEvent.ID[1:]} {
+		_, err := ParseEventID(input)
+		assert.ErrorContains(t, err, "event id must be 64 hex characters")
+	}
+}
+
+func TestParseSignature(t *testing.T) {
+	sig, err := ParseSignature(testEvent.Sig)
+	assert.NoError(t, err)
+	assert.Equal(t, testEvent.Sig, sig.String())
+
+	for _, input := range []string{testEvent.Sig[:126], strings.ToUpper(testEvent.Sig), testEvent.ID} {
+		_, err := ParseSignature(input)
+		assert.ErrorContains(t, err, "event signature must be 128 hex characters")
+	}
+}
+
+func TestComputeID(t *testing.T) {
+	id, err := ComputeID(testEvent)
+	assert.NoError(t, err)
+	expected, _ := ParseEventID(testEvent.ID)
+	assert.True(t, expected.Equal(id))
+
+	other := testEvent
+	other.Content = "other"
+	otherID, err := ComputeID(other)
+	assert.NoError(t, err)
+	assert.False(t, id.Equal(otherID))
+}
+
+func TestSignatureEqual(t *testing.T) {
+	a, _ := ParseSignature(testEvent.Sig)
+	b, _ := ParseSignature(testEvent.Sig)
+	c, _ := ParseSignature(strings.Repeat("0", 128))
+	assert.True(t, a.Equal(b))
+	assert.False(t, a.Equal(c))
+}
+
+func TestTypedJSON(t *testing.T) {
+	type reference struct {
+		ID  EventID   `json:"id"`
+		Sig Signature `json:"sig"`
+	}
+	id, _ := ParseEventID(testEvent.ID)
+	sig, _ := ParseSignature(testEvent.Sig)
+
+	data, err := json.Marshal(reference{id, sig})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":"`+testEvent.ID+`","sig":"`+testEvent.Sig+`"}`, string(data))
+
+	var decoded reference
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, reference{id, sig}, decoded)
+
+	err = json.Unmarshal([]byte(`{"id":"abc"}`), &decoded)
+	assert.ErrorContains(t, err, "event id must be 64 hex characters")
+	err = json.Unmarshal([]byte(`{"sig":"abc"}`), &decoded)
+	assert.ErrorContains(t, err, "event signature must be 128 hex characters")
+}
@@ -10,6 +10,20 @@ import (
 // Validate performs a complete event validation: structure, ID computation,
 // and signature verification. Returns the first error encountered.
 func Validate(e Event) error {
+	return ValidateWithOptions(e, ValidateOptions{})
+}
+
+// ValidateOptions configures optional validation steps. The zero value
+// validates like Validate.
+type ValidateOptions struct {
+	// Delegation also validates the NIP-26 delegation tag of delegated
+	// events with ValidateDelegation.
+	Delegation bool
+}
+
+// ValidateWithOptions validates an event like Validate, plus the optional
+// steps enabled by the options.
+func ValidateWithOptions(e Event, opts ValidateOptions) error {
 	if err := ValidateStructure(e); err != nil {
 		return err
 	}
@@ -18,7 +32,14 @@ func Validate(e Event) error {
 		return err
 	}
 
-	return ValidateSignature(e)
+	if err := ValidateSignature(e); err != nil {
+		return err
+	}
+
+	if opts.Delegation {
+		return ValidateDelegation(e)
+	}
+	return nil
 }
 
 // ValidateStructure checks that all event fields conform to the protocol
@@ -63,17 +84,22 @@ func ValidateID(e Event) error {
 // ValidateSignature verifies the event signature is cryptographically valid
 // for the event ID and public key using Schnorr verification.
 func ValidateSignature(e Event) error {
-	idBytes, err := hex.DecodeString(e.ID)
+	return verifySignature(e.ID, e.PubKey, e.Sig)
+}
+
+// verifySignature verifies a hex signature of a hex hash by a hex public key.
+func verifySignature(hashHex, publicKeyHex, sigHex string) error {
+	idBytes, err := hex.DecodeString(hashHex)
 	if err != nil {
 		return fmt.Errorf("invalid event id hex: %w", err)
 	}
 
-	sigBytes, err := hex.DecodeString(e.Sig)
+	sigBytes, err := hex.DecodeString(sigHex)
 	if err != nil {
 		return fmt.Errorf("invalid event signature hex: %w", err)
 	}
 
-	pkBytes, err := hex.DecodeString(e.PubKey)
+	pkBytes, err := hex.DecodeString(publicKeyHex)
 	if err != nil {
 		return fmt.Errorf("invalid public key hex: %w", err)
 	}
@@ -0,0 +1,226 @@
+// Package search implements the NIP-50 search filter extension as a local,
+// dependency-free matcher and relevance ranker.
+package search
+
+import (
+	"encoding/json"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ExtensionKey is the filter extension field that carries the search string.
+const ExtensionKey = "search"
+
+// Query is a parsed NIP-50 search string. Words of the form "key:value" with
+// a key defined by NIP-50 become Extensions, and other words become Terms.
+type Query struct {
+	Terms      []string
+	Extensions map[string]string
+}
+
+// Options controls which parts of an event are searched and how spam is
+// recognized. The zero value searches content only and treats no event as spam.
+type Options struct {
+	// Tags lists the tag names whose values are searched alongside content.
+	Tags []string
+
+	// Spam reports whether an event is spam. Spam events are excluded
+	// unless the query contains "include:spam".
+	Spam func(events.Event) bool
+}
+
+// Tag values are weighted lower than content when scoring.
+const tagWeight = 0.5
+
+// Parse splits a search string into terms and "key:value" extensions.
+// Only the NIP-50 keys language, include, nsfw, domain and sentiment are
+// extensions, so words such as URLs remain terms. Terms are tokenized the
+// same way as searched text. Later extensions override earlier ones with
+// the same key.
+func Parse(s string) Query {
+	q := Query{}
+	for _, word := range strings.Fields(s) {
+		key, value, found := strings.Cut(word, ":")
+		if found && value != "" && isExtensionKey(key) {
+			if q.Extensions == nil {
+				q.Extensions = make(map[string]string)
+			}
+			q.Extensions[strings.ToLower(key)] = value
+			continue
+		}
+		q.Terms = append(q.Terms, Tokenize(word)...)
+	}
+	return q
+}
+
+// FromFilter extracts and parses the search extension of a filter. The
+// boolean result is false when the filter has no search extension.
+func FromFilter(f filters.Filter) (Query, bool, error) {
+	raw, ok := f.Extensions[ExtensionKey]
+	if !ok {
+		return Query{}, false, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return Query{}, false, errors.MalformedSearch
+	}
+	return Parse(s), true, nil
+}
+
+// Tokenize lowercases text and splits it into runs of letters and digits.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Score returns the relevance of an event to the query. Every term must
+// appear in the searched text and every supported extension must be
+// satisfied; otherwise the score is zero. Unsupported extensions are
+// ignored. A query without terms scores 1 for any event that satisfies its
+// extensions.
+func Score(q Query, e events.Event, opts Options) float64 {
+	if !matchesExtensions(q, e, opts) {
+		return 0
+	}
+	if len(q.Terms) == 0 {
+		return 1
+	}
+
+	frequencies := make(map[string]float64)
+	length := 0
+	for _, token := range Tokenize(e.Content) {
+		frequencies[token]++
+		length++
+	}
+	for _, tag := range e.Tags {
+		if len(tag) < 2 || !containsString(opts.Tags, tag[0]) {
+			continue
+		}
+		for _, value := range tag[1:] {
+			for _, token := range Tokenize(value) {
+				frequencies[token] += tagWeight
+				length++
+			}
+		}
+	}
+
+	// Sum log-scaled term frequencies, damped by document length so that
+	// short, focused events outrank long ones that mention a term in passing.
+	score := 0.0
+	for _, term := range q.Terms {
+		tf := frequencies[term]
+		if tf == 0 {
+			return 0
+		}
+		score += 1 + math.Log(1+tf)
+	}
+	return score / (1 + math.Log(1+float64(length)))
+}
+
+// Matches returns true if the event satisfies the filter, including its
+// search extension when present. A malformed search extension never matches.
+func Matches(f filters.Filter, e events.Event, opts Options) bool {
+	if !filters.Matches(f, e) {
+		return false
+	}
+	q, ok, err := FromFilter(f)
+	if err != nil {
+		return false
+	}
+	if !ok {
+		return true
+	}
+	return Score(q, e, opts) > 0
+}
+
+// Rank returns the events that match the query, ordered by descending
+// score. Ties are ordered newest first and then by lowest ID, matching the
+// NIP-01 ordering of query results. The input slice is not modified.
+func Rank(q Query, evs []events.Event, opts Options) []events.Event {
+	type scored struct {
+		event events.Event
+		score float64
+	}
+
+	results := []scored{}
+	for _, e := range evs {
+		if s := Score(q, e, opts); s > 0 {
+			results = append(results, scored{e, s})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if a.event.CreatedAt != b.event.CreatedAt {
+			return a.event.CreatedAt > b.event.CreatedAt
+		}
+		return a.event.ID < b.event.ID
+	})
+
+	ranked := make([]events.Event, len(results))
+	for i, r := range results {
+		ranked[i] = r.event
+	}
+	return ranked
+}
+
+func matchesExtensions(q Query, e events.Event, opts Options) bool {
+	if opts.Spam != nil && q.Extensions["include"] != "spam" && opts.Spam(e) {
+		return false
+	}
+
+	if language, ok := q.Extensions["language"]; ok {
+		if !hasLanguage(e.Tags, strings.ToLower(language)) {
+			return false
+		}
+	}
+
+	if q.Extensions["nsfw"] == "false" {
+		for _, tag := range e.Tags {
+			if len(tag) > 0 && tag[0] == "content-warning" {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// hasLanguage reports whether the tags carry a NIP-32 ISO-639-1 language
+// label with the given code.
+func hasLanguage(tags []events.Tag, code string) bool {
+	for _, tag := range tags {
+		if len(tag) < 3 || tag[0] != "l" || tag[2] != "ISO-639-1" {
+			continue
+		}
+		if strings.ToLower(tag[1]) == code {
+			return true
+		}
+	}
+	return false
+}
+
+// extensionKeys are the search extensions defined by NIP-50.
+var extensionKeys = []string{"language", "include", "nsfw", "domain", "sentiment"}
+
+func isExtensionKey(key string) bool {
+	return containsString(extensionKeys, strings.ToLower(key))
+}
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,308 @@
+package search
+
+import (
+	"encoding/json"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var searchEvents = []events.Event{
+	{
+		ID:        "01",
+		CreatedAt: 1000,
+		Kind:      1,
+		Tags:      []events.Tag{{"t", "bitcoin"}},
+		Content:   "Bitcoin fixes this.",
+	},
+	{
+		ID:        "02",
+		CreatedAt: 2000,
+		Kind:      1,
+		Tags:      []events.Tag{{"l", "de", "ISO-639-1"}},
+		Content:   "Ein langer Beitrag über Nostr, Relays und auch ein wenig Bitcoin.",
+	},
+	{
+		ID:        "03",
+		CreatedAt: 3000,
+		Kind:      1,
+		Tags:      []events.Tag{{"l", "en", "ISO-639-1"}},
+		Content:   "nostr nostr nostr",
+	},
+	{
+		ID:        "04",
+		CreatedAt: 4000,
+		Kind:      1,
+		Tags:      []events.Tag{{"content-warning", "nsfw"}},
+		Content:   "Nostr after dark",
+	},
+	{
+		ID:        "05",
+		CreatedAt: 5000,
+		Kind:      7,
+		Tags:      []events.Tag{{"t", "nostr"}},
+		Content:   "+",
+	},
+}
+
+type ParseTestCase struct {
+	name     string
+	input    string
+	expected Query
+}
+
+var parseTestCases = []ParseTestCase{
+	{
+		name:     "empty",
+		input:    "",
+		expected: Query{},
+	},
+
+	{
+		name:     "terms are tokenized",
+		input:    "Hello, World!",
+		expected: Query{Terms: []string{"hello", "world"}},
+	},
+
+	{
+		name:  "extensions",
+		input: "nostr language:en include:spam",
+		expected: Query{
+			Terms:      []string{"nostr"},
+			Extensions: map[string]string{"language": "en", "include": "spam"},
+		},
+	},
+
+	{
+		name:  "extension keys are case-insensitive",
+		input: "Language:en",
+		expected: Query{
+			Extensions: map[string]string{"language": "en"},
+		},
+	},
+
+	{
+		name:     "empty extension value is a term",
+		input:    "language:",
+		expected: Query{Terms: []string{"language"}},
+	},
+
+	{
+		name:     "numeric key is a term",
+		input:    "12:30",
+		expected: Query{Terms: []string{"12", "30"}},
+	},
+
+	{
+		name:     "url is a term",
+		input:    "https://example.com",
+		expected: Query{Terms: []string{"https", "example", "com"}},
+	},
+
+	{
+		name:  "unknown key is a term",
+		input: "bitcoin:rocks domain:example.com",
+		expected: Query{
+			Terms:      []string{"bitcoin", "rocks"},
+			Extensions: map[string]string{"domain": "example.com"},
+		},
+	},
+}
+
+func TestParse(t *testing.T) {
+	for _, tc := range parseTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Parse(tc.input))
+		})
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t,
+		[]string{"über", "nostr", "2024", "日本語"},
+		Tokenize("Über-Nostr (2024) 日本語!"))
+}
+
+func TestFromFilter(t *testing.T) {
+	f := filters.Filter{
+		Extensions: filters.FilterExtensions{
+			"search": json.RawMessage(`"bitcoin language:en"`),
+		},
+	}
+	q, ok, err := FromFilter(f)
+
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"bitcoin"}, q.Terms)
+	assert.Equal(t, "en", q.Extensions["language"])
+}
+
+func TestFromFilterWithoutSearch(t *testing.T) {
+	_, ok, err := FromFilter(filters.Filter{})
+
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestFromFilterMalformedSearch(t *testing.T) {
+	f := filters.Filter{
+		Extensions: filters.FilterExtensions{
+			"search": json.RawMessage(`["bitcoin"]`),
+		},
+	}
+	_, _, err := FromFilter(f)
+
+	assert.ErrorContains(t, err, "search must be a string")
+}
+
+type RankTestCase struct {
+	name        string
+	query       string
+	options     Options
+	expectedIDs []string
+}
+
+var rankTestCases = []RankTestCase{
+	{
+		name:        "single term",
+		query:       "bitcoin",
+		expectedIDs: []string{"01", "02"},
+	},
+
+	{
+		name:        "repeated term ranks higher",
+		query:       "nostr",
+		expectedIDs: []string{"03", "04", "02"},
+	},
+
+	{
+		name:        "all terms required",
+		query:       "nostr bitcoin",
+		expectedIDs: []string{"02"},
+	},
+
+	{
+		name:        "no match",
+		query:       "ethereum",
+		expectedIDs: []string{},
+	},
+
+	{
+		name:        "tags searched when requested",
+		query:       "nostr",
+		options:     Options{Tags: []string{"t"}},
+		expectedIDs: []string{"03", "05", "04", "02"},
+	},
+
+	{
+		name:        "language extension",
+		query:       "nostr language:en",
+		expectedIDs: []string{"03"},
+	},
+
+	{
+		name:        "nsfw extension",
+		query:       "nostr nsfw:false",
+		expectedIDs: []string{"03", "02"},
+	},
+
+	{
+		name:        "unsupported extension ignored",
+		query:       "bitcoin domain:example.com",
+		expectedIDs: []string{"01", "02"},
+	},
+
+	{
+		name:        "extensions only",
+		query:       "language:de",
+		expectedIDs: []string{"02"},
+	},
+
+	{
+		name:  "spam excluded",
+		query: "nostr",
+		options: Options{
+			Spam: func(e events.Event) bool { return e.ID == "03" },
+		},
+		expectedIDs: []string{"04", "02"},
+	},
+
+	{
+		name:  "spam included",
+		query: "nostr include:spam",
+		options: Options{
+			Spam: func(e events.Event) bool { return e.ID == "03" },
+		},
+		expectedIDs: []string{"03", "04", "02"},
+	},
+}
+
+func TestRank(t *testing.T) {
+	for _, tc := range rankTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actualIDs := []string{}
+			for _, e := range Rank(Parse(tc.query), searchEvents, tc.options) {
+				actualIDs = append(actualIDs, e.ID)
+			}
+
+			assert.Equal(t, tc.expectedIDs, actualIDs)
+		})
+	}
+}
+
+func TestRankTiesNewestFirst(t *testing.T) {
+	evs := []events.Event{
+		{ID: "b", CreatedAt: 1, Content: "nostr"},
+		{ID: "c", CreatedAt: 2, Content: "nostr"},
+		{ID: "a", CreatedAt: 1, Content: "nostr"},
+	}
+	actualIDs := []string{}
+	for _, e := range Rank(Parse("nostr"), evs, Options{}) {
+		actualIDs = append(actualIDs, e.ID)
+	}
+
+	assert.Equal(t, []string{"c", "a", "b"}, actualIDs)
+}
+
+func TestMatches(t *testing.T) {
+	f := filters.Filter{
+		Kinds: []int{1},
+		Extensions: filters.FilterExtensions{
+			"search": json.RawMessage(`"nostr"`),
+		},
+	}
+	opts := Options{Tags: []string{"t"}}
+
+	assert.True(t, Matches(f, searchEvents[2], opts))
+	assert.False(t, Matches(f, searchEvents[0], opts))
+	// kind 7 matches search through its tag but not the filter's kinds
+	assert.False(t, Matches(f, searchEvents[4], opts))
+}
+
+func TestMatchesWithoutSearch(t *testing.T) {
+	f := filters.Filter{Kinds: []int{1}}
+	assert.True(t, Matches(f, searchEvents[0], Options{}))
+}
+
+func TestMatchesMalformedSearch(t *testing.T) {
+	f := filters.Filter{
+		Extensions: filters.FilterExtensions{
+			"search": json.RawMessage(`42`),
+		},
+	}
+	assert.False(t, Matches(f, searchEvents[0], Options{}))
+}
+
+func TestMatchesURL(t *testing.T) {
+	f := filters.Filter{
+		Extensions: filters.FilterExtensions{
+			"search": json.RawMessage(`"https://example.com"`),
+		},
+	}
+	linked := events.Event{Kind: 1, Content: "read https://example.com/post", Tags: []events.Tag{}}
+	other := events.Event{Kind: 1, Content: "read https://other.org", Tags: []events.Tag{}}
+
+	assert.True(t, Matches(f, linked, Options{}))
+	assert.False(t, Matches(f, other, Options{}))
+}
@@ -0,0 +1,226 @@
+// Package sqlfilter compiles subscription filters to parameterized SQL for a
+// reference schema of events and tags tables.
+//
+// The generated SQL is equivalent to filters.Matches: ID and author
+// prefixes are compared exactly (never with case-insensitive LIKE), tag
+// conditions test the first value of each tag, and filter extensions are
+// ignored.
+package sqlfilter
+
+import (
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Dialect selects the placeholder syntax and schema types of the output.
+type Dialect int
+
+const (
+	// SQLite uses "?" placeholders.
+	SQLite Dialect = iota
+
+	// PostgreSQL uses "$1", "$2", ... placeholders.
+	PostgreSQL
+)
+
+// SQLiteSchema is the reference schema in the SQLite dialect.
+// Tags holds one row per event tag with at least two elements, storing the
+// tag name and its first value. Events.tags stores the full tag array as JSON.
+const SQLiteSchema = `CREATE TABLE events (
+	id TEXT PRIMARY KEY,
+	pubkey TEXT NOT NULL,
+	created_at INTEGER NOT NULL,
+	kind INTEGER NOT NULL,
+	tags TEXT NOT NULL,
+	content TEXT NOT NULL,
+	sig TEXT NOT NULL
+);
+CREATE TABLE tags (
+	event_id TEXT NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	value TEXT NOT NULL
+);
+CREATE INDEX events_pubkey ON events (pubkey);
+CREATE INDEX events_kind ON events (kind);
+CREATE INDEX events_created_at ON events (created_at);
+CREATE INDEX tags_name_value ON tags (name, value);
+`
+
+// PostgreSQLSchema is the reference schema in the PostgreSQL dialect.
+const PostgreSQLSchema = `CREATE TABLE events (
+	id TEXT PRIMARY KEY,
+	pubkey TEXT NOT NULL,
+	created_at BIGINT NOT NULL,
+	kind INTEGER NOT NULL,
+	tags JSONB NOT NULL,
+	content TEXT NOT NULL,
+	sig TEXT NOT NULL
+);
+CREATE TABLE tags (
+	event_id TEXT NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	value TEXT NOT NULL
+);
+CREATE INDEX events_pubkey ON events (pubkey);
+CREATE INDEX events_kind ON events (kind);
+CREATE INDEX events_created_at ON events (created_at);
+CREATE INDEX tags_name_value ON tags (name, value);
+`
+
+// Columns lists the events table columns in Event field order, as selected
+// by Select.
+const Columns = "id, pubkey, created_at, kind, tags, content, sig"
+
+// Order is the result ordering applied by Select: newest first, then lowest ID.
+const Order = "created_at DESC, id ASC"
+
+// Schema returns the reference schema for the dialect.
+func Schema(d Dialect) (string, error) {
+	switch d {
+	case SQLite:
+		return SQLiteSchema, nil
+	case PostgreSQL:
+		return PostgreSQLSchema, nil
+	default:
+		return "", errors.UnsupportedDialect
+	}
+}
+
+// Where compiles the filter to a boolean SQL expression over the events
+// table, suitable for use after "WHERE". Returns "TRUE" for a filter
+// without conditions. Limit is not part of the expression.
+func Where(f filters.Filter, d Dialect) (string, []interface{}, error) {
+	if d != SQLite && d != PostgreSQL {
+		return "", nil, errors.UnsupportedDialect
+	}
+	c := compiler{dialect: d}
+	return c.where(f), c.args, nil
+}
+
+// Select compiles the filter to a complete query returning Columns from the
+// events table, ordered by Order and limited by the filter's Limit.
+func Select(f filters.Filter, d Dialect) (string, []interface{}, error) {
+	if d != SQLite && d != PostgreSQL {
+		return "", nil, errors.UnsupportedDialect
+	}
+	c := compiler{dialect: d}
+	where := c.where(f)
+
+	query := "SELECT " + Columns + " FROM events WHERE " + where +
+		" ORDER BY " + Order
+	if f.Limit != nil {
+		query += " LIMIT " + c.bind(*f.Limit)
+	}
+	return query, c.args, nil
+}
+
+// TagRows returns the tags table rows for an event as name/value pairs, in
+// tag order. Tags with fewer than two elements are skipped.
+func TagRows(e events.Event) [][2]string {
+	rows := [][2]string{}
+	for _, tag := range e.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		rows = append(rows, [2]string{tag[0], tag[1]})
+	}
+	return rows
+}
+
+type compiler struct {
+	dialect Dialect
+	args    []interface{}
+}
+
+func (c *compiler) bind(value interface{}) string {
+	c.args = append(c.args, value)
+	if c.dialect == PostgreSQL {
+		return fmt.Sprintf("$%d", len(c.args))
+	}
+	return "?"
+}
+
+func (c *compiler) bindList(values []interface{}) string {
+	placeholders := make([]string, len(values))
+	for i, v := range values {
+		placeholders[i] = c.bind(v)
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+func (c *compiler) where(f filters.Filter) string {
+	conditions := []string{}
+
+	if len(f.IDs) > 0 {
+		conditions = append(conditions, c.prefixes("id", f.IDs))
+	}
+
+	if len(f.Authors) > 0 {
+		conditions = append(conditions, c.prefixes("pubkey", f.Authors))
+	}
+
+	if len(f.Kinds) > 0 {
+		kinds := make([]interface{}, len(f.Kinds))
+		for i, k := range f.Kinds {
+			kinds[i] = k
+		}
+		conditions = append(conditions, "kind IN ("+c.bindList(kinds)+")")
+	}
+
+	if f.Since != nil {
+		conditions = append(conditions, "created_at >= "+c.bind(*f.Since))
+	}
+
+	if f.Until != nil {
+		conditions = append(conditions, "created_at <= "+c.bind(*f.Until))
+	}
+
+	// Sort tag names so output is deterministic
+	tagNames := make([]string, 0, len(f.Tags))
+	for name, values := range f.Tags {
+		// Empty tag filters match all events
+		if len(values) > 0 {
+			tagNames = append(tagNames, name)
+		}
+	}
+	sort.Strings(tagNames)
+
+	for _, name := range tagNames {
+		values := make([]interface{}, len(f.Tags[name]))
+		for i, v := range f.Tags[name] {
+			values[i] = v
+		}
+		conditions = append(conditions,
+			"EXISTS (SELECT 1 FROM tags WHERE tags.event_id = events.id"+
+				" AND tags.name = "+c.bind(name)+
+				" AND tags.value IN ("+c.bindList(values)+"))")
+	}
+
+	if len(conditions) == 0 {
+		return "TRUE"
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// prefixes compiles an OR of exact prefix comparisons. Full 64-character
+// values compile to equality so that primary key and column indexes apply.
+func (c *compiler) prefixes(column string, prefixes []string) string {
+	terms := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		if events.Hex64Pattern.MatchString(prefix) {
+			terms[i] = column + " = " + c.bind(prefix)
+			continue
+		}
+		length := utf8.RuneCountInString(prefix)
+		terms[i] = fmt.Sprintf("substr(%s, 1, %d) = %s", column, length, c.bind(prefix))
+	}
+	if len(terms) == 1 {
+		return terms[0]
+	}
+	return "(" + strings.Join(terms, " OR ") + ")"
+}
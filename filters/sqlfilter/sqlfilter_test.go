@@ -0,0 +1,362 @@
+package sqlfilter
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	_ "modernc.org/sqlite"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+var testEvents []events.Event
+
+func init() {
+	data, err := os.ReadFile("../testdata/test_events.json")
+	if err != nil {
+		panic(err)
+	}
+	if err := json.Unmarshal(data, &testEvents); err != nil {
+		panic(err)
+	}
+}
+
+type CompileTestCase struct {
+	name   string
+	filter filters.Filter
+}
+
+var compileTestCases = []CompileTestCase{
+	{
+		name:   "empty",
+		filter: filters.Filter{},
+	},
+
+	{
+		name:   "empty_lists",
+		filter: filters.Filter{IDs: []string{}, Authors: []string{}, Kinds: []int{}},
+	},
+
+	{
+		name:   "id_prefix",
+		filter: filters.Filter{IDs: []string{"e751d41f"}},
+	},
+
+	{
+		name: "full_ids",
+		filter: filters.Filter{IDs: []string{
+			"e67fa7b84df6b0bb4c57f8719149de77f58955d7849da1be10b2267c72daad8b",
+			"562bc378",
+		}},
+	},
+
+	{
+		name:   "authors",
+		filter: filters.Filter{Authors: []string{"d877e187", "9e4b726a"}},
+	},
+
+	{
+		name:   "kinds",
+		filter: filters.Filter{Kinds: []int{0, 2}},
+	},
+
+	{
+		name:   "time_range",
+		filter: filters.Filter{Since: intPtr(2000), Until: intPtr(7000)},
+	},
+
+	{
+		name: "tags",
+		filter: filters.Filter{Tags: filters.TagFilters{
+			"p":     {"91cf9b32f3735070f46c0a86a820a47efa08a5be6c9f4f8cf68e5b5b75c92d60"},
+			"e":     {"5c83da77af1dec6d7289834998ad7aafbd9e2191396d75ec3cc27f5a77226f36", "ae3f2a91"},
+			"empty": {},
+		}},
+	},
+
+	{
+		name:   "limit",
+		filter: filters.Filter{Kinds: []int{1}, Limit: intPtr(2)},
+	},
+
+	{
+		name: "combined",
+		filter: filters.Filter{
+			Authors: []string{"e719e8f8"},
+			Kinds:   []int{0},
+			Since:   intPtr(5000),
+			Until:   intPtr(10000),
+			Limit:   intPtr(10),
+			Tags:    filters.TagFilters{"power": {"fire"}},
+			Extensions: filters.FilterExtensions{
+				"search": json.RawMessage(`"ignored"`),
+			},
+		},
+	},
+}
+
+var dialects = map[string]Dialect{
+	"sqlite":   SQLite,
+	"postgres": PostgreSQL,
+}
+
+func TestSelectGolden(t *testing.T) {
+	for _, tc := range compileTestCases {
+		for dialectName, dialect := range dialects {
+			t.Run(tc.name+"/"+dialectName, func(t *testing.T) {
+				query, args, err := Select(tc.filter, dialect)
+				assert.NoError(t, err)
+
+				argsJSON, err := json.Marshal(args)
+				assert.NoError(t, err)
+				actual := query + "\n" + string(argsJSON) + "\n"
+
+				path := filepath.Join("testdata", tc.name+"."+dialectName+".golden")
+				if *update {
+					assert.NoError(t, os.WriteFile(path, []byte(actual), 0o644))
+				}
+				expected, err := os.ReadFile(path)
+				assert.NoError(t, err)
+				assert.Equal(t, string(expected), actual)
+			})
+		}
+	}
+}
+
+func TestWhereEmptyFilter(t *testing.T) {
+	where, args, err := Where(filters.Filter{}, SQLite)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "TRUE", where)
+	assert.Empty(t, args)
+}
+
+func TestUnsupportedDialect(t *testing.T) {
+	_, _, err := Where(filters.Filter{}, Dialect(99))
+	assert.ErrorContains(t, err, "unsupported sql dialect")
+
+	_, _, err = Select(filters.Filter{}, Dialect(99))
+	assert.ErrorContains(t, err, "unsupported sql dialect")
+
+	_, err = Schema(Dialect(99))
+	assert.ErrorContains(t, err, "unsupported sql dialect")
+}
+
+func TestTagRows(t *testing.T) {
+	e := events.Event{Tags: []events.Tag{
+		{"malformed"},
+		{"e", "abc", "wss://relay"},
+		{"t", "nostr"},
+	}}
+	assert.Equal(t, [][2]string{{"e", "abc"}, {"t", "nostr"}}, TagRows(e))
+}
+
+// Equivalence
+
+func openTestDB(t *testing.T, corpus []events.Event) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each connection to :memory: is a separate database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(SQLiteSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range corpus {
+		tagsJSON, err := json.Marshal(e.Tags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = db.Exec(
+			"INSERT INTO events ("+Columns+") VALUES (?, ?, ?, ?, ?, ?, ?)",
+			e.ID, e.PubKey, e.CreatedAt, e.Kind, string(tagsJSON), e.Content, e.Sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range TagRows(e) {
+			_, err = db.Exec(
+				"INSERT INTO tags (event_id, name, value) VALUES (?, ?, ?)",
+				e.ID, row[0], row[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return db
+}
+
+func querySQL(t *testing.T, db *sql.DB, f filters.Filter) []string {
+	query, args, err := Select(f, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var e events.Event
+		var tagsJSON string
+		err := rows.Scan(&e.ID, &e.PubKey, &e.CreatedAt, &e.Kind, &tagsJSON, &e.Content, &e.Sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, e.ID)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+// queryReference scans the corpus with filters.Matches, newest first.
+func queryReference(corpus []events.Event, f filters.Filter) []string {
+	matched := []events.Event{}
+	for _, e := range corpus {
+		if filters.Matches(f, e) {
+			matched = append(matched, e)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].CreatedAt != matched[j].CreatedAt {
+			return matched[i].CreatedAt > matched[j].CreatedAt
+		}
+		return matched[i].ID < matched[j].ID
+	})
+	if f.Limit != nil && *f.Limit >= 0 && *f.Limit < len(matched) {
+		matched = matched[:*f.Limit]
+	}
+
+	ids := make([]string, len(matched))
+	for i, e := range matched {
+		ids[i] = e.ID
+	}
+	return ids
+}
+
+// generateCorpus builds events with colliding timestamps, shared authors and
+// a small tag vocabulary so that generated filters select interesting subsets.
+func generateCorpus(r *rand.Rand, n int) []events.Event {
+	authors := []string{randomHex(r, 64), randomHex(r, 64), randomHex(r, 64)}
+	tagNames := []string{"e", "p", "t", "emoji"}
+	tagValues := []string{"nostr", "Nostr", "100%", "a_b", "🌊", randomHex(r, 64)}
+
+	corpus := make([]events.Event, n)
+	for i := range corpus {
+		tags := []events.Tag{}
+		for j := r.Intn(4); j > 0; j-- {
+			tag := events.Tag{tagNames[r.Intn(len(tagNames))], tagValues[r.Intn(len(tagValues))]}
+			if r.Intn(4) == 0 {
+				tag = append(tag, tagValues[r.Intn(len(tagValues))])
+			}
+			tags = append(tags, tag)
+		}
+		if r.Intn(8) == 0 {
+			tags = append(tags, events.Tag{"malformed"})
+		}
+		corpus[i] = events.Event{
+			ID:        randomHex(r, 64),
+			PubKey:    authors[r.Intn(len(authors))],
+			CreatedAt: 1000 + r.Intn(20)*100,
+			Kind:      r.Intn(4),
+			Tags:      tags,
+			Content:   fmt.Sprintf("event %d", i),
+			Sig:       randomHex(r, 128),
+		}
+	}
+	return corpus
+}
+
+func generateFilter(r *rand.Rand, corpus []events.Event) filters.Filter {
+	f := filters.Filter{}
+	pick := func() events.Event { return corpus[r.Intn(len(corpus))] }
+	prefix := func(s string) string { return s[:r.Intn(len(s)+1)] }
+
+	if r.Intn(3) == 0 {
+		f.IDs = []string{}
+		for j := r.Intn(3); j > 0; j-- {
+			f.IDs = append(f.IDs, prefix(pick().ID))
+		}
+	}
+	if r.Intn(3) == 0 {
+		f.Authors = []string{prefix(pick().PubKey)}
+		if r.Intn(4) == 0 {
+			// Upper-case prefixes never match
+			f.Authors = append(f.Authors, "D877E187")
+		}
+	}
+	if r.Intn(3) == 0 {
+		f.Kinds = []int{r.Intn(4), r.Intn(4)}
+	}
+	if r.Intn(3) == 0 {
+		f.Since = intPtr(1000 + r.Intn(20)*100)
+	}
+	if r.Intn(3) == 0 {
+		f.Until = intPtr(1000 + r.Intn(20)*100)
+	}
+	if r.Intn(3) == 0 {
+		f.Limit = intPtr(r.Intn(6))
+	}
+	if r.Intn(2) == 0 {
+		f.Tags = filters.TagFilters{}
+		for j := r.Intn(3); j > 0; j-- {
+			e := pick()
+			if len(e.Tags) == 0 || len(e.Tags[0]) < 2 {
+				f.Tags["t"] = []string{"nostr", "%"}
+				continue
+			}
+			f.Tags[e.Tags[0][0]] = append(f.Tags[e.Tags[0][0]], e.Tags[0][1])
+		}
+	}
+	return f
+}
+
+func randomHex(r *rand.Rand, n int) string {
+	const digits = "0123456789abcdef"
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = digits[r.Intn(len(digits))]
+	}
+	return string(b)
+}
+
+func TestSQLiteEquivalence(t *testing.T) {
+	db := openTestDB(t, testEvents)
+	for _, tc := range compileTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, queryReference(testEvents, tc.filter), querySQL(t, db, tc.filter))
+		})
+	}
+}
+
+func TestSQLiteEquivalenceGenerated(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	corpus := generateCorpus(r, 200)
+	db := openTestDB(t, corpus)
+
+	for i := 0; i < 500; i++ {
+		f := generateFilter(r, corpus)
+		expected := queryReference(corpus, f)
+		actual := querySQL(t, db, f)
+		if !assert.Equal(t, expected, actual) {
+			filterJSON, _ := filters.MarshalJSON(f)
+			t.Fatalf("filter %s", filterJSON)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+package sqlfilter
+
+func intPtr(i int) *int {
+	return &i
+}
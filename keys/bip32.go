@@ -0,0 +1,118 @@
+package keys
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"strconv"
+	"strings"
+)
+
+// HardenedOffset is added to a child index to derive a hardened child.
+const HardenedOffset uint32 = 0x80000000
+
+// extendedKey is a BIP-32 private key with its chain code.
+type extendedKey struct {
+	key       secp256k1.ModNScalar
+	chainCode []byte
+}
+
+// DerivePrivateKey derives the NIP-06 private key for an account from a
+// BIP-39 seed, along m/44'/1237'/<account>'/0/0.
+func DerivePrivateKey(seed []byte, account uint32) (string, error) {
+	return DerivePath(seed, fmt.Sprintf("m/44'/1237'/%d'/0/0", account))
+}
+
+// DerivePath derives the private key at a BIP-32 path, such as
+// "m/44'/1237'/0'/0/0", from a seed of 16 to 64 bytes. Hardened indexes are
+// marked with ' or h.
+func DerivePath(seed []byte, path string) (string, error) {
+	indexes, err := parsePath(path)
+	if err != nil {
+		return "", err
+	}
+	k, err := masterKey(seed)
+	if err != nil {
+		return "", err
+	}
+	for _, index := range indexes {
+		if k, err = k.child(index); err != nil {
+			return "", err
+		}
+	}
+	b := k.key.Bytes()
+	return hex.EncodeToString(b[:]), nil
+}
+
+func parsePath(path string) ([]uint32, error) {
+	segments := strings.Split(path, "/")
+	if segments[0] != "m" {
+		return nil, fmt.Errorf("%w: %q", errors.MalformedDerivationPath, path)
+	}
+
+	indexes := make([]uint32, 0, len(segments)-1)
+	for _, s := range segments[1:] {
+		hardened := strings.HasSuffix(s, "'") || strings.HasSuffix(s, "h")
+		if hardened {
+			s = s[:len(s)-1]
+		}
+		n, err := strconv.ParseUint(s, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", errors.MalformedDerivationPath, path)
+		}
+		index := uint32(n)
+		if hardened {
+			index += HardenedOffset
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+func masterKey(seed []byte) (extendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return extendedKey{}, fmt.Errorf("%w: %d bytes", errors.InvalidSeed, len(seed))
+	}
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	var k extendedKey
+	if overflow := k.key.SetByteSlice(sum[:32]); overflow || k.key.IsZero() {
+		return extendedKey{}, errors.InvalidSeed
+	}
+	k.chainCode = sum[32:]
+	return k, nil
+}
+
+// child derives a child private key. The vanishingly rare indexes that
+// produce no valid key are reported as errors.
+func (k extendedKey) child(index uint32) (extendedKey, error) {
+	mac := hmac.New(sha512.New, k.chainCode)
+	if index >= HardenedOffset {
+		b := k.key.Bytes()
+		mac.Write([]byte{0})
+		mac.Write(b[:])
+	} else {
+		mac.Write(secp256k1.NewPrivateKey(&k.key).PubKey().SerializeCompressed())
+	}
+	var i [4]byte
+	binary.BigEndian.PutUint32(i[:], index)
+	mac.Write(i[:])
+	sum := mac.Sum(nil)
+
+	var child extendedKey
+	if overflow := child.key.SetByteSlice(sum[:32]); overflow {
+		return extendedKey{}, fmt.Errorf("%w: index %d has no key", errors.InvalidSeed, index)
+	}
+	child.key.Add(&k.key)
+	if child.key.IsZero() {
+		return extendedKey{}, fmt.Errorf("%w: index %d has no key", errors.InvalidSeed, index)
+	}
+	child.chainCode = sum[32:]
+	return child, nil
+}
@@ -0,0 +1,73 @@
+package keys
+
+import (
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type DerivePathTestCase struct {
+	path       string
+	privateKey string
+}
+
+// BIP-32 test vector 1
+var bip32Seed1 = "000102030405060708090a0b0c0d0e0f"
+
+var derivePathTestCases1 = []DerivePathTestCase{
+	{"m", "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35"},
+	{"m/0'", "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea"},
+	{"m/0'/1", "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368"},
+	{"m/0h/1/2h", "cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca"},
+	{"m/0'/1/2'/2", "0f479245fb19a38a1954c5c7c0ebab2f9bdfd96a17563ef28a6a4b1a2a764ef4"},
+	{"m/0'/1/2'/2/1000000000", "471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8"},
+}
+
+// BIP-32 test vector 2
+var bip32Seed2 = "fffcf9f6f3f0edeae7e4e1dedbd8d5d2cfccc9c6c3c0bdbab7b4b1aeaba8a5a29f9c999693908d8a8784817e7b7875726f6c696663605d5a5754514e4b484542"
+
+var derivePathTestCases2 = []DerivePathTestCase{
+	{"m", "4b03d6fc340455b363f51020ad3ecca4f0850280cf436c70c727923f6db46c3e"},
+	{"m/0'", "5640758773ebb5cd9fb234d31e7dbe957d31b3b44bdb3b55c385f42c98a9acfe"},
+	{"m/0'/1", "25cfadb50c33ac21c62e0df73dd5f4979d41d6cab73799fced92a9ad8c3711ee"},
+}
+
+func TestDerivePath(t *testing.T) {
+	for seedHex, cases := range map[string][]DerivePathTestCase{
+		bip32Seed1: derivePathTestCases1,
+		bip32Seed2: derivePathTestCases2,
+	} {
+		seed, _ := hex.DecodeString(seedHex)
+		for _, tc := range cases {
+			t.Run(tc.path, func(t *testing.T) {
+				sk, err := DerivePath(seed, tc.path)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.privateKey, sk)
+			})
+		}
+	}
+}
+
+func TestDerivePrivateKey(t *testing.T) {
+	seed := MnemonicToSeed(nip06TestCases[0].mnemonic, "")
+	sk, err := DerivePrivateKey(seed, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, nip06TestCases[0].privateKey, sk)
+
+	viaPath, err := DerivePath(seed, "m/44'/1237'/0'/0/0")
+	assert.NoError(t, err)
+	assert.Equal(t, sk, viaPath)
+}
+
+func TestDerivePathErrors(t *testing.T) {
+	seed, _ := hex.DecodeString(bip32Seed1)
+	for _, path := range []string{"", "44'/0", "m/", "m/x", "m/-1", "m/+1", "m/2147483648", "m/0''"} {
+		_, err := DerivePath(seed, path)
+		assert.ErrorContains(t, err, "derivation path is malformed", path)
+	}
+
+	_, err := DerivePath(make([]byte, 15), "m")
+	assert.ErrorContains(t, err, "seed is invalid: 15 bytes")
+	_, err = DerivePath(make([]byte, 65), "m")
+	assert.ErrorContains(t, err, "seed is invalid: 65 bytes")
+}
@@ -0,0 +1,31 @@
+package keys
+
+import (
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// SharedSecret computes the x-coordinate of the ECDH point shared by the
+// owner of the private key and the owner of the public key. As in BIP-340,
+// the x-only public key is lifted to the point with an even y-coordinate.
+//
+// The secret is returned unhashed; protocols derive their own keys from it.
+func SharedSecret(privateKeyHex, publicKeyHex string) ([]byte, error) {
+	sk, err := ParsePrivateKey(privateKeyHex)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(&sk)
+
+	pk, err := ParsePublicKey(publicKeyHex)
+	if err != nil {
+		return nil, err
+	}
+	point, err := secp256k1.ParsePubKey(append([]byte{0x02}, pk[:]...))
+	if err != nil {
+		return nil, err
+	}
+
+	priv := secp256k1.PrivKeyFromBytes(sk[:])
+	defer priv.Zero()
+	return secp256k1.GenerateSharedSecret(priv, point), nil
+}
@@ -0,0 +1,59 @@
+package keys
+
+import (
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestSharedSecret(t *testing.T) {
+	secret, err := SharedSecret(testSK, vectorPK)
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"65a59b3ab01df06dd3c92efa82407cbcd63e6a895446f727abd376f789e99b76",
+		hex.EncodeToString(secret))
+
+	reverse, err := SharedSecret(vectorSK, testPK)
+	assert.NoError(t, err)
+	assert.Equal(t, secret, reverse)
+}
+
+func TestSharedSecretSymmetric(t *testing.T) {
+	// Random keys have odd and even y-coordinates alike
+	for i := 0; i < 16; i++ {
+		skA, _ := GeneratePrivateKey()
+		skB, _ := GeneratePrivateKey()
+		pkA, _ := GetPublicKey(skA)
+		pkB, _ := GetPublicKey(skB)
+
+		ab, err := SharedSecret(skA, pkB)
+		assert.NoError(t, err)
+		ba, err := SharedSecret(skB, pkA)
+		assert.NoError(t, err)
+		assert.Equal(t, ab, ba)
+	}
+}
+
+type SharedSecretErrorTestCase struct {
+	name          string
+	privateKey    string
+	publicKey     string
+	expectedError string
+}
+
+var sharedSecretErrorTestCases = []SharedSecretErrorTestCase{
+	{"malformed private key", "abc", testPK, "private key must be 64 lowercase hex characters"},
+	{"private key out of range", strings.Repeat("0", 64), testPK, "private key is out of range"},
+	{"malformed public key", testSK, strings.ToUpper(testPK), "public key must be 64 lowercase hex characters"},
+	{"public key off curve", testSK, offCurveX, "public key is not on the curve"},
+}
+
+func TestSharedSecretErrors(t *testing.T) {
+	for _, tc := range sharedSecretErrorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := SharedSecret(tc.privateKey, tc.publicKey)
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
@@ -2,7 +2,6 @@ package keys
 
 import (
 	"encoding/hex"
-	"git.wisehodl.dev/jay/go-roots/errors"
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 )
 
@@ -20,15 +19,10 @@ func GeneratePrivateKey() (string, error) {
 // GetPublicKey derives the public key from a private key hex string
 // and returns the x-coordinate as 64 lowercase hex characters.
 func GetPublicKey(privateKeyHex string) (string, error) {
-	if len(privateKeyHex) != 64 {
-		return "", errors.MalformedPrivKey
-	}
-	skBytes, err := hex.DecodeString(privateKeyHex)
+	sk, err := ParsePrivateKey(privateKeyHex)
 	if err != nil {
-		return "", errors.MalformedPrivKey
+		return "", err
 	}
-
-	pk := secp256k1.PrivKeyFromBytes(skBytes).PubKey()
-	pkBytes := pk.SerializeCompressed()[1:]
-	return hex.EncodeToString(pkBytes), nil
+	defer wipe(&sk)
+	return sk.PublicKey().Hex(), nil
 }
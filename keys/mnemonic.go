@@ -0,0 +1,117 @@
+package keys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+)
+
+// The BIP-39 English wordlist, one word per line.
+//
+//go:embed bip39_english.txt
+var englishWordlist string
+
+var (
+	words     = strings.Fields(englishWordlist)
+	wordIndex = indexWords(words)
+)
+
+func indexWords(words []string) map[string]int {
+	index := make(map[string]int, len(words))
+	for i, w := range words {
+		index[w] = i
+	}
+	return index
+}
+
+// GenerateMnemonic returns a new random BIP-39 mnemonic of 12, 15, 18, 21 or
+// 24 English words.
+func GenerateMnemonic(wordCount int) (string, error) {
+	if wordCount < 12 || wordCount > 24 || wordCount%3 != 0 {
+		return "", fmt.Errorf("%w: %d words", errors.InvalidMnemonic, wordCount)
+	}
+	entropy := make([]byte, wordCount*4/3)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+	return MnemonicFromEntropy(entropy)
+}
+
+// MnemonicFromEntropy encodes 16 to 32 bytes of entropy, in multiples of 4,
+// as a BIP-39 mnemonic.
+func MnemonicFromEntropy(entropy []byte) (string, error) {
+	if len(entropy) < 16 || len(entropy) > 32 || len(entropy)%4 != 0 {
+		return "", fmt.Errorf("%w: %d bytes of entropy", errors.InvalidMnemonic, len(entropy))
+	}
+
+	// The entropy is followed by a checksum of one bit per 32 bits of
+	// entropy, and split into 11-bit word indexes.
+	checksum := sha256.Sum256(entropy)
+	bits := append(append([]byte{}, entropy...), checksum[0])
+	count := (len(entropy)*8 + len(entropy)/4) / 11
+
+	mnemonic := make([]string, count)
+	for i := range mnemonic {
+		index := 0
+		for b := i * 11; b < (i+1)*11; b++ {
+			index = index<<1 | int(bits[b/8]>>(7-b%8)&1)
+		}
+		mnemonic[i] = words[index]
+	}
+	return strings.Join(mnemonic, " "), nil
+}
+
+// ValidateMnemonic checks that a mnemonic consists of 12 to 24 English
+// words, in multiples of 3, with a valid checksum.
+func ValidateMnemonic(mnemonic string) error {
+	fields := strings.Fields(mnemonic)
+	if len(fields) < 12 || len(fields) > 24 || len(fields)%3 != 0 {
+		return fmt.Errorf("%w: %d words", errors.InvalidMnemonic, len(fields))
+	}
+
+	bits := make([]byte, (len(fields)*11+7)/8)
+	for i, w := range fields {
+		index, ok := wordIndex[w]
+		if !ok {
+			return fmt.Errorf("%w: unknown word %q", errors.InvalidMnemonic, w)
+		}
+		for j := 0; j < 11; j++ {
+			if index>>(10-j)&1 == 1 {
+				b := i*11 + j
+				bits[b/8] |= 1 << (7 - b%8)
+			}
+		}
+	}
+
+	entropyLen := len(fields) * 4 / 3
+	checksumBits := uint(len(fields) / 3)
+	checksum := sha256.Sum256(bits[:entropyLen])
+	if bits[entropyLen]>>(8-checksumBits) != checksum[0]>>(8-checksumBits) {
+		return fmt.Errorf("%w: checksum mismatch", errors.InvalidMnemonic)
+	}
+	return nil
+}
+
+// MnemonicToSeed derives the 64-byte BIP-39 seed from a mnemonic and an
+// optional passphrase. The mnemonic is not validated, but its words are
+// rejoined with single spaces, as they are when validated.
+func MnemonicToSeed(mnemonic, passphrase string) []byte {
+	password := norm.NFKD.String(strings.Join(strings.Fields(mnemonic), " "))
+	salt := "mnemonic" + norm.NFKD.String(passphrase)
+	return pbkdf2.Key([]byte(password), []byte(salt), 2048, 64, sha512.New)
+}
+
+// PrivateKeyFromMnemonic derives the NIP-06 private key for an account from
+// a mnemonic and an optional passphrase, along m/44'/1237'/<account>'/0/0.
+func PrivateKeyFromMnemonic(mnemonic, passphrase string, account uint32) (string, error) {
+	if err := ValidateMnemonic(mnemonic); err != nil {
+		return "", err
+	}
+	return DerivePrivateKey(MnemonicToSeed(mnemonic, passphrase), account)
+}
@@ -0,0 +1,194 @@
+package keys
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+type NIP06TestCase struct {
+	name       string
+	mnemonic   string
+	privateKey string
+	publicKey  string
+}
+
+// Vectors from NIP-06
+var nip06TestCases = []NIP06TestCase{
+	{
+		name:       "12 words",
+		mnemonic:   "leader monkey parrot ring guide accident before fence cannon height naive bean",
+		privateKey: "7f7ff03d123792d6ac594bfa67bf6d0c0ab55b6b1fdb6249303fe861f1ccba9a",
+		publicKey:  "17162c921dc4d2518f9a101db33695df1afb56ab82f5ff3e5da6eec3ca5cd917",
+	},
+	{
+		name:       "24 words",
+		mnemonic:   "what bleak badge arrange retreat wolf trade produce cricket blur garlic valid proud rude strong choose busy staff weather area salt hollow arm fade",
+		privateKey: "c15d739894c81a2fcfd3a2df85a0d2c0dbc47a280d092799f144d73d7ae78add",
+		publicKey:  "d41b22899549e1f3d335a31002cfd382174006e166d3e658e3a5eecdb6463573",
+	},
+}
+
+func TestPrivateKeyFromMnemonic(t *testing.T) {
+	for _, tc := range nip06TestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sk, err := PrivateKeyFromMnemonic(tc.mnemonic, "", 0)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.privateKey, sk)
+
+			pk, err := GetPublicKey(sk)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.publicKey, pk)
+		})
+	}
+}
+
+func TestPrivateKeyFromMnemonicAccounts(t *testing.T) {
+	mnemonic := nip06TestCases[0].mnemonic
+	sk, err := PrivateKeyFromMnemonic(mnemonic, "", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "3790c23940f62b23754115ef70f16e63cca8e9015a532b8a891171ccdadcf910", sk)
+
+	// A passphrase derives a different identity
+	sk, err = PrivateKeyFromMnemonic(mnemonic, "nostr", 0)
+	assert.NoError(t, err)
+	assert.NotEqual(t, nip06TestCases[0].privateKey, sk)
+
+	_, err = PrivateKeyFromMnemonic("leader monkey parrot", "", 0)
+	assert.ErrorContains(t, err, "mnemonic is invalid: 3 words")
+}
+
+type BIP39TestCase struct {
+	entropy  string
+	mnemonic string
+	seed     string
+}
+
+// Vectors from the BIP-39 reference implementation, with passphrase "TREZOR"
+var bip39TestCases = []BIP39TestCase{
+	{
+		entropy:  "00000000000000000000000000000000",
+		mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		seed:     "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+	},
+	{
+		entropy:  "7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+		mnemonic: "legal winner thank year wave sausage worth useful legal winner thank yellow",
+		seed:     "2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+	},
+	{
+		entropy:  "ffffffffffffffffffffffffffffffffffffffffffffffff",
+		mnemonic: "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo when",
+		seed:     "0cd6e5d827bb62eb8fc1e262254223817fd068a74b5b449cc2f667c3f1f985a76379b43348d952e2265b4cd129090758b3e3c2c49103b5051aac2eaeb890a528",
+	},
+	{
+		entropy:  "8080808080808080808080808080808080808080808080808080808080808080",
+		mnemonic: "letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic bless",
+		seed:     "c0c519bd0e91a2ed54357d9d1ebef6f5af218a153624cf4f2da911a0ed8f7a09e2ef61af0aca007096df430022f7a2b6fb91661a9589097069720d015e4e982f",
+	},
+}
+
+func TestBIP39Vectors(t *testing.T) {
+	for _, tc := range bip39TestCases {
+		t.Run(tc.entropy, func(t *testing.T) {
+			entropy, _ := hex.DecodeString(tc.entropy)
+			mnemonic, err := MnemonicFromEntropy(entropy)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.mnemonic, mnemonic)
+			assert.NoError(t, ValidateMnemonic(mnemonic))
+			assert.Equal(t, tc.seed, hex.EncodeToString(MnemonicToSeed(mnemonic, "TREZOR")))
+		})
+	}
+}
+
+func TestWordlist(t *testing.T) {
+	// The SHA-256 of the official english.txt
+	sum := sha256.Sum256([]byte(englishWordlist))
+	assert.Equal(t, "2f5eed53a4727b4bf8880d8f3f199efc90e58503646d9ff8eff3a2ed3b24dbda", hex.EncodeToString(sum[:]))
+	assert.Len(t, words, 2048)
+	assert.Len(t, wordIndex, 2048)
+}
+
+func TestGenerateMnemonic(t *testing.T) {
+	for _, count := range []int{12, 15, 18, 21, 24} {
+		mnemonic, err := GenerateMnemonic(count)
+		assert.NoError(t, err)
+		assert.Len(t, strings.Fields(mnemonic), count)
+		assert.NoError(t, ValidateMnemonic(mnemonic))
+	}
+
+	a, _ := GenerateMnemonic(12)
+	b, _ := GenerateMnemonic(12)
+	assert.NotEqual(t, a, b)
+
+	_, err := GenerateMnemonic(13)
+	assert.ErrorContains(t, err, "mnemonic is invalid: 13 words")
+}
+
+func TestMnemonicFromEntropyLength(t *testing.T) {
+	for _, n := range []int{0, 12, 17, 36} {
+		_, err := MnemonicFromEntropy(make([]byte, n))
+		assert.ErrorContains(t, err, "mnemonic is invalid")
+	}
+}
+
+type ValidateMnemonicTestCase struct {
+	name          string
+	mnemonic      string
+	expectedError string
+}
+
+var validateMnemonicTestCases = []ValidateMnemonicTestCase{
+	{
+		name:          "too few words",
+		mnemonic:      "abandon abandon abandon",
+		expectedError: "mnemonic is invalid: 3 words",
+	},
+	{
+		name:          "unknown word",
+		mnemonic:      "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon nostr",
+		expectedError: "mnemonic is invalid: unknown word \"nostr\"",
+	},
+	{
+		name:          "uppercase word",
+		mnemonic:      "Abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		expectedError: "mnemonic is invalid: unknown word \"Abandon\"",
+	},
+	{
+		name:          "bad checksum",
+		mnemonic:      "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",
+		expectedError: "mnemonic is invalid: checksum mismatch",
+	},
+	{
+		name:          "bad checksum with 24 words",
+		mnemonic:      strings.Repeat("zoo ", 24),
+		expectedError: "mnemonic is invalid: checksum mismatch",
+	},
+}
+
+func TestValidateMnemonic(t *testing.T) {
+	for _, tc := range validateMnemonicTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.ErrorContains(t, ValidateMnemonic(tc.mnemonic), tc.expectedError)
+		})
+	}
+}
+
+func TestMnemonicToSeedNormalizesPassphrase(t *testing.T) {
+	mnemonic := bip39TestCases[0].mnemonic
+	// "é" precomposed and decomposed
+	assert.Equal(t, MnemonicToSeed(mnemonic, "café"), MnemonicToSeed(mnemonic, "café"))
+}
+
+func TestMnemonicWhitespace(t *testing.T) {
+	tc := nip06TestCases[0]
+	mnemonic := "  " + strings.ReplaceAll(tc.mnemonic, " ", " \t ") + "\n"
+	assert.NoError(t, ValidateMnemonic(mnemonic))
+	assert.Equal(t, MnemonicToSeed(tc.mnemonic, ""), MnemonicToSeed(mnemonic, ""))
+
+	sk, err := PrivateKeyFromMnemonic(mnemonic, "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, tc.privateKey, sk)
+}
@@ -0,0 +1,126 @@
+package keys
+
+import (
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"log/slog"
+	"sync"
+)
+
+// redacted replaces private key material in formatted output.
+const redacted = "[redacted]"
+
+// SecureKey holds a private key that can be wiped from memory once it is no
+// longer needed. It redacts itself when formatted, logged or marshaled, and
+// lends the key out only for the duration of Use.
+//
+// A SecureKey must not be copied after first use.
+type SecureKey struct {
+	mu    sync.RWMutex
+	key   PrivateKey
+	wiped bool
+}
+
+// GenerateSecureKey generates a new, random private key.
+func GenerateSecureKey() (*SecureKey, error) {
+	sk, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	defer sk.Zero()
+
+	s := &SecureKey{}
+	sk.Key.PutBytes((*[32]byte)(&s.key))
+	return s, nil
+}
+
+// NewSecureKey moves a private key into a SecureKey, wiping the original.
+func NewSecureKey(k *PrivateKey) *SecureKey {
+	s := &SecureKey{key: *k}
+	wipe(k)
+	return s
+}
+
+// ParseSecureKey parses a private key from 64 lowercase hex characters.
+// The hex string itself cannot be wiped, so generating or decrypting keys
+// directly into a SecureKey is preferable.
+func ParseSecureKey(privateKeyHex string) (*SecureKey, error) {
+	k, err := ParsePrivateKey(privateKeyHex)
+	if err != nil {
+		return nil, err
+	}
+	return NewSecureKey(&k), nil
+}
+
+// Use calls fn with the private key, failing with errors.WipedKey if the key
+// has been wiped. The key must not be retained after fn returns.
+func (s *SecureKey) Use(fn func(k *PrivateKey) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.wiped {
+		return errors.WipedKey
+	}
+	return fn(&s.key)
+}
+
+// PublicKey derives the public key.
+func (s *SecureKey) PublicKey() (PublicKey, error) {
+	var pk PublicKey
+	err := s.Use(func(k *PrivateKey) error {
+		pk = k.PublicKey()
+		return nil
+	})
+	return pk, err
+}
+
+// Wipe overwrites the private key with zeros. Later uses of the key fail.
+func (s *SecureKey) Wipe() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wipe(&s.key)
+	s.wiped = true
+}
+
+// Wiped reports whether the key has been wiped.
+func (s *SecureKey) Wiped() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.wiped
+}
+
+// String returns a placeholder instead of the key.
+func (s *SecureKey) String() string {
+	return redacted
+}
+
+// Format prints a placeholder instead of the key, for every verb.
+func (s *SecureKey) Format(f fmt.State, verb rune) {
+	f.Write([]byte(redacted))
+}
+
+// LogValue logs a placeholder instead of the key.
+func (s *SecureKey) LogValue() slog.Value {
+	return slog.StringValue(redacted)
+}
+
+// MarshalText encodes a placeholder instead of the key.
+func (s *SecureKey) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+// Format prints a placeholder instead of the key, for every verb.
+func (k PrivateKey) Format(f fmt.State, verb rune) {
+	f.Write([]byte(k.String()))
+}
+
+// LogValue logs a placeholder instead of the key.
+func (k PrivateKey) LogValue() slog.Value {
+	return slog.StringValue(k.String())
+}
+
+func wipe(k *PrivateKey) {
+	for i := range k {
+		k[i] = 0
+	}
+}
@@ -0,0 +1,112 @@
+package keys
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"testing"
+)
+
+// formats covers the verbs and flags that print values.
+var formats = []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%X", "%d"}
+
+func TestSecureKeyRedaction(t *testing.T) {
+	key, err := ParseSecureKey(testSK)
+	assert.NoError(t, err)
+
+	for _, format := range formats {
+		assert.Equal(t, "[redacted]", fmt.Sprintf(format, key), format)
+	}
+	assert.Equal(t, "[redacted]", key.String())
+
+	// Within other values
+	wrapper := struct{ Key *SecureKey }{key}
+	assert.NotContains(t, fmt.Sprintf("%+v", wrapper), testSK[:16])
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	logger.Info("signing", "key", key)
+	assert.Contains(t, buf.String(), `"key":"[redacted]"`)
+	assert.NotContains(t, buf.String(), testSK[:16])
+
+	data, err := json.Marshal(wrapper)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"Key":"[redacted]"}`, string(data))
+}
+
+func TestPrivateKeyFormatRedaction(t *testing.T) {
+	sk, _ := ParsePrivateKey(testSK)
+	for _, format := range formats {
+		printed := fmt.Sprintf(format, sk)
+		assert.Equal(t, "PrivateKey(redacted)", printed, format)
+	}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("loaded", "key", sk)
+	assert.Contains(t, buf.String(), "key=PrivateKey(redacted)")
+}
+
+func TestSecureKeyUse(t *testing.T) {
+	key, err := ParseSecureKey(testSK)
+	assert.NoError(t, err)
+
+	pk, err := key.PublicKey()
+	assert.NoError(t, err)
+	assert.Equal(t, testPK, pk.Hex())
+
+	err = key.Use(func(k *PrivateKey) error {
+		assert.Equal(t, testSK, k.Hex())
+		return nil
+	})
+	assert.NoError(t, err)
+
+	assert.False(t, key.Wiped())
+	key.Wipe()
+	assert.True(t, key.Wiped())
+	assert.Equal(t, PrivateKey{}, key.key)
+
+	_, err = key.PublicKey()
+	assert.ErrorContains(t, err, "private key has been wiped")
+	called := false
+	err = key.Use(func(*PrivateKey) error {
+		called = true
+		return nil
+	})
+	assert.ErrorContains(t, err, "private key has been wiped")
+	assert.False(t, called)
+}
+
+func TestNewSecureKeyWipesOriginal(t *testing.T) {
+	sk, _ := ParsePrivateKey(testSK)
+	key := NewSecureKey(&sk)
+	assert.Equal(t, PrivateKey{}, sk)
+
+	pk, err := key.PublicKey()
+	assert.NoError(t, err)
+	assert.Equal(t, testPK, pk.Hex())
+}
+
+func TestGenerateSecureKey(t *testing.T) {
+	a, err := GenerateSecureKey()
+	assert.NoError(t, err)
+	b, err := GenerateSecureKey()
+	assert.NoError(t, err)
+
+	pkA, _ := a.PublicKey()
+	pkB, _ := b.PublicKey()
+	assert.NotEqual(t, pkA, pkB)
+
+	// The generated key is in range
+	a.Use(func(k *PrivateKey) error {
+		_, err := ParsePrivateKey(k.Hex())
+		assert.NoError(t, err)
+		return nil
+	})
+}
+
+func TestParseSecureKeyError(t *testing.T) {
+	_, err := ParseSecureKey("abc")
+	assert.ErrorContains(t, err, "private key must be 64 lowercase hex characters")
+}
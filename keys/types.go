@@ -0,0 +1,125 @@
+package keys
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// PrivateKey is a secp256k1 private key.
+//
+// Its String method never prints the key. Use Hex to read the key material
+// explicitly.
+type PrivateKey [32]byte
+
+// PublicKey is the 32-byte x-coordinate of a secp256k1 public key.
+type PublicKey [32]byte
+
+// ParsePrivateKey parses a private key from 64 lowercase hex characters,
+// checking that it is in range.
+func ParsePrivateKey(privateKeyHex string) (PrivateKey, error) {
+	var k PrivateKey
+	if !decodeLowerHex(k[:], privateKeyHex) {
+		return PrivateKey{}, errors.MalformedPrivKey
+	}
+	var scalar secp256k1.ModNScalar
+	if overflow := scalar.SetBytes((*[32]byte)(&k)); overflow != 0 || scalar.IsZero() {
+		return PrivateKey{}, errors.InvalidPrivKey
+	}
+	return k, nil
+}
+
+// ParsePublicKey parses a public key from 64 lowercase hex characters,
+// checking that it is a point on the curve.
+func ParsePublicKey(publicKeyHex string) (PublicKey, error) {
+	var k PublicKey
+	if !decodeLowerHex(k[:], publicKeyHex) {
+		return PublicKey{}, errors.MalformedPubKey
+	}
+	if err := checkPoint(k); err != nil {
+		return PublicKey{}, err
+	}
+	return k, nil
+}
+
+// Hex returns the private key as 64 lowercase hex characters.
+func (k PrivateKey) Hex() string {
+	return hex.EncodeToString(k[:])
+}
+
+// String returns a placeholder, so that the key is not printed by accident.
+func (k PrivateKey) String() string {
+	return "PrivateKey(redacted)"
+}
+
+// PublicKey derives the public key.
+func (k PrivateKey) PublicKey() PublicKey {
+	var pk PublicKey
+	copy(pk[:], secp256k1.PrivKeyFromBytes(k[:]).PubKey().SerializeCompressed()[1:])
+	return pk
+}
+
+// Equal reports whether two private keys are equal, in constant time.
+func (k PrivateKey) Equal(other PrivateKey) bool {
+	return subtle.ConstantTimeCompare(k[:], other[:]) == 1
+}
+
+// MarshalText encodes the private key as hex.
+func (k PrivateKey) MarshalText() ([]byte, error) {
+	return []byte(k.Hex()), nil
+}
+
+// UnmarshalText parses a private key from hex.
+func (k *PrivateKey) UnmarshalText(text []byte) error {
+	parsed, err := ParsePrivateKey(string(text))
+	if err != nil {
+		return err
+	}
+	*k = parsed
+	return nil
+}
+
+// Hex returns the public key as 64 lowercase hex characters.
+func (k PublicKey) Hex() string {
+	return hex.EncodeToString(k[:])
+}
+
+// String returns the public key as hex.
+func (k PublicKey) String() string {
+	return k.Hex()
+}
+
+// Equal reports whether two public keys are equal, in constant time.
+func (k PublicKey) Equal(other PublicKey) bool {
+	return subtle.ConstantTimeCompare(k[:], other[:]) == 1
+}
+
+// MarshalText encodes the public key as hex.
+func (k PublicKey) MarshalText() ([]byte, error) {
+	return []byte(k.Hex()), nil
+}
+
+// UnmarshalText parses a public key from hex.
+func (k *PublicKey) UnmarshalText(text []byte) error {
+	parsed, err := ParsePublicKey(string(text))
+	if err != nil {
+		return err
+	}
+	*k = parsed
+	return nil
+}
+
+// decodeLowerHex decodes exactly len(dst) bytes from lowercase hex.
+func decodeLowerHex(dst []byte, s string) bool {
+	if len(s) != 2*len(dst) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
@@ -0,0 +1,90 @@
+package keys
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+type ParsePrivateKeyTestCase struct {
+	name          string
+	input         string
+	expectedError string
+}
+
+var parsePrivateKeyTestCases = []ParsePrivateKeyTestCase{
+	{"too short", testSK[:62], "private key must be 64 lowercase hex characters"},
+	{"uppercase", strings.ToUpper(testSK), "private key must be 64 lowercase hex characters"},
+	{"non-hex", strings.Repeat("g", 64), "private key must be 64 lowercase hex characters"},
+	{"zero", strings.Repeat("0", 64), "private key is out of range"},
+	{"curve order", "fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", "private key is out of range"},
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	sk, err := ParsePrivateKey(testSK)
+	assert.NoError(t, err)
+	assert.Equal(t, testSK, sk.Hex())
+	assert.Equal(t, testPK, sk.PublicKey().Hex())
+
+	for _, tc := range parsePrivateKeyTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParsePrivateKey(tc.input)
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	pk, err := ParsePublicKey(testPK)
+	assert.NoError(t, err)
+	assert.Equal(t, testPK, pk.Hex())
+	assert.Equal(t, testPK, pk.String())
+
+	for _, input := range []string{testPK[:62], strings.ToUpper(testPK), "z" + testPK[1:]} {
+		_, err := ParsePublicKey(input)
+		assert.ErrorContains(t, err, "public key must be 64 lowercase hex characters")
+	}
+}
+
+func TestPrivateKeyRedaction(t *testing.T) {
+	sk, _ := ParsePrivateKey(testSK)
+	for _, format := range []string{"%v", "%s", "%+v", "%x", "%q"} {
+		printed := fmt.Sprintf(format, sk)
+		assert.NotContains(t, printed, testSK[:16], format)
+	}
+	assert.Equal(t, "PrivateKey(redacted)", sk.String())
+}
+
+func TestKeyEqual(t *testing.T) {
+	a, _ := ParsePrivateKey(testSK)
+	b, _ := ParsePrivateKey(testSK)
+	other, _ := ParsePrivateKey("7f7ff03d123792d6ac594bfa67bf6d0c0ab55b6b1fdb6249303fe861f1ccba9a")
+	assert.True(t, a.Equal(b))
+	assert.False(t, a.Equal(other))
+	assert.True(t, a.PublicKey().Equal(b.PublicKey()))
+	assert.False(t, a.PublicKey().Equal(other.PublicKey()))
+}
+
+func TestKeyJSON(t *testing.T) {
+	type identity struct {
+		PrivateKey PrivateKey `json:"private_key"`
+		PublicKey  PublicKey  `json:"public_key"`
+	}
+	sk, _ := ParsePrivateKey(testSK)
+	data, err := json.Marshal(identity{sk, sk.PublicKey()})
+	assert.NoError(t, err)
+	expected := `{"private_key":"` + testSK + `","public_key":"` + testPK + `"}`
+	assert.Equal(t, expected, string(data))
+
+	var decoded identity
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.True(t, sk.Equal(decoded.PrivateKey))
+	assert.Equal(t, sk.PublicKey(), decoded.PublicKey)
+
+	err = json.Unmarshal([]byte(`{"public_key":"abc"}`), &decoded)
+	assert.ErrorContains(t, err, "public key must be 64 lowercase hex characters")
+	err = json.Unmarshal([]byte(`{"private_key":"`+strings.Repeat("0", 64)+`"}`), &decoded)
+	assert.ErrorContains(t, err, "private key is out of range")
+}
@@ -0,0 +1,143 @@
+package keys
+
+import (
+	"encoding/hex"
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/bech32"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"strings"
+)
+
+// Bech32 prefixes of NIP-19 keys.
+const (
+	NpubPrefix = "npub"
+	NsecPrefix = "nsec"
+)
+
+// ValidatePrivateKey checks that a private key is 64 lowercase hex
+// characters encoding a scalar between 1 and the curve order.
+func ValidatePrivateKey(privateKeyHex string) error {
+	_, err := ParsePrivateKey(privateKeyHex)
+	return err
+}
+
+// ValidatePublicKey checks that a public key is 64 lowercase hex characters
+// encoding the x-coordinate of a point on the curve.
+func ValidatePublicKey(publicKeyHex string) error {
+	var x [32]byte
+	if !decodeLowerHex(x[:], publicKeyHex) {
+		return errors.MalformedPubKey
+	}
+	return checkPoint(x)
+}
+
+// checkPoint checks that x is the x-coordinate of a curve point.
+func checkPoint(x [32]byte) error {
+	if _, err := secp256k1.ParsePubKey(append([]byte{0x02}, x[:]...)); err != nil {
+		return errors.InvalidPubKey
+	}
+	return nil
+}
+
+// KeyType identifies the kind of key ParseAnyKey expects or returns.
+type KeyType int
+
+const (
+	// UnknownKeyType expects either an npub or an nsec. Hex input is
+	// rejected, since it does not say which kind of key it is.
+	UnknownKeyType KeyType = iota
+
+	// PublicKeyType is an npub, or hex expected to be a public key.
+	PublicKeyType
+
+	// PrivateKeyType is an nsec, or hex expected to be a private key.
+	PrivateKeyType
+)
+
+// ParsedKey is a key in normalized form.
+type ParsedKey struct {
+	// Hex is the key as 64 lowercase hex characters.
+	Hex string
+
+	// Type is the kind of key.
+	Type KeyType
+}
+
+// ParseAnyKey parses a key given as hex in either case, or as an npub or
+// nsec. An npub may have a "nostr:" prefix, which NIP-21 forbids on an nsec
+// and does not define for hex. Hex does not say which kind of key it is, so
+// it is parsed as the expected type, and fails with errors.UnknownKeyFormat
+// if that is UnknownKeyType. Npubs and nsecs must match the expected type,
+// unless it is UnknownKeyType. Keys must be valid of their type.
+func ParseAnyKey(s string, expected KeyType) (ParsedKey, error) {
+	s = strings.TrimSpace(s)
+	uri := len(s) > 6 && strings.EqualFold(s[:6], "nostr:")
+	if uri {
+		s = s[6:]
+	}
+
+	var key ParsedKey
+	if len(s) == 64 {
+		key.Hex = strings.ToLower(s)
+		if _, err := hex.DecodeString(key.Hex); err != nil {
+			return ParsedKey{}, errors.UnknownKeyFormat
+		}
+		if expected == UnknownKeyType {
+			return ParsedKey{}, fmt.Errorf("%w: hex key of unknown type", errors.UnknownKeyFormat)
+		}
+		key.Type = expected
+	} else {
+		hrp, data, err := bech32.Decode(s)
+		if err != nil || len(data) != 32 {
+			return ParsedKey{}, errors.UnknownKeyFormat
+		}
+		key.Hex = hex.EncodeToString(data)
+		switch hrp {
+		case NpubPrefix:
+			key.Type = PublicKeyType
+		case NsecPrefix:
+			key.Type = PrivateKeyType
+		default:
+			return ParsedKey{}, errors.UnknownKeyFormat
+		}
+		if expected != UnknownKeyType && key.Type != expected {
+			return ParsedKey{}, fmt.Errorf("%w: unexpected %s", errors.UnknownKeyFormat, hrp)
+		}
+	}
+
+	// NIP-21 URIs carry NIP-19 identifiers, and never an nsec
+	if uri && (len(s) == 64 || key.Type != PublicKeyType) {
+		return ParsedKey{}, fmt.Errorf("%w: nostr: URI must be an npub", errors.UnknownKeyFormat)
+	}
+
+	var err error
+	if key.Type == PublicKeyType {
+		err = ValidatePublicKey(key.Hex)
+	} else {
+		err = ValidatePrivateKey(key.Hex)
+	}
+	if err != nil {
+		return ParsedKey{}, err
+	}
+	return key, nil
+}
+
+// EncodeNpub encodes a public key as an npub.
+func EncodeNpub(publicKeyHex string) (string, error) {
+	pk, err := ParsePublicKey(publicKeyHex)
+	if err != nil {
+		return "", err
+	}
+	return bech32.Encode(NpubPrefix, pk[:])
+}
+
+// EncodeNsec encodes a private key as an nsec.
+func EncodeNsec(privateKeyHex string) (string, error) {
+	sk, err := ParsePrivateKey(privateKeyHex)
+	if err != nil {
+		return "", err
+	}
+	defer wipe(&sk)
+	return bech32.Encode(NsecPrefix, sk[:])
+}
@@ -0,0 +1,154 @@
+package keys
+
+import (
+	"git.wisehodl.dev/jay/go-roots/bech32"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// NIP-19 test vectors
+const (
+	vectorNpub = "npub10elfcs4fr0l0r8af98jlmgdh9c8tcxjvz9qkw038js35mp4dma8qzvjptg"
+	vectorPK   = "7e7e9c42a91bfef19fa929e5fda1b72e0ebc1a4c1141673e2794234d86addf4e"
+	vectorNsec = "nsec1vl029mgpspedva04g90vltkh6fvh240zqtv9k0t9af8935ke9laqsnlfe5"
+	vectorSK   = "67dea2ed018072d675f5415ecfaed7d2597555e202d85b3d65ea4e58d2d92ffa"
+)
+
+// offCurveX is not the x-coordinate of any curve point.
+var offCurveX = strings.Repeat("0", 63) + "5"
+
+func mustEncode(t *testing.T, hrp, hexData string) string {
+	var data [32]byte
+	assert.True(t, decodeLowerHex(data[:], hexData))
+	s, err := bech32.Encode(hrp, data[:])
+	assert.NoError(t, err)
+	return s
+}
+
+type ValidateKeyTestCase struct {
+	name          string
+	input         string
+	expectedError string
+}
+
+var validatePublicKeyTestCases = []ValidateKeyTestCase{
+	{"valid", testPK, ""},
+	{"too short", testPK[:62], "public key must be 64 lowercase hex characters"},
+	{"uppercase", strings.ToUpper(testPK), "public key must be 64 lowercase hex characters"},
+	{"off curve", offCurveX, "public key is not on the curve"},
+	{"field size", "fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f", "public key is not on the curve"},
+}
+
+func TestValidatePublicKey(t *testing.T) {
+	for _, tc := range validatePublicKeyTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidatePublicKey(tc.input)
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+
+	// ParsePublicKey applies the same checks
+	_, err := ParsePublicKey(offCurveX)
+	assert.ErrorContains(t, err, "public key is not on the curve")
+}
+
+func TestValidatePrivateKey(t *testing.T) {
+	assert.NoError(t, ValidatePrivateKey(testSK))
+	for _, tc := range parsePrivateKeyTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.ErrorContains(t, ValidatePrivateKey(tc.input), tc.expectedError)
+		})
+	}
+
+	// GetPublicKey rejects out-of-range keys
+	_, err := GetPublicKey(strings.Repeat("0", 64))
+	assert.ErrorContains(t, err, "private key is out of range")
+}
+
+type ParseAnyKeyTestCase struct {
+	name     string
+	input    string
+	expect   KeyType
+	expected ParsedKey
+}
+
+var parseAnyKeyTestCases = []ParseAnyKeyTestCase{
+	{"public hex", vectorPK, PublicKeyType, ParsedKey{vectorPK, PublicKeyType}},
+	{"private hex", vectorSK, PrivateKeyType, ParsedKey{vectorSK, PrivateKeyType}},
+	{"uppercase hex", strings.ToUpper(vectorPK), PublicKeyType, ParsedKey{vectorPK, PublicKeyType}},
+	{"whitespace", "  " + vectorPK + "\n", PublicKeyType, ParsedKey{vectorPK, PublicKeyType}},
+	{"off-curve private hex", offCurveX, PrivateKeyType, ParsedKey{offCurveX, PrivateKeyType}},
+	{"npub", vectorNpub, PublicKeyType, ParsedKey{vectorPK, PublicKeyType}},
+	{"npub of either type", vectorNpub, UnknownKeyType, ParsedKey{vectorPK, PublicKeyType}},
+	{"uppercase npub", strings.ToUpper(vectorNpub), PublicKeyType, ParsedKey{vectorPK, PublicKeyType}},
+	{"nostr uri", "nostr:" + vectorNpub, UnknownKeyType, ParsedKey{vectorPK, PublicKeyType}},
+	{"nsec", vectorNsec, PrivateKeyType, ParsedKey{vectorSK, PrivateKeyType}},
+	{"nsec of either type", vectorNsec, UnknownKeyType, ParsedKey{vectorSK, PrivateKeyType}},
+	{"uppercase nsec", strings.ToUpper(vectorNsec), PrivateKeyType, ParsedKey{vectorSK, PrivateKeyType}},
+}
+
+func TestParseAnyKey(t *testing.T) {
+	for _, tc := range parseAnyKeyTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			key, err := ParseAnyKey(tc.input, tc.expect)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, key)
+		})
+	}
+}
+
+type ParseAnyKeyErrorTestCase struct {
+	name          string
+	input         func(t *testing.T) string
+	expect        KeyType
+	expectedError string
+}
+
+var parseAnyKeyErrorTestCases = []ParseAnyKeyErrorTestCase{
+	{"empty", func(*testing.T) string { return "" }, PublicKeyType, "unrecognized key format"},
+	{"non-hex", func(*testing.T) string { return strings.Repeat("g", 64) }, PublicKeyType, "unrecognized key format"},
+	{"short hex", func(*testing.T) string { return vectorPK[:62] }, PublicKeyType, "unrecognized key format"},
+	{"hex of unknown type", func(*testing.T) string { return vectorPK }, UnknownKeyType, "unrecognized key format: hex key of unknown type"},
+	{"mixed case npub", func(*testing.T) string { return "NPUB" + vectorNpub[4:] }, PublicKeyType, "unrecognized key format"},
+	{"bad checksum", func(*testing.T) string { return vectorNpub[:len(vectorNpub)-1] + "q" }, PublicKeyType, "unrecognized key format"},
+	{"other prefix", func(t *testing.T) string { return mustEncode(t, "note", vectorPK) }, UnknownKeyType, "unrecognized key format"},
+	{"nsec expecting public key", func(*testing.T) string { return vectorNsec }, PublicKeyType, "unrecognized key format: unexpected nsec"},
+	{"npub expecting private key", func(*testing.T) string { return vectorNpub }, PrivateKeyType, "unrecognized key format: unexpected npub"},
+	{"nostr nsec", func(*testing.T) string { return "nostr:" + vectorNsec }, UnknownKeyType, "unrecognized key format: nostr: URI must be an npub"},
+	{"nostr hex", func(*testing.T) string { return "nostr:" + vectorPK }, PublicKeyType, "unrecognized key format: nostr: URI must be an npub"},
+	{"off-curve hex", func(*testing.T) string {
+		return "fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f"
+	}, PublicKeyType, "public key is not on the curve"},
+	{"out of range hex", func(*testing.T) string { return strings.Repeat("f", 64) }, PrivateKeyType, "private key is out of range"},
+	{"off-curve npub", func(t *testing.T) string { return mustEncode(t, NpubPrefix, offCurveX) }, UnknownKeyType, "public key is not on the curve"},
+	{"zero nsec", func(t *testing.T) string { return mustEncode(t, NsecPrefix, strings.Repeat("0", 64)) }, UnknownKeyType, "private key is out of range"},
+}
+
+func TestParseAnyKeyErrors(t *testing.T) {
+	for _, tc := range parseAnyKeyErrorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseAnyKey(tc.input(t), tc.expect)
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestEncodeKeys(t *testing.T) {
+	npub, err := EncodeNpub(vectorPK)
+	assert.NoError(t, err)
+	assert.Equal(t, vectorNpub, npub)
+
+	nsec, err := EncodeNsec(vectorSK)
+	assert.NoError(t, err)
+	assert.Equal(t, vectorNsec, nsec)
+
+	_, err = EncodeNpub(offCurveX)
+	assert.ErrorContains(t, err, "public key is not on the curve")
+	_, err = EncodeNsec(strings.Repeat("0", 64))
+	assert.ErrorContains(t, err, "private key is out of range")
+}
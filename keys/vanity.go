@@ -0,0 +1,370 @@
+package keys
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/bech32"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"math"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// VanityEncoding selects the public key encoding a vanity pattern is matched
+// against.
+type VanityEncoding int
+
+const (
+	// VanityNpub matches the npub, after the "npub1" prefix.
+	VanityNpub VanityEncoding = iota
+
+	// VanityHex matches the hex public key.
+	VanityHex
+)
+
+// VanityOptions configures vanity mining. The zero value matches a prefix of
+// the npub with the defaults.
+type VanityOptions struct {
+	// Encoding is the public key encoding to match.
+	Encoding VanityEncoding
+
+	// Suffix matches the pattern at the end of the encoded key instead of
+	// the start.
+	Suffix bool
+
+	// Workers is the number of goroutines generating keys in parallel.
+	// Defaults to the number of CPUs.
+	Workers int
+
+	// Progress, if set, is called every ProgressInterval.
+	Progress func(VanityProgress)
+
+	// ProgressInterval defaults to one second.
+	ProgressInterval time.Duration
+}
+
+// VanityProgress reports the progress of vanity mining.
+type VanityProgress struct {
+	// Attempts is the number of keys tried so far.
+	Attempts uint64
+
+	// Expected is the average number of keys tried before a match.
+	Expected float64
+
+	// Elapsed is the time since mining started.
+	Elapsed time.Duration
+
+	// Rate is the number of keys tried per second.
+	Rate float64
+}
+
+// VanityKey is a mined keypair.
+type VanityKey struct {
+	// PrivateKey and PublicKey are 64 lowercase hex characters.
+	PrivateKey string
+	PublicKey  string
+
+	// Npub is the bech32 encoding of the public key.
+	Npub string
+
+	// Attempts is the number of keys tried.
+	Attempts uint64
+
+	// Elapsed is the time mining took.
+	Elapsed time.Duration
+}
+
+// Lengths of the parts of an npub: "npub1", 52 characters encoding the key,
+// and the checksum.
+const (
+	npubPrefixLength   = len(NpubPrefix) + 1
+	npubDataLength     = 52
+	npubChecksumLength = 6
+)
+
+// EstimateVanityAttempts returns the average number of keys tried before
+// one matches the pattern, failing with errors.InvalidVanityPattern if no
+// key can match it.
+func EstimateVanityAttempts(pattern string, opts VanityOptions) (float64, error) {
+	pattern = strings.ToLower(pattern)
+	if opts.Encoding == VanityHex {
+		return estimateHex(pattern)
+	}
+	return estimateNpub(pattern, opts.Suffix)
+}
+
+func estimateHex(pattern string) (float64, error) {
+	if pattern == "" || len(pattern) > 64 {
+		return 0, fmt.Errorf("%w: must be 1 to 64 characters", errors.InvalidVanityPattern)
+	}
+	for i := 0; i < len(pattern); i++ {
+		if !strings.ContainsRune("0123456789abcdef", rune(pattern[i])) {
+			return 0, fmt.Errorf("%w: %q is not a hex character", errors.InvalidVanityPattern, pattern[i])
+		}
+	}
+	return math.Pow(16, float64(len(pattern))), nil
+}
+
+func estimateNpub(pattern string, suffix bool) (float64, error) {
+	max := npubDataLength
+	if suffix {
+		max += npubChecksumLength
+	}
+	if pattern == "" || len(pattern) > max {
+		return 0, fmt.Errorf("%w: must be 1 to %d characters", errors.InvalidVanityPattern, max)
+	}
+
+	// Offset of the pattern within the encoded key
+	offset := 0
+	if suffix {
+		offset = npubDataLength + npubChecksumLength - len(pattern)
+	}
+
+	expected := 1.0
+	for i := 0; i < len(pattern); i++ {
+		if !strings.ContainsRune(bech32.Charset, rune(pattern[i])) {
+			return 0, fmt.Errorf("%w: %q is not in the bech32 charset", errors.InvalidVanityPattern, pattern[i])
+		}
+
+		// The last data character holds one bit of the key and four bits of
+		// zero padding, so it can only be "q" or "s"
+		if offset+i == npubDataLength-1 {
+			if pattern[i] != bech32.Charset[0] && pattern[i] != bech32.Charset[16] {
+				return 0, fmt.Errorf("%w: character %d must be q or s", errors.InvalidVanityPattern, npubDataLength)
+			}
+			expected *= 2
+			continue
+		}
+		expected *= 32
+	}
+	return expected, nil
+}
+
+// vanityBatchSize is the number of keys a worker tries between checking for
+// cancellation and reporting attempts.
+const vanityBatchSize = 256
+
+// MineVanity generates keys until the encoded public key matches the
+// pattern, which is case-insensitive. Mining stops with the context's error
+// when it is canceled.
+//
+// The average number of attempts grows 32-fold with each npub character and
+// 16-fold with each hex character; see EstimateVanityAttempts.
+func MineVanity(ctx context.Context, pattern string, opts VanityOptions) (VanityKey, error) {
+	pattern = strings.ToLower(pattern)
+	expected, err := EstimateVanityAttempts(pattern, opts)
+	if err != nil {
+		return VanityKey{}, err
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+	if opts.ProgressInterval <= 0 {
+		opts.ProgressInterval = time.Second
+	}
+
+	mining, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	var attempts atomic.Uint64
+	result := make(chan PrivateKey, 1)
+	errs := make(chan error, opts.Workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sk, err := mineVanity(mining, vanityMatcher(pattern, opts), &attempts)
+			if err != nil {
+				errs <- err
+				cancel()
+				return
+			}
+			if sk != nil {
+				select {
+				case result <- *sk:
+				default:
+				}
+				wipe(sk)
+				cancel()
+			}
+		}()
+	}
+
+	if opts.Progress != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			ticker := time.NewTicker(opts.ProgressInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					opts.Progress(vanityProgress(attempts.Load(), expected, time.Since(start)))
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	select {
+	case sk := <-result:
+		defer wipe(&sk)
+		pk := sk.PublicKey()
+		npub, err := bech32.Encode(NpubPrefix, pk[:])
+		if err != nil {
+			return VanityKey{}, err
+		}
+		return VanityKey{
+			PrivateKey: sk.Hex(),
+			PublicKey:  pk.Hex(),
+			Npub:       npub,
+			Attempts:   attempts.Load(),
+			Elapsed:    time.Since(start),
+		}, nil
+	default:
+	}
+	select {
+	case err := <-errs:
+		return VanityKey{}, err
+	default:
+		return VanityKey{}, ctx.Err()
+	}
+}
+
+// mineVanity walks consecutive private keys from a random start, adding the
+// generator to the public key at each step instead of multiplying a scalar
+// per key. Each batch of points is converted to affine coordinates with a
+// single field inversion, shared using Montgomery's trick. It returns nil
+// when mining is canceled.
+func mineVanity(ctx context.Context, match func(x *[32]byte) bool, attempts *atomic.Uint64) (*PrivateKey, error) {
+	start, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	defer start.Zero()
+	k := start.Key
+	defer k.Zero()
+
+	var one secp256k1.ModNScalar
+	one.SetInt(1)
+	var g, point secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(&one, &g)
+	g.ToAffine()
+	secp256k1.ScalarBaseMultNonConst(&k, &point)
+
+	var (
+		points   [vanityBatchSize]secp256k1.JacobianPoint
+		products [vanityBatchSize]secp256k1.FieldVal
+		last     secp256k1.ModNScalar
+		x        [32]byte
+	)
+	defer last.Zero()
+	for {
+		// points[i] is the public key of k+i, and products[i] the product
+		// of the Z coordinates of points[0] through points[i]
+		last = k
+		for i := range points {
+			if i > 0 {
+				last.Add(&one)
+				if last.IsZero() {
+					// The walk wrapped around the curve order
+					return mineVanity(ctx, match, attempts)
+				}
+				secp256k1.AddNonConst(&points[i-1], &g, &points[i])
+			} else {
+				points[0].Set(&point)
+			}
+			products[i].Set(&points[i].Z)
+			if i > 0 {
+				products[i].Mul(&products[i-1])
+			}
+		}
+
+		// Working back from the inverse of the product of every Z, the
+		// inverse of each Z is the inverse of the product up to it times the
+		// product before it
+		var inverse, zInv, zInv2 secp256k1.FieldVal
+		inverse.Set(&products[vanityBatchSize-1]).Inverse()
+		for i := vanityBatchSize - 1; i >= 0; i-- {
+			if i > 0 {
+				zInv.Mul2(&inverse, &products[i-1])
+				inverse.Mul(&points[i].Z)
+			} else {
+				zInv.Set(&inverse)
+			}
+			// Reuse the product slot for X/Z^2
+			zInv2.SquareVal(&zInv)
+			products[i].Mul2(&points[i].X, &zInv2).Normalize()
+		}
+
+		for i := range points {
+			products[i].PutBytes(&x)
+			if match(&x) {
+				attempts.Add(uint64(i + 1))
+				var offset secp256k1.ModNScalar
+				offset.SetInt(uint32(i))
+				k.Add(&offset)
+				sk := new(PrivateKey)
+				k.PutBytes((*[32]byte)(sk))
+				return sk, nil
+			}
+		}
+
+		k = last
+		k.Add(&one)
+		if k.IsZero() {
+			return mineVanity(ctx, match, attempts)
+		}
+		secp256k1.AddNonConst(&points[vanityBatchSize-1], &g, &point)
+
+		attempts.Add(vanityBatchSize)
+		if ctx.Err() != nil {
+			return nil, nil
+		}
+	}
+}
+
+// vanityMatcher returns a function reporting whether an x-coordinate
+// matches the pattern. It is not safe for concurrent use.
+func vanityMatcher(pattern string, opts VanityOptions) func(x *[32]byte) bool {
+	if opts.Encoding == VanityHex {
+		buf := make([]byte, 64)
+		return func(x *[32]byte) bool {
+			hex.Encode(buf, x[:])
+			return hasPattern(string(buf), pattern, opts.Suffix)
+		}
+	}
+	return func(x *[32]byte) bool {
+		npub, err := bech32.Encode(NpubPrefix, x[:])
+		if err != nil {
+			return false
+		}
+		return hasPattern(npub[npubPrefixLength:], pattern, opts.Suffix)
+	}
+}
+
+func hasPattern(s, pattern string, suffix bool) bool {
+	if suffix {
+		return strings.HasSuffix(s, pattern)
+	}
+	return strings.HasPrefix(s, pattern)
+}
+
+func vanityProgress(attempts uint64, expected float64, elapsed time.Duration) VanityProgress {
+	progress := VanityProgress{Attempts: attempts, Expected: expected, Elapsed: elapsed}
+	if elapsed > 0 {
+		progress.Rate = float64(attempts) / elapsed.Seconds()
+	}
+	return progress
+}
@@ -0,0 +1,136 @@
+package keys
+
+import (
+	"context"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type MineVanityTestCase struct {
+	name    string
+	pattern string
+	opts    VanityOptions
+	check   func(t *testing.T, key VanityKey)
+}
+
+var mineVanityTestCases = []MineVanityTestCase{
+	{"npub prefix", "qq", VanityOptions{}, func(t *testing.T, key VanityKey) {
+		assert.True(t, strings.HasPrefix(key.Npub, "npub1qq"), key.Npub)
+	}},
+	{"npub suffix", "xy", VanityOptions{Suffix: true}, func(t *testing.T, key VanityKey) {
+		assert.True(t, strings.HasSuffix(key.Npub, "xy"), key.Npub)
+	}},
+	{"upper-case pattern", "Q", VanityOptions{Workers: 1}, func(t *testing.T, key VanityKey) {
+		assert.True(t, strings.HasPrefix(key.Npub, "npub1q"), key.Npub)
+	}},
+	{"hex prefix", "be", VanityOptions{Encoding: VanityHex}, func(t *testing.T, key VanityKey) {
+		assert.True(t, strings.HasPrefix(key.PublicKey, "be"), key.PublicKey)
+	}},
+	{"hex suffix", "00", VanityOptions{Encoding: VanityHex, Suffix: true}, func(t *testing.T, key VanityKey) {
+		assert.True(t, strings.HasSuffix(key.PublicKey, "00"), key.PublicKey)
+	}},
+}
+
+func TestMineVanity(t *testing.T) {
+	for _, tc := range mineVanityTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			key, err := MineVanity(context.Background(), tc.pattern, tc.opts)
+			assert.NoError(t, err)
+			tc.check(t, key)
+
+			// The keypair is consistent
+			pk, err := GetPublicKey(key.PrivateKey)
+			assert.NoError(t, err)
+			assert.Equal(t, pk, key.PublicKey)
+			npub, err := EncodeNpub(key.PublicKey)
+			assert.NoError(t, err)
+			assert.Equal(t, npub, key.Npub)
+			assert.Greater(t, key.Attempts, uint64(0))
+		})
+	}
+}
+
+type VanityPatternTestCase struct {
+	name          string
+	pattern       string
+	opts          VanityOptions
+	expected      float64
+	expectedError string
+}
+
+var vanityPatternTestCases = []VanityPatternTestCase{
+	{"npub", "ace", VanityOptions{}, 32 * 32 * 32, ""},
+	{"hex", "abc", VanityOptions{Encoding: VanityHex}, 16 * 16 * 16, ""},
+	{"npub checksum", "qqqqqqq", VanityOptions{Suffix: true}, 2 * 32 * 32 * 32 * 32 * 32 * 32, ""},
+	{"empty", "", VanityOptions{}, 0, "invalid vanity pattern: must be 1 to 52 characters"},
+	{"not bech32", "xb", VanityOptions{}, 0, `invalid vanity pattern: 'b' is not in the bech32 charset`},
+	{"not bech32 digit", "x1", VanityOptions{}, 0, `invalid vanity pattern: '1' is not in the bech32 charset`},
+	{"not hex", "xyz", VanityOptions{Encoding: VanityHex}, 0, `invalid vanity pattern: 'x' is not a hex character`},
+	{"npub too long", strings.Repeat("q", 53), VanityOptions{}, 0, "invalid vanity pattern: must be 1 to 52 characters"},
+	{"npub suffix too long", strings.Repeat("q", 59), VanityOptions{Suffix: true}, 0, "invalid vanity pattern: must be 1 to 58 characters"},
+	{"hex too long", strings.Repeat("0", 65), VanityOptions{Encoding: VanityHex}, 0, "invalid vanity pattern: must be 1 to 64 characters"},
+	{"padding character", strings.Repeat("q", 51) + "p", VanityOptions{}, 0, "invalid vanity pattern: character 52 must be q or s"},
+	{"padding character in suffix", "p" + strings.Repeat("q", 6), VanityOptions{Suffix: true}, 0, "invalid vanity pattern: character 52 must be q or s"},
+}
+
+func TestEstimateVanityAttempts(t *testing.T) {
+	for _, tc := range vanityPatternTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expected, err := EstimateVanityAttempts(tc.pattern, tc.opts)
+			if tc.expectedError != "" {
+				assert.ErrorContains(t, err, tc.expectedError)
+				_, err = MineVanity(context.Background(), tc.pattern, tc.opts)
+				assert.ErrorContains(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, expected)
+		})
+	}
+}
+
+func TestMineVanityCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	var reports atomic.Int32
+	var last atomic.Value
+	_, err := MineVanity(ctx, "qqqqqqqqqq", VanityOptions{
+		Workers:          2,
+		ProgressInterval: 10 * time.Millisecond,
+		Progress: func(p VanityProgress) {
+			reports.Add(1)
+			last.Store(p)
+		},
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Greater(t, reports.Load(), int32(0))
+
+	progress := last.Load().(VanityProgress)
+	assert.Greater(t, progress.Attempts, uint64(0))
+	assert.Greater(t, progress.Rate, 0.0)
+	assert.Equal(t, float64(uint64(1)<<50), progress.Expected)
+}
+
+func TestMineVanityBatch(t *testing.T) {
+	// Accept a key deep in the second batch, checking that the batched
+	// affine conversion matches the key's public key
+	var calls int
+	var matched [32]byte
+	match := func(x *[32]byte) bool {
+		calls++
+		matched = *x
+		return calls == vanityBatchSize+200
+	}
+	var attempts atomic.Uint64
+	sk, err := mineVanity(context.Background(), match, &attempts)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(vanityBatchSize+200), attempts.Load())
+
+	pk := sk.PublicKey()
+	assert.Equal(t, hex.EncodeToString(matched[:]), hex.EncodeToString(pk[:]))
+}
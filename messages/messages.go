@@ -0,0 +1,258 @@
+// Package messages encodes and decodes the NIP-01 messages exchanged between
+// clients and relays.
+package messages
+
+import (
+	"encoding/json"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"strings"
+)
+
+// Message labels, the first element of every message array.
+const (
+	EventLabel  = "EVENT"
+	ReqLabel    = "REQ"
+	CloseLabel  = "CLOSE"
+	OKLabel     = "OK"
+	EOSELabel   = "EOSE"
+	ClosedLabel = "CLOSED"
+	NoticeLabel = "NOTICE"
+	AuthLabel   = "AUTH"
+)
+
+// Machine-readable prefixes of OK and CLOSED messages.
+const (
+	Duplicate    = "duplicate"
+	PoW          = "pow"
+	Blocked      = "blocked"
+	RateLimited  = "rate-limited"
+	Invalid      = "invalid"
+	Restricted   = "restricted"
+	Error        = "error"
+	AuthRequired = "auth-required"
+)
+
+// Message is a decoded NIP-01 message.
+type Message interface {
+	Label() string
+}
+
+// Event carries an event. Clients publish events with an empty
+// SubscriptionID; relays deliver events with the subscription they match.
+type Event struct {
+	SubscriptionID string
+	Event          events.Event
+}
+
+// Req opens or replaces a subscription.
+type Req struct {
+	SubscriptionID string
+	Filters        []filters.Filter
+}
+
+// Close ends a subscription.
+type Close struct {
+	SubscriptionID string
+}
+
+// OK reports whether a published event was accepted.
+type OK struct {
+	EventID  string
+	Accepted bool
+	Message  string
+}
+
+// EOSE marks the end of stored events for a subscription.
+type EOSE struct {
+	SubscriptionID string
+}
+
+// Closed reports that the relay ended a subscription.
+type Closed struct {
+	SubscriptionID string
+	Message        string
+}
+
+// Notice carries a human-readable message from the relay.
+type Notice struct {
+	Message string
+}
+
+// AuthChallenge asks the client to authenticate, as defined by NIP-42.
+type AuthChallenge struct {
+	Challenge string
+}
+
+// Auth carries a client's signed authentication event, as defined by NIP-42.
+type Auth struct {
+	Event events.Event
+}
+
+func (Event) Label() string  { return EventLabel }
+func (Req) Label() string    { return ReqLabel }
+func (Close) Label() string  { return CloseLabel }
+func (OK) Label() string     { return OKLabel }
+func (EOSE) Label() string   { return EOSELabel }
+func (Closed) Label() string { return ClosedLabel }
+func (Notice) Label() string { return NoticeLabel }
+
+func (AuthChallenge) Label() string { return AuthLabel }
+func (Auth) Label() string          { return AuthLabel }
+
+// Reason joins a machine-readable prefix and a human-readable message in
+// the "prefix: message" form used by OK and CLOSED messages.
+func Reason(prefix, message string) string {
+	return prefix + ": " + message
+}
+
+// ReasonPrefix returns the machine-readable prefix of an OK or CLOSED
+// message, or an empty string if it has none.
+func ReasonPrefix(message string) string {
+	prefix, _, found := strings.Cut(message, ":")
+	if !found || strings.ContainsRune(prefix, ' ') {
+		return ""
+	}
+	return prefix
+}
+
+// Marshal encodes a message as a JSON array.
+func Marshal(m Message) ([]byte, error) {
+	var elements []interface{}
+
+	switch m := m.(type) {
+	case Event:
+		if m.SubscriptionID == "" {
+			elements = []interface{}{EventLabel, m.Event}
+		} else {
+			elements = []interface{}{EventLabel, m.SubscriptionID, m.Event}
+		}
+	case Req:
+		elements = []interface{}{ReqLabel, m.SubscriptionID}
+		for _, f := range m.Filters {
+			data, err := filters.MarshalJSON(f)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, json.RawMessage(data))
+		}
+	case Close:
+		elements = []interface{}{CloseLabel, m.SubscriptionID}
+	case OK:
+		elements = []interface{}{OKLabel, m.EventID, m.Accepted, m.Message}
+	case EOSE:
+		elements = []interface{}{EOSELabel, m.SubscriptionID}
+	case Closed:
+		elements = []interface{}{ClosedLabel, m.SubscriptionID, m.Message}
+	case Notice:
+		elements = []interface{}{NoticeLabel, m.Message}
+	case AuthChallenge:
+		elements = []interface{}{AuthLabel, m.Challenge}
+	case Auth:
+		elements = []interface{}{AuthLabel, m.Event}
+	default:
+		return nil, errors.UnknownMessage
+	}
+
+	return json.Marshal(elements)
+}
+
+// Unmarshal decodes a JSON array into the message type named by its label.
+func Unmarshal(data []byte) (Message, error) {
+	var elements []json.RawMessage
+	if err := json.Unmarshal(data, &elements); err != nil || len(elements) == 0 {
+		return nil, errors.MalformedMessage
+	}
+	var label string
+	if err := json.Unmarshal(elements[0], &label); err != nil {
+		return nil, errors.MalformedMessage
+	}
+	args := elements[1:]
+
+	var m Message
+	var err error
+
+	switch label {
+	case EventLabel:
+		e := Event{}
+		switch len(args) {
+		case 1:
+			err = decode(args, &e.Event)
+		case 2:
+			err = decode(args, &e.SubscriptionID, &e.Event)
+		default:
+			err = errors.MalformedMessage
+		}
+		m = e
+	case ReqLabel:
+		if len(args) < 1 {
+			return nil, errors.MalformedMessage
+		}
+		req := Req{Filters: make([]filters.Filter, len(args)-1)}
+		err = decode(args[:1], &req.SubscriptionID)
+		for i, raw := range args[1:] {
+			if err == nil && filters.UnmarshalJSON(raw, &req.Filters[i]) != nil {
+				err = errors.MalformedMessage
+			}
+		}
+		m = req
+	case CloseLabel:
+		c := Close{}
+		err = decodeExactly(args, &c.SubscriptionID)
+		m = c
+	case OKLabel:
+		ok := OK{}
+		err = decodeExactly(args, &ok.EventID, &ok.Accepted, &ok.Message)
+		m = ok
+	case EOSELabel:
+		eose := EOSE{}
+		err = decodeExactly(args, &eose.SubscriptionID)
+		m = eose
+	case ClosedLabel:
+		closed := Closed{}
+		err = decodeExactly(args, &closed.SubscriptionID, &closed.Message)
+		m = closed
+	case NoticeLabel:
+		notice := Notice{}
+		err = decodeExactly(args, &notice.Message)
+		m = notice
+	case AuthLabel:
+		// Relays send a challenge string, clients send an event object
+		if len(args) == 1 && len(args[0]) > 0 && args[0][0] == '"' {
+			challenge := AuthChallenge{}
+			err = decode(args, &challenge.Challenge)
+			m = challenge
+		} else {
+			auth := Auth{}
+			err = decodeExactly(args, &auth.Event)
+			m = auth
+		}
+	default:
+		return nil, errors.UnknownMessage
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// decodeExactly unmarshals the elements into the targets, requiring one
+// element per target.
+func decodeExactly(elements []json.RawMessage, targets ...interface{}) error {
+	if len(elements) != len(targets) {
+		return errors.MalformedMessage
+	}
+	return decode(elements, targets...)
+}
+
+// decode unmarshals each element into the matching target.
+func decode(elements []json.RawMessage, targets ...interface{}) error {
+	for i, target := range targets {
+		if err := json.Unmarshal(elements[i], target); err != nil {
+			return errors.MalformedMessage
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package messages
+
+import (
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var testEvent = events.Event{
+	ID:        "c7a702e6158744ca03508bbb4c90f9dbb0d6e88fefbfaa511d5ab24b4e3c48ad",
+	PubKey:    "cfa87f35acbde29ba1ab3ee42de527b2cad33ac487e80cf2d6405ea0042c8fef",
+	CreatedAt: 1760740551,
+	Kind:      1,
+	Tags:      []events.Tag{},
+	Content:   "hello world",
+	Sig:       "83b71e15649c9e9da362c175f988c36404cabf357a976d869102a74451cfb8af486f6088b5631033b4927bd46cad7a0d90d7f624aefc0ac260364aa65c36071a",
+}
+
+const testEventJSON = `{"id":"c7a702e6158744ca03508bbb4c90f9dbb0d6e88fefbfaa511d5ab24b4e3c48ad","pubkey":"cfa87f35acbde29ba1ab3ee42de527b2cad33ac487e80cf2d6405ea0042c8fef","created_at":1760740551,"kind":1,"tags":[],"content":"hello world","sig":"83b71e15649c9e9da362c175f988c36404cabf357a976d869102a74451cfb8af486f6088b5631033b4927bd46cad7a0d90d7f624aefc0ac260364aa65c36071a"}`
+
+type MessageRoundTripTestCase struct {
+	name    string
+	message Message
+	json    string
+}
+
+var messageRoundTripTestCases = []MessageRoundTripTestCase{
+	{
+		name:    "client event",
+		message: Event{Event: testEvent},
+		json:    `["EVENT",` + testEventJSON + `]`,
+	},
+
+	{
+		name:    "relay event",
+		message: Event{SubscriptionID: "sub", Event: testEvent},
+		json:    `["EVENT","sub",` + testEventJSON + `]`,
+	},
+
+	{
+		name: "req",
+		message: Req{
+			SubscriptionID: "sub",
+			Filters: []filters.Filter{
+				{Kinds: []int{1}},
+				{Authors: []string{"cfa87f35"}, Tags: filters.TagFilters{"e": {"abc"}}},
+			},
+		},
+		json: `["REQ","sub",{"kinds":[1]},{"#e":["abc"],"authors":["cfa87f35"]}]`,
+	},
+
+	{
+		name:    "req without filters",
+		message: Req{SubscriptionID: "sub", Filters: []filters.Filter{}},
+		json:    `["REQ","sub"]`,
+	},
+
+	{
+		name:    "close",
+		message: Close{SubscriptionID: "sub"},
+		json:    `["CLOSE","sub"]`,
+	},
+
+	{
+		name:    "ok",
+		message: OK{EventID: testEvent.ID, Accepted: false, Message: "invalid: bad signature"},
+		json:    `["OK","` + testEvent.ID + `",false,"invalid: bad signature"]`,
+	},
+
+	{
+		name:    "eose",
+		message: EOSE{SubscriptionID: "sub"},
+		json:    `["EOSE","sub"]`,
+	},
+
+	{
+		name:    "closed",
+		message: Closed{SubscriptionID: "sub", Message: "error: shutting down"},
+		json:    `["CLOSED","sub","error: shutting down"]`,
+	},
+
+	{
+		name:    "notice",
+		message: Notice{Message: "hello"},
+		json:    `["NOTICE","hello"]`,
+	},
+
+	{
+		name:    "auth challenge",
+		message: AuthChallenge{Challenge: "challengestring"},
+		json:    `["AUTH","challengestring"]`,
+	},
+
+	{
+		name:    "auth",
+		message: Auth{Event: testEvent},
+		json:    `["AUTH",` + testEventJSON + `]`,
+	},
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	for _, tc := range messageRoundTripTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := Marshal(tc.message)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tc.json, string(data))
+
+			m, err := Unmarshal([]byte(tc.json))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.message, m)
+		})
+	}
+}
+
+type UnmarshalErrorTestCase struct {
+	name          string
+	input         string
+	expectedError string
+}
+
+var unmarshalErrorTestCases = []UnmarshalErrorTestCase{
+	{name: "not json", input: `EVENT`, expectedError: "malformed message"},
+	{name: "object", input: `{"EVENT":1}`, expectedError: "malformed message"},
+	{name: "empty array", input: `[]`, expectedError: "malformed message"},
+	{name: "numeric label", input: `[1,"sub"]`, expectedError: "malformed message"},
+	{name: "unknown label", input: `["HELLO","sub"]`, expectedError: "unknown message type"},
+	{name: "event without payload", input: `["EVENT"]`, expectedError: "malformed message"},
+	{name: "event with extra elements", input: `["EVENT","a","b","c"]`, expectedError: "malformed message"},
+	{name: "event not object", input: `["EVENT","sub","event"]`, expectedError: "malformed message"},
+	{name: "req without id", input: `["REQ"]`, expectedError: "malformed message"},
+	{name: "req bad filter", input: `["REQ","sub",{"kinds":"1"}]`, expectedError: "malformed message"},
+	{name: "close extra element", input: `["CLOSE","sub","x"]`, expectedError: "malformed message"},
+	{name: "ok missing message", input: `["OK","id",true]`, expectedError: "malformed message"},
+	{name: "ok string accepted", input: `["OK","id","true",""]`, expectedError: "malformed message"},
+	{name: "notice number", input: `["NOTICE",1]`, expectedError: "malformed message"},
+	{name: "auth without payload", input: `["AUTH"]`, expectedError: "malformed message"},
+	{name: "auth number", input: `["AUTH",1]`, expectedError: "malformed message"},
+	{name: "auth extra element", input: `["AUTH","a","b"]`, expectedError: "malformed message"},
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for _, tc := range unmarshalErrorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Unmarshal([]byte(tc.input))
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestReason(t *testing.T) {
+	assert.Equal(t, "duplicate: already have this event", Reason(Duplicate, "already have this event"))
+	assert.Equal(t, "rate-limited", ReasonPrefix("rate-limited: slow down"))
+	assert.Equal(t, "", ReasonPrefix("no prefix here: at all"))
+	assert.Equal(t, "", ReasonPrefix(""))
+}
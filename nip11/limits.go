@@ -0,0 +1,78 @@
+package nip11
+
+import (
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"git.wisehodl.dev/jay/go-roots/messages"
+	"git.wisehodl.dev/jay/go-roots/nip13"
+	"unicode/utf8"
+)
+
+// CheckEvent returns an error if publishing the event would violate the
+// limitation. The created_at bounds are checked relative to now, a Unix
+// timestamp.
+func CheckEvent(e events.Event, l Limitation, now int) error {
+	if l.MaxMessageLength != nil {
+		data, err := messages.Marshal(messages.Event{Event: e})
+		if err != nil {
+			return err
+		}
+		if len(data) > *l.MaxMessageLength {
+			return fmt.Errorf("%w: %d bytes, maximum %d", errors.MessageTooLong, len(data), *l.MaxMessageLength)
+		}
+	}
+
+	if l.MaxEventTags != nil && len(e.Tags) > *l.MaxEventTags {
+		return fmt.Errorf("%w: %d tags, maximum %d", errors.TooManyTags, len(e.Tags), *l.MaxEventTags)
+	}
+
+	if l.MaxContentLength != nil {
+		// The limit is in characters, not bytes
+		length := utf8.RuneCountInString(e.Content)
+		if length > *l.MaxContentLength {
+			return fmt.Errorf("%w: %d characters, maximum %d", errors.ContentTooLong, length, *l.MaxContentLength)
+		}
+	}
+
+	if l.MinPowDifficulty != nil {
+		difficulty := nip13.Difficulty(e.ID)
+		if difficulty < *l.MinPowDifficulty {
+			return fmt.Errorf("%w: difficulty %d, minimum %d", errors.InsufficientPoW, difficulty, *l.MinPowDifficulty)
+		}
+	}
+
+	if l.CreatedAtLowerLimit != nil && e.CreatedAt < now-*l.CreatedAtLowerLimit {
+		return fmt.Errorf("%w: more than %d seconds in the past", errors.CreatedAtOutOfRange, *l.CreatedAtLowerLimit)
+	}
+	if l.CreatedAtUpperLimit != nil && e.CreatedAt > now+*l.CreatedAtUpperLimit {
+		return fmt.Errorf("%w: more than %d seconds in the future", errors.CreatedAtOutOfRange, *l.CreatedAtUpperLimit)
+	}
+
+	return nil
+}
+
+// CheckFilter returns an error if the filter requests more events than the
+// limitation allows.
+func CheckFilter(f filters.Filter, l Limitation) error {
+	if l.MaxLimit != nil && f.Limit != nil && *f.Limit > *l.MaxLimit {
+		return fmt.Errorf("%w: %d, maximum %d", errors.LimitTooHigh, *f.Limit, *l.MaxLimit)
+	}
+	return nil
+}
+
+// ClampFilter returns a copy of the filter with its limit set the way a
+// relay applies the limitation: the default limit when none is given,
+// reduced to the maximum limit.
+func ClampFilter(f filters.Filter, l Limitation) filters.Filter {
+	if f.Limit == nil && l.DefaultLimit != nil {
+		limit := *l.DefaultLimit
+		f.Limit = &limit
+	}
+	if f.Limit != nil && l.MaxLimit != nil && *f.Limit > *l.MaxLimit {
+		limit := *l.MaxLimit
+		f.Limit = &limit
+	}
+	return f
+}
@@ -0,0 +1,133 @@
+package nip11
+
+import (
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+const now = 1700000000
+
+var limitEvent = events.Event{
+	ID:        "000f5a7e2c9e8e1cdbd2a6d1ad9b39b6b2e3a34fa5e1c0e1bb48b3b1a6e1d6c1",
+	PubKey:    "cfa87f35acbde29ba1ab3ee42de527b2cad33ac487e80cf2d6405ea0042c8fef",
+	CreatedAt: now,
+	Kind:      1,
+	Tags:      []events.Tag{{"t", "a"}, {"t", "b"}},
+	Content:   "héllo",
+	Sig:       strings.Repeat("0", 128),
+}
+
+type CheckEventTestCase struct {
+	name          string
+	event         func(e events.Event) events.Event
+	limitation    Limitation
+	expectedError string
+}
+
+func unchanged(e events.Event) events.Event { return e }
+
+var checkEventTestCases = []CheckEventTestCase{
+	{
+		name:       "no limits",
+		event:      unchanged,
+		limitation: Limitation{},
+	},
+
+	{
+		name:  "within every limit",
+		event: unchanged,
+		limitation: Limitation{
+			MaxMessageLength:    intPtr(1000),
+			MaxEventTags:        intPtr(2),
+			MaxContentLength:    intPtr(5),
+			MinPowDifficulty:    intPtr(12),
+			CreatedAtLowerLimit: intPtr(0),
+			CreatedAtUpperLimit: intPtr(0),
+		},
+	},
+
+	{
+		name:          "message too long",
+		event:         unchanged,
+		limitation:    Limitation{MaxMessageLength: intPtr(100)},
+		expectedError: "message is too long",
+	},
+
+	{
+		name:          "too many tags",
+		event:         unchanged,
+		limitation:    Limitation{MaxEventTags: intPtr(1)},
+		expectedError: "event has too many tags: 2 tags, maximum 1",
+	},
+
+	{
+		name:          "content counted in characters",
+		event:         unchanged,
+		limitation:    Limitation{MaxContentLength: intPtr(4)},
+		expectedError: "event content is too long: 5 characters, maximum 4",
+	},
+
+	{
+		name:          "insufficient pow",
+		event:         unchanged,
+		limitation:    Limitation{MinPowDifficulty: intPtr(13)},
+		expectedError: "event proof of work is insufficient: difficulty 12, minimum 13",
+	},
+
+	{
+		name: "too old",
+		event: func(e events.Event) events.Event {
+			e.CreatedAt = now - 3601
+			return e
+		},
+		limitation:    Limitation{CreatedAtLowerLimit: intPtr(3600)},
+		expectedError: "event created_at is out of range: more than 3600 seconds in the past",
+	},
+
+	{
+		name: "too far in the future",
+		event: func(e events.Event) events.Event {
+			e.CreatedAt = now + 301
+			return e
+		},
+		limitation:    Limitation{CreatedAtUpperLimit: intPtr(300)},
+		expectedError: "event created_at is out of range: more than 300 seconds in the future",
+	},
+}
+
+func TestCheckEvent(t *testing.T) {
+	for _, tc := range checkEventTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := CheckEvent(tc.event(limitEvent), tc.limitation, now)
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestCheckFilter(t *testing.T) {
+	l := Limitation{MaxLimit: intPtr(100)}
+	assert.NoError(t, CheckFilter(filters.Filter{}, l))
+	assert.NoError(t, CheckFilter(filters.Filter{Limit: intPtr(100)}, l))
+	assert.ErrorContains(t, CheckFilter(filters.Filter{Limit: intPtr(101)}, l), "filter limit is too high: 101, maximum 100")
+	assert.NoError(t, CheckFilter(filters.Filter{Limit: intPtr(101)}, Limitation{}))
+}
+
+func TestClampFilter(t *testing.T) {
+	l := Limitation{MaxLimit: intPtr(100), DefaultLimit: intPtr(20)}
+	assert.Equal(t, intPtr(20), ClampFilter(filters.Filter{}, l).Limit)
+	assert.Equal(t, intPtr(50), ClampFilter(filters.Filter{Limit: intPtr(50)}, l).Limit)
+	assert.Equal(t, intPtr(100), ClampFilter(filters.Filter{Limit: intPtr(500)}, l).Limit)
+	assert.Nil(t, ClampFilter(filters.Filter{}, Limitation{MaxLimit: intPtr(100)}).Limit)
+
+	// The original filter is unchanged
+	f := filters.Filter{Limit: intPtr(500)}
+	ClampFilter(f, l)
+	assert.Equal(t, 500, *f.Limit)
+}
@@ -0,0 +1,162 @@
+// Package nip11 describes relays with the NIP-11 relay information document,
+// and checks events and filters against the limitations it advertises.
+package nip11
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// MediaType is the Accept header value that requests a relay information
+// document.
+const MediaType = "application/nostr+json"
+
+// Extensions holds document fields not defined by this package as raw JSON.
+type Extensions map[string]json.RawMessage
+
+// Document is a relay information document. Empty fields are omitted from
+// JSON.
+type Document struct {
+	Name           string      `json:"name,omitempty"`
+	Description    string      `json:"description,omitempty"`
+	Banner         string      `json:"banner,omitempty"`
+	Icon           string      `json:"icon,omitempty"`
+	PubKey         string      `json:"pubkey,omitempty"`
+	Self           string      `json:"self,omitempty"`
+	Contact        string      `json:"contact,omitempty"`
+	SupportedNIPs  []int       `json:"supported_nips,omitempty"`
+	Software       string      `json:"software,omitempty"`
+	Version        string      `json:"version,omitempty"`
+	PrivacyPolicy  string      `json:"privacy_policy,omitempty"`
+	TermsOfService string      `json:"terms_of_service,omitempty"`
+	PostingPolicy  string      `json:"posting_policy,omitempty"`
+	PaymentsURL    string      `json:"payments_url,omitempty"`
+	RelayCountries []string    `json:"relay_countries,omitempty"`
+	LanguageTags   []string    `json:"language_tags,omitempty"`
+	Tags           []string    `json:"tags,omitempty"`
+	Limitation     *Limitation `json:"limitation,omitempty"`
+
+	// Extensions holds every other field, such as retention and fees.
+	Extensions Extensions `json:"-"`
+}
+
+// Limitation holds the limits a relay enforces. Nil fields are not limited.
+//
+// CreatedAtLowerLimit and CreatedAtUpperLimit are relative to the current
+// time: events must be created no more than CreatedAtLowerLimit seconds in
+// the past and no more than CreatedAtUpperLimit seconds in the future.
+type Limitation struct {
+	MaxMessageLength    *int  `json:"max_message_length,omitempty"`
+	MaxSubscriptions    *int  `json:"max_subscriptions,omitempty"`
+	MaxLimit            *int  `json:"max_limit,omitempty"`
+	MaxSubIDLength      *int  `json:"max_subid_length,omitempty"`
+	MaxEventTags        *int  `json:"max_event_tags,omitempty"`
+	MaxContentLength    *int  `json:"max_content_length,omitempty"`
+	MinPowDifficulty    *int  `json:"min_pow_difficulty,omitempty"`
+	AuthRequired        *bool `json:"auth_required,omitempty"`
+	PaymentRequired     *bool `json:"payment_required,omitempty"`
+	RestrictedWrites    *bool `json:"restricted_writes,omitempty"`
+	CreatedAtLowerLimit *int  `json:"created_at_lower_limit,omitempty"`
+	CreatedAtUpperLimit *int  `json:"created_at_upper_limit,omitempty"`
+	DefaultLimit        *int  `json:"default_limit,omitempty"`
+}
+
+// MarshalJSON converts the document to JSON, merging extensions into the
+// top-level object. Extensions never override defined fields.
+func MarshalJSON(d Document) ([]byte, error) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.Extensions) == 0 {
+		return data, nil
+	}
+
+	outputMap := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &outputMap); err != nil {
+		return nil, err
+	}
+	for key, raw := range d.Extensions {
+		if _, defined := outputMap[key]; defined || isDefinedField(key) {
+			continue
+		}
+		outputMap[key] = raw
+	}
+	return json.Marshal(outputMap)
+}
+
+// UnmarshalJSON parses JSON into the document, placing fields not defined by
+// this package in extensions.
+func UnmarshalJSON(data []byte, d *Document) error {
+	var parsed Document
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+
+	raw := make(Extensions)
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	for key := range raw {
+		if isDefinedField(key) {
+			delete(raw, key)
+		}
+	}
+	if len(raw) > 0 {
+		parsed.Extensions = raw
+	}
+
+	*d = parsed
+	return nil
+}
+
+var definedFields = map[string]struct{}{
+	"name": {}, "description": {}, "banner": {}, "icon": {}, "pubkey": {},
+	"self": {}, "contact": {}, "supported_nips": {}, "software": {},
+	"version": {}, "privacy_policy": {}, "terms_of_service": {},
+	"posting_policy": {}, "payments_url": {}, "relay_countries": {},
+	"language_tags": {}, "tags": {}, "limitation": {},
+}
+
+func isDefinedField(key string) bool {
+	_, ok := definedFields[key]
+	return ok
+}
+
+// Fetch requests the information document of the relay at a ws:// or wss://
+// URL.
+func Fetch(ctx context.Context, relayURL string) (Document, error) {
+	url := relayURL
+	switch {
+	case strings.HasPrefix(url, "wss://"):
+		url = "https://" + strings.TrimPrefix(url, "wss://")
+	case strings.HasPrefix(url, "ws://"):
+		url = "http://" + strings.TrimPrefix(url, "ws://")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Document{}, err
+	}
+	req.Header.Set("Accept", MediaType)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Document{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Document{}, fmt.Errorf("relay information request failed: %s", resp.Status)
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return Document{}, err
+	}
+	var d Document
+	err = UnmarshalJSON(raw, &d)
+	return d, err
+}
@@ -0,0 +1,136 @@
+package nip11
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testDocumentJSON = `{
+	"name": "JellyFish",
+	"description": "Stay Immortal!",
+	"pubkey": "cfa87f35acbde29ba1ab3ee42de527b2cad33ac487e80cf2d6405ea0042c8fef",
+	"contact": "mailto:admin@example.com",
+	"supported_nips": [1, 9, 11, 40, 42],
+	"software": "https://example.com/relay",
+	"version": "1.2.3",
+	"limitation": {
+		"max_message_length": 70000,
+		"max_subscriptions": 350,
+		"max_limit": 5000,
+		"max_subid_length": 256,
+		"max_event_tags": 2000,
+		"max_content_length": 70000,
+		"min_pow_difficulty": 0,
+		"auth_required": false,
+		"payment_required": true,
+		"restricted_writes": true,
+		"created_at_lower_limit": 94608000,
+		"created_at_upper_limit": 300,
+		"default_limit": 500
+	},
+	"retention": [{"kinds": [0, 1, [5, 7], [40, 49]], "time": 3600}],
+	"fees": {"admission": [{"amount": 1000000, "unit": "msats"}]}
+}`
+
+var testDocument = Document{
+	Name:          "JellyFish",
+	Description:   "Stay Immortal!",
+	PubKey:        "cfa87f35acbde29ba1ab3ee42de527b2cad33ac487e80cf2d6405ea0042c8fef",
+	Contact:       "mailto:admin@example.com",
+	SupportedNIPs: []int{1, 9, 11, 40, 42},
+	Software:      "https://example.com/relay",
+	Version:       "1.2.3",
+	Limitation: &Limitation{
+		MaxMessageLength:    intPtr(70000),
+		MaxSubscriptions:    intPtr(350),
+		MaxLimit:            intPtr(5000),
+		MaxSubIDLength:      intPtr(256),
+		MaxEventTags:        intPtr(2000),
+		MaxContentLength:    intPtr(70000),
+		MinPowDifficulty:    intPtr(0),
+		AuthRequired:        boolPtr(false),
+		PaymentRequired:     boolPtr(true),
+		RestrictedWrites:    boolPtr(true),
+		CreatedAtLowerLimit: intPtr(94608000),
+		CreatedAtUpperLimit: intPtr(300),
+		DefaultLimit:        intPtr(500),
+	},
+	Extensions: Extensions{
+		"retention": json.RawMessage(`[{"kinds": [0, 1, [5, 7], [40, 49]], "time": 3600}]`),
+		"fees":      json.RawMessage(`{"admission": [{"amount": 1000000, "unit": "msats"}]}`),
+	},
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	var d Document
+	assert.NoError(t, UnmarshalJSON([]byte(testDocumentJSON), &d))
+	assert.Equal(t, testDocument, d)
+}
+
+func TestMarshalJSON(t *testing.T) {
+	data, err := MarshalJSON(testDocument)
+	assert.NoError(t, err)
+	assert.JSONEq(t, testDocumentJSON, string(data))
+}
+
+func TestRoundTrip(t *testing.T) {
+	data, err := MarshalJSON(testDocument)
+	assert.NoError(t, err)
+
+	var d Document
+	assert.NoError(t, UnmarshalJSON(data, &d))
+	redata, err := MarshalJSON(d)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(data), string(redata))
+}
+
+func TestMarshalEmptyDocument(t *testing.T) {
+	data, err := MarshalJSON(Document{})
+	assert.NoError(t, err)
+	assert.Equal(t, `{}`, string(data))
+}
+
+func TestExtensionsDoNotOverrideFields(t *testing.T) {
+	d := Document{
+		Name: "relay",
+		Extensions: Extensions{
+			"name":       json.RawMessage(`"override"`),
+			"limitation": json.RawMessage(`{"max_limit":1}`),
+			"custom":     json.RawMessage(`true`),
+		},
+	}
+	data, err := MarshalJSON(d)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name":"relay","custom":true}`, string(data))
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var d Document
+	assert.Error(t, UnmarshalJSON([]byte(`[]`), &d))
+	assert.Error(t, UnmarshalJSON([]byte(`{"supported_nips":"1"}`), &d))
+	assert.Error(t, UnmarshalJSON([]byte(`{"limitation":{"max_limit":"1"}}`), &d))
+}
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != MediaType || r.URL.Path == "/missing" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", MediaType)
+		w.Write([]byte(testDocumentJSON))
+	}))
+	defer server.Close()
+
+	d, err := Fetch(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http"))
+	assert.NoError(t, err)
+	assert.Equal(t, testDocument, d)
+
+	_, err = Fetch(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http")+"/missing")
+	assert.ErrorContains(t, err, "404")
+}
@@ -0,0 +1,165 @@
+package nip13
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MineOptions configures mining. The zero value uses the defaults.
+type MineOptions struct {
+	// Workers is the number of goroutines hashing in parallel.
+	// Defaults to the number of CPUs.
+	Workers int
+
+	// Progress, if set, is called every ProgressInterval with the number of
+	// nonces tried so far.
+	Progress func(attempts uint64)
+
+	// ProgressInterval defaults to one second.
+	ProgressInterval time.Duration
+}
+
+// batchSize is the number of nonces a worker tries between checking for
+// cancellation and reporting attempts.
+const batchSize = 1024
+
+// Mine returns a copy of the event with a nonce tag committing to the
+// difficulty and an ID meeting it. An existing nonce tag is replaced. The
+// returned event is unsigned: sign it after mining, since the ID changes.
+// Mining stops with the context's error when it is canceled. A difficulty
+// outside 0 to 256 fails with errors.InvalidDifficulty.
+func Mine(ctx context.Context, e events.Event, difficulty int, opts MineOptions) (events.Event, error) {
+	if difficulty < 0 || difficulty > 256 {
+		return events.Event{}, fmt.Errorf("%w: %d", errors.InvalidDifficulty, difficulty)
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+	if opts.ProgressInterval <= 0 {
+		opts.ProgressInterval = time.Second
+	}
+
+	// Serialize once around a unique marker in place of the nonce, so that
+	// each attempt only hashes the nonce between the two halves
+	marker, err := newMarker()
+	if err != nil {
+		return events.Event{}, err
+	}
+	e = withNonce(e, marker, difficulty)
+	serialized, err := events.Serialize(e)
+	if err != nil {
+		return events.Event{}, err
+	}
+	prefix, suffix, _ := bytes.Cut(serialized, []byte(marker))
+
+	mining, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var attempts atomic.Uint64
+	result := make(chan uint64, 1)
+
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Workers; w++ {
+		wg.Add(1)
+		go func(start uint64) {
+			defer wg.Done()
+			step := uint64(opts.Workers)
+			buf := make([]byte, 0, len(prefix)+20+len(suffix))
+			for n := start; ; {
+				for i := 0; i < batchSize; i++ {
+					buf = append(buf[:0], prefix...)
+					buf = strconv.AppendUint(buf, n, 10)
+					buf = append(buf, suffix...)
+					hash := sha256.Sum256(buf)
+					if difficultyBytes(hash[:]) >= difficulty {
+						select {
+						case result <- n:
+						default:
+						}
+						cancel()
+						return
+					}
+					n += step
+				}
+				attempts.Add(batchSize)
+				if mining.Err() != nil {
+					return
+				}
+			}
+		}(uint64(w))
+	}
+
+	if opts.Progress != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			ticker := time.NewTicker(opts.ProgressInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					opts.Progress(attempts.Load())
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	select {
+	case n := <-result:
+		e = withNonce(e, strconv.FormatUint(n, 10), difficulty)
+		if e.ID, err = events.GetID(e); err != nil {
+			return events.Event{}, err
+		}
+		return e, nil
+	default:
+		return events.Event{}, ctx.Err()
+	}
+}
+
+// withNonce returns a copy of the event with its nonce tag set, unsigned and
+// without an ID.
+func withNonce(e events.Event, nonce string, target int) events.Event {
+	tag := events.Tag{"nonce", nonce, strconv.Itoa(target)}
+	tags := make([]events.Tag, 0, len(e.Tags)+1)
+	replaced := false
+	for _, t := range e.Tags {
+		if len(t) >= 1 && t[0] == "nonce" && !replaced {
+			tags = append(tags, tag)
+			replaced = true
+			continue
+		}
+		tags = append(tags, t)
+	}
+	if !replaced {
+		tags = append(tags, tag)
+	}
+	e.Tags = tags
+	e.ID = ""
+	e.Sig = ""
+	return e
+}
+
+// newMarker returns a random string that cannot occur by chance in a
+// serialized event.
+func newMarker() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,85 @@
+// Package nip13 measures, verifies and mines proof of work on event IDs.
+//
+// The difficulty of an event is the number of leading zero bits of its ID.
+// Mining varies the nonce tag, ["nonce", "<nonce>", "<target>"], whose third
+// element commits to the target difficulty.
+package nip13
+
+import (
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"math/bits"
+	"strconv"
+)
+
+// Difficulty returns the number of leading zero bits of a hex-encoded ID,
+// counting up to the first character that is not hex.
+func Difficulty(id string) int {
+	count := 0
+	for _, c := range id {
+		nibble, err := strconv.ParseUint(string(c), 16, 8)
+		if err != nil {
+			return count
+		}
+		if nibble != 0 {
+			return count + bits.LeadingZeros8(uint8(nibble)) - 4
+		}
+		count += 4
+	}
+	return count
+}
+
+// difficultyBytes returns the number of leading zero bits of a hash.
+func difficultyBytes(hash []byte) int {
+	count := 0
+	for _, b := range hash {
+		if b != 0 {
+			return count + bits.LeadingZeros8(b)
+		}
+		count += 8
+	}
+	return count
+}
+
+// CommittedTarget returns the target difficulty committed in the event's
+// nonce tag.
+func CommittedTarget(e events.Event) (int, bool) {
+	for _, tag := range e.Tags {
+		if len(tag) >= 3 && tag[0] == "nonce" {
+			target, err := strconv.Atoi(tag[2])
+			if err != nil || target < 0 {
+				return 0, false
+			}
+			return target, true
+		}
+	}
+	return 0, false
+}
+
+// Verify checks that the event commits to a target of at least
+// minDifficulty in its nonce tag, and that its ID meets the committed target.
+// Requiring the commitment rejects events that reached the difficulty by
+// chance while mining for a lower target. The ID is recomputed, so that it
+// cannot be chosen freely.
+func Verify(e events.Event, minDifficulty int) error {
+	id, err := events.GetID(e)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errors.FailedIDComp, err)
+	}
+	if id != e.ID {
+		return fmt.Errorf("event id %q does not match computed id %q", e.ID, id)
+	}
+
+	target, ok := CommittedTarget(e)
+	if !ok {
+		return errors.MissingPoWTarget
+	}
+	if target < minDifficulty {
+		return fmt.Errorf("%w: committed target %d, minimum %d", errors.InsufficientPoW, target, minDifficulty)
+	}
+	if difficulty := Difficulty(e.ID); difficulty < target {
+		return fmt.Errorf("%w: difficulty %d, committed target %d", errors.InsufficientPoW, difficulty, target)
+	}
+	return nil
+}
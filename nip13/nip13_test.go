@@ -0,0 +1,217 @@
+package nip13
+
+import (
+	"context"
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testSK = "f43a0435f69529f310bbd1d6263d2fbf0977f54bfe2310cc37ae5904b83bb167"
+const testPK = "cfa87f35acbde29ba1ab3ee42de527b2cad33ac487e80cf2d6405ea0042c8fef"
+
+// specEvent is the example event from NIP-13.
+var specEvent = events.Event{
+	ID:        "000006d8c378af1779d2feebc7603a125d99eca0ccf1085959b307f64e5dd358",
+	PubKey:    "a48380f4cfcc1ad5378294fcac36439770f9c878dd880ffa94bb74ea54a6f243",
+	CreatedAt: 1651794653,
+	Kind:      1,
+	Tags:      []events.Tag{{"nonce", "776797", "20"}},
+	Content:   "It's just me mining my own business",
+	Sig:       "284622fc0a3f4f1303455d5175f7ba962a3300d136085b9566801bc2e0699de0c7e31e44c81fb40ad9049173742e904713c3594a1da0fc5d2382a25c11aba977",
+}
+
+type DifficultyTestCase struct {
+	id       string
+	expected int
+}
+
+var difficultyTestCases = []DifficultyTestCase{
+	{id: "", expected: 0},
+	{id: "f0", expected: 0},
+	{id: "7f", expected: 1},
+	{id: "1f", expected: 3},
+	{id: "0f", expected: 4},
+	{id: "00", expected: 8},
+	{id: "000f", expected: 12},
+	{id: "002f", expected: 10},
+	{id: "000006d8c378af1779d2feebc7603a125d99eca0ccf1085959b307f64e5dd358", expected: 21},
+	{id: "000000000e9d97a1ab09fc381030b346cdd7a142ad57e6df0b46dc9bef6c7e2d", expected: 36},
+	{id: "00zz", expected: 8},
+}
+
+func TestDifficulty(t *testing.T) {
+	for _, tc := range difficultyTestCases {
+		t.Run(tc.id, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Difficulty(tc.id))
+		})
+	}
+}
+
+func TestDifficultyBytes(t *testing.T) {
+	assert.Equal(t, 0, difficultyBytes([]byte{0x80}))
+	assert.Equal(t, 13, difficultyBytes([]byte{0x00, 0x04, 0xff}))
+	assert.Equal(t, 16, difficultyBytes([]byte{0x00, 0x00}))
+}
+
+func TestCommittedTarget(t *testing.T) {
+	target, ok := CommittedTarget(specEvent)
+	assert.True(t, ok)
+	assert.Equal(t, 20, target)
+
+	for _, tags := range [][]events.Tag{
+		{},
+		{{"nonce", "1"}},
+		{{"nonce", "1", "twenty"}},
+		{{"nonce", "1", "-1"}},
+	} {
+		_, ok := CommittedTarget(events.Event{Tags: tags})
+		assert.False(t, ok)
+	}
+}
+
+type VerifyTestCase struct {
+	name          string
+	event         func() events.Event
+	minDifficulty int
+	expectedError string
+}
+
+func specWithTags(tags []events.Tag) func() events.Event {
+	return func() events.Event {
+		e := specEvent
+		e.Tags = tags
+		e.ID, _ = events.GetID(e)
+		return e
+	}
+}
+
+var verifyTestCases = []VerifyTestCase{
+	{
+		name:          "spec example",
+		event:         func() events.Event { return specEvent },
+		minDifficulty: 20,
+	},
+
+	{
+		name:          "below relay minimum",
+		event:         func() events.Event { return specEvent },
+		minDifficulty: 21,
+		expectedError: "event proof of work is insufficient: committed target 20, minimum 21",
+	},
+
+	{
+		name:          "no commitment",
+		event:         specWithTags([]events.Tag{{"nonce", "776797"}}),
+		minDifficulty: 0,
+		expectedError: "event has no committed proof of work target",
+	},
+
+	{
+		name:          "commitment not met",
+		event:         specWithTags([]events.Tag{{"nonce", "776797", "30"}}),
+		minDifficulty: 0,
+		expectedError: "event proof of work is insufficient",
+	},
+
+	{
+		name: "forged id",
+		event: func() events.Event {
+			e := specEvent
+			e.Content = "changed"
+			return e
+		},
+		minDifficulty: 0,
+		expectedError: "does not match computed id",
+	},
+}
+
+func TestVerify(t *testing.T) {
+	for _, tc := range verifyTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Verify(tc.event(), tc.minDifficulty)
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func unsignedNote() events.Event {
+	return events.Event{
+		PubKey:    testPK,
+		CreatedAt: 1700000000,
+		Kind:      1,
+		Tags:      []events.Tag{{"t", "pow"}},
+		Content:   "mined",
+	}
+}
+
+func TestMine(t *testing.T) {
+	e := unsignedNote()
+	mined, err := Mine(context.Background(), e, 12, MineOptions{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, Verify(mined, 12))
+	assert.GreaterOrEqual(t, Difficulty(mined.ID), 12)
+	assert.Equal(t, events.Tag{"t", "pow"}, mined.Tags[0])
+	assert.Equal(t, "nonce", mined.Tags[1][0])
+	assert.Equal(t, "12", mined.Tags[1][2])
+	assert.Equal(t, "", mined.Sig)
+
+	// The input is unchanged
+	assert.Equal(t, unsignedNote(), e)
+
+	// The mined event can be signed and validated
+	mined.Sig, err = events.SignEvent(mined.ID, testSK)
+	assert.NoError(t, err)
+	assert.NoError(t, events.Validate(mined))
+}
+
+func TestMineReplacesNonce(t *testing.T) {
+	e := unsignedNote()
+	e.Tags = append(e.Tags, events.Tag{"nonce", "5", "1"})
+	mined, err := Mine(context.Background(), e, 8, MineOptions{Workers: 1})
+	assert.NoError(t, err)
+
+	assert.Len(t, mined.Tags, 2)
+	assert.Equal(t, "8", mined.Tags[1][2])
+	assert.NoError(t, Verify(mined, 8))
+}
+
+func TestMineZeroDifficulty(t *testing.T) {
+	mined, err := Mine(context.Background(), unsignedNote(), 0, MineOptions{Workers: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, events.Tag{"nonce", "0", "0"}, mined.Tags[1])
+}
+
+func TestMineInvalidDifficulty(t *testing.T) {
+	for _, difficulty := range []int{-1, 257} {
+		_, err := Mine(context.Background(), unsignedNote(), difficulty, MineOptions{Workers: 1})
+		assert.ErrorContains(t, err, fmt.Sprintf("difficulty must be between 0 and 256: %d", difficulty))
+	}
+}
+
+func TestMineCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	var reports atomic.Int32
+	var last atomic.Uint64
+	_, err := Mine(ctx, unsignedNote(), 200, MineOptions{
+		Workers:          2,
+		ProgressInterval: 10 * time.Millisecond,
+		Progress: func(attempts uint64) {
+			reports.Add(1)
+			last.Store(attempts)
+		},
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Greater(t, reports.Load(), int32(0))
+	assert.Greater(t, last.Load(), uint64(0))
+}
@@ -0,0 +1,161 @@
+package nip17
+
+import (
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/keys"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Conversation is the messages exchanged within one set of participants.
+type Conversation struct {
+	// Participants are the sorted public keys of the sender and recipients,
+	// including the inbox owner.
+	Participants []string
+
+	// Subject is the subject of the newest message that set one.
+	Subject string
+
+	// Messages are ordered oldest first.
+	Messages []events.Event
+}
+
+// ConversationKey identifies the conversation between a set of
+// participants, in any order and with any repetition.
+func ConversationKey(participants ...string) string {
+	unique := make(map[string]struct{}, len(participants))
+	sorted := make([]string, 0, len(participants))
+	for _, pk := range participants {
+		if _, ok := unique[pk]; !ok {
+			unique[pk] = struct{}{}
+			sorted = append(sorted, pk)
+		}
+	}
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+// participants returns the sender and recipients of a message.
+func participants(message events.Event) []string {
+	return append(Recipients(message), message.PubKey)
+}
+
+// Inbox collects the messages received by one user into conversations.
+type Inbox struct {
+	privateKey string
+	publicKey  string
+
+	mu            sync.Mutex
+	conversations map[string]*Conversation
+	seen          map[string]struct{}
+}
+
+// NewInbox returns an empty inbox for the owner of the private key.
+func NewInbox(privateKey string) (*Inbox, error) {
+	publicKey, err := keys.GetPublicKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Inbox{
+		privateKey:    privateKey,
+		publicKey:     publicKey,
+		conversations: make(map[string]*Conversation),
+		seen:          make(map[string]struct{}),
+	}, nil
+}
+
+// Add opens a gift wrap and files its message under its conversation,
+// returning the message. A message already in the inbox, such as one
+// received from several relays, is returned without being added again.
+func (in *Inbox) Add(wrap events.Event) (events.Event, error) {
+	message, err := Open(wrap, in.privateKey)
+	if err != nil {
+		return events.Event{}, err
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if _, ok := in.seen[message.ID]; ok {
+		return message, nil
+	}
+	in.seen[message.ID] = struct{}{}
+
+	key := ConversationKey(participants(message)...)
+	c, ok := in.conversations[key]
+	if !ok {
+		c = &Conversation{Participants: strings.Split(key, ",")}
+		in.conversations[key] = c
+	}
+
+	i := sort.Search(len(c.Messages), func(i int) bool {
+		return before(message, c.Messages[i])
+	})
+	c.Messages = append(c.Messages, events.Event{})
+	copy(c.Messages[i+1:], c.Messages[i:])
+	c.Messages[i] = message
+
+	c.Subject = ""
+	for _, m := range c.Messages {
+		if subject := tagValue(m, "subject"); subject != "" {
+			c.Subject = subject
+		}
+	}
+	return message, nil
+}
+
+// Conversation returns the conversation between the inbox owner and the
+// other participants, and whether it has any messages.
+func (in *Inbox) Conversation(others ...string) (Conversation, bool) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	c, ok := in.conversations[ConversationKey(append([]string{in.publicKey}, others...)...)]
+	if !ok {
+		return Conversation{}, false
+	}
+	return c.copy(), true
+}
+
+// Conversations returns every conversation, the most recently active first.
+func (in *Inbox) Conversations() []Conversation {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	cs := make([]Conversation, 0, len(in.conversations))
+	for _, c := range in.conversations {
+		cs = append(cs, c.copy())
+	}
+	sort.Slice(cs, func(i, j int) bool {
+		return before(cs[j].latest(), cs[i].latest())
+	})
+	return cs
+}
+
+func (c *Conversation) copy() Conversation {
+	return Conversation{
+		Participants: append([]string{}, c.Participants...),
+		Subject:      c.Subject,
+		Messages:     append([]events.Event{}, c.Messages...),
+	}
+}
+
+func (c *Conversation) latest() events.Event {
+	return c.Messages[len(c.Messages)-1]
+}
+
+// before orders messages by creation time, then by ID.
+func before(a, b events.Event) bool {
+	if a.CreatedAt != b.CreatedAt {
+		return a.CreatedAt < b.CreatedAt
+	}
+	return a.ID < b.ID
+}
+
+func tagValue(e events.Event, name string) string {
+	for _, tag := range e.Tags {
+		if len(tag) >= 2 && tag[0] == name {
+			return tag[1]
+		}
+	}
+	return ""
+}
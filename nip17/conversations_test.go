@@ -0,0 +1,104 @@
+package nip17
+
+import (
+	"git.wisehodl.dev/jay/go-roots/events"
+	"github.com/stretchr/testify/assert"
+	"sort"
+	"testing"
+)
+
+func TestConversationKey(t *testing.T) {
+	assert.Equal(t, "a,b,c", ConversationKey("c", "a", "b", "a"))
+	assert.Equal(t, ConversationKey("a", "b"), ConversationKey("b", "a"))
+	assert.Equal(t, "", ConversationKey())
+}
+
+// send wraps a message from the sender and returns the wrap addressed to
+// the recipient.
+func send(t *testing.T, m events.Event, senderSK, recipient string) events.Event {
+	wraps, err := Wrap(m, senderSK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wraps[recipient]
+}
+
+func TestInbox(t *testing.T) {
+	alice, bob, carol := publicKey(t, aliceSK), publicKey(t, bobSK), publicKey(t, carolSK)
+	inbox, err := NewInbox(bobSK)
+	assert.NoError(t, err)
+
+	first := ChatMessage(alice, []string{bob}, "hi bob", 1000)
+	reply := ChatMessage(bob, []string{alice}, "hi alice", 1100)
+	reply.Tags = append(reply.Tags, events.Tag{"subject", "catching up"})
+	late := ChatMessage(alice, []string{bob}, "how are you?", 1200)
+	group := ChatMessage(carol, []string{alice, bob}, "hi all", 1150)
+
+	// Messages arrive out of order, and the reply is Bob's own copy
+	for _, wrap := range []events.Event{
+		send(t, late, aliceSK, bob),
+		send(t, group, carolSK, bob),
+		send(t, reply, bobSK, bob),
+		send(t, first, aliceSK, bob),
+	} {
+		_, err := inbox.Add(wrap)
+		assert.NoError(t, err)
+	}
+
+	// A copy received from another relay is ignored
+	dup := send(t, first, aliceSK, bob)
+	m, err := inbox.Add(dup)
+	assert.NoError(t, err)
+	assert.Equal(t, "hi bob", m.Content)
+
+	c, ok := inbox.Conversation(alice)
+	assert.True(t, ok)
+	assert.Equal(t, sorted(alice, bob), c.Participants)
+	assert.Equal(t, "catching up", c.Subject)
+	assert.Equal(t, []string{"hi bob", "hi alice", "how are you?"}, contents(c.Messages))
+
+	g, ok := inbox.Conversation(carol, alice)
+	assert.True(t, ok)
+	assert.Equal(t, sorted(alice, bob, carol), g.Participants)
+	assert.Equal(t, []string{"hi all"}, contents(g.Messages))
+
+	_, ok = inbox.Conversation(carol)
+	assert.False(t, ok)
+
+	// The conversation with the newest message comes first
+	all := inbox.Conversations()
+	assert.Len(t, all, 2)
+	assert.Equal(t, c.Participants, all[0].Participants)
+	assert.Equal(t, g.Participants, all[1].Participants)
+
+	// Returned conversations are copies
+	c.Messages[0].Content = "changed"
+	c, _ = inbox.Conversation(alice)
+	assert.Equal(t, "hi bob", c.Messages[0].Content)
+}
+
+func TestInboxRejectsForeignWraps(t *testing.T) {
+	alice, carol := publicKey(t, aliceSK), publicKey(t, carolSK)
+	inbox, err := NewInbox(bobSK)
+	assert.NoError(t, err)
+
+	_, err = inbox.Add(send(t, ChatMessage(alice, []string{carol}, "not for bob", 1000), aliceSK, carol))
+	assert.ErrorContains(t, err, "gift wrap is invalid")
+	assert.Empty(t, inbox.Conversations())
+
+	_, err = NewInbox("invalid")
+	assert.ErrorContains(t, err, "private key must be 64 lowercase hex characters")
+}
+
+func sorted(pks ...string) []string {
+	sort.Strings(pks)
+	return pks
+}
+
+func contents(ms []events.Event) []string {
+	cs := []string{}
+	for _, m := range ms {
+		cs = append(cs, m.Content)
+	}
+	return cs
+}
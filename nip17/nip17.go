@@ -0,0 +1,214 @@
+// Package nip17 builds and reads NIP-17 private direct messages.
+//
+// Messages are kind 14 chat or kind 15 file rumors, tagged with every
+// recipient. Each is gift wrapped once for every recipient and once for the
+// sender, so that all participants, including the sender's other devices,
+// can read the conversation.
+package nip17
+
+import (
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"git.wisehodl.dev/jay/go-roots/keys"
+	"git.wisehodl.dev/jay/go-roots/nip59"
+	"strconv"
+)
+
+const (
+	// ChatKind is the kind of chat messages.
+	ChatKind = 14
+
+	// FileKind is the kind of file messages.
+	FileKind = 15
+)
+
+// ChatMessage returns an unsigned chat message from the sender to the
+// recipients. Reply ("e") and subject tags may be appended before wrapping.
+func ChatMessage(senderPublicKey string, recipients []string, content string, createdAt int) events.Event {
+	return events.Event{
+		PubKey:    senderPublicKey,
+		CreatedAt: createdAt,
+		Kind:      ChatKind,
+		Tags:      recipientTags(recipients),
+		Content:   content,
+	}
+}
+
+// File describes an encrypted file shared in a file message.
+type File struct {
+	// URL locates the encrypted file.
+	URL string
+
+	// Type is the MIME type of the file before encryption.
+	Type string
+
+	// EncryptionAlgorithm names the cipher, such as "aes-gcm", and
+	// DecryptionKey and DecryptionNonce hold its parameters.
+	EncryptionAlgorithm string
+	DecryptionKey       string
+	DecryptionNonce     string
+
+	// Hash is the hex SHA-256 hash of the encrypted file, and OriginalHash
+	// that of the file before encryption.
+	Hash         string
+	OriginalHash string
+
+	// Size is the size of the encrypted file in bytes.
+	Size int
+
+	// Dimensions is the size of an image or video as "<width>x<height>".
+	Dimensions string
+
+	Blurhash  string
+	Thumbnail string
+	Fallbacks []string
+}
+
+// FileMessage returns an unsigned file message from the sender to the
+// recipients.
+func FileMessage(senderPublicKey string, recipients []string, file File, createdAt int) events.Event {
+	tags := recipientTags(recipients)
+	add := func(name, value string) {
+		if value != "" {
+			tags = append(tags, events.Tag{name, value})
+		}
+	}
+	add("file-type", file.Type)
+	add("encryption-algorithm", file.EncryptionAlgorithm)
+	add("decryption-key", file.DecryptionKey)
+	add("decryption-nonce", file.DecryptionNonce)
+	add("x", file.Hash)
+	add("ox", file.OriginalHash)
+	if file.Size > 0 {
+		add("size", strconv.Itoa(file.Size))
+	}
+	add("dim", file.Dimensions)
+	add("blurhash", file.Blurhash)
+	add("thumb", file.Thumbnail)
+	for _, url := range file.Fallbacks {
+		add("fallback", url)
+	}
+
+	return events.Event{
+		PubKey:    senderPublicKey,
+		CreatedAt: createdAt,
+		Kind:      FileKind,
+		Tags:      tags,
+		Content:   file.URL,
+	}
+}
+
+// ParseFile reads the file described by a file message.
+func ParseFile(e events.Event) (File, error) {
+	if e.Kind != FileKind {
+		return File{}, fmt.Errorf("%w: kind %d, expected %d", errors.UnexpectedKind, e.Kind, FileKind)
+	}
+
+	file := File{URL: e.Content}
+	for _, tag := range e.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "file-type":
+			file.Type = tag[1]
+		case "encryption-algorithm":
+			file.EncryptionAlgorithm = tag[1]
+		case "decryption-key":
+			file.DecryptionKey = tag[1]
+		case "decryption-nonce":
+			file.DecryptionNonce = tag[1]
+		case "x":
+			file.Hash = tag[1]
+		case "ox":
+			file.OriginalHash = tag[1]
+		case "size":
+			file.Size, _ = strconv.Atoi(tag[1])
+		case "dim":
+			file.Dimensions = tag[1]
+		case "blurhash":
+			file.Blurhash = tag[1]
+		case "thumb":
+			file.Thumbnail = tag[1]
+		case "fallback":
+			file.Fallbacks = append(file.Fallbacks, tag[1])
+		}
+	}
+	return file, nil
+}
+
+// Wrap seals a chat or file message from the sender and gift wraps it for
+// every recipient tagged in it and for the sender. It returns the wraps
+// keyed by the public key of the participant each is addressed to, to be
+// published to that participant's DM relays.
+func Wrap(message events.Event, senderPrivateKey string) (map[string]events.Event, error) {
+	if message.Kind != ChatKind && message.Kind != FileKind {
+		return nil, fmt.Errorf("%w: kind %d", errors.UnexpectedKind, message.Kind)
+	}
+	senderPublicKey, err := keys.GetPublicKey(senderPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	wraps := make(map[string]events.Event)
+	for _, pk := range append(Recipients(message), senderPublicKey) {
+		if _, done := wraps[pk]; done {
+			continue
+		}
+		wrap, err := nip59.GiftWrap(message, senderPrivateKey, pk)
+		if err != nil {
+			return nil, err
+		}
+		wraps[pk] = wrap
+	}
+	return wraps, nil
+}
+
+// Open unwraps a gift wrap addressed to the owner of the private key and
+// returns the chat or file message inside.
+func Open(wrap events.Event, privateKey string) (events.Event, error) {
+	message, err := nip59.Unwrap(wrap, privateKey)
+	if err != nil {
+		return events.Event{}, err
+	}
+	if message.Kind != ChatKind && message.Kind != FileKind {
+		return events.Event{}, fmt.Errorf("%w: kind %d", errors.UnexpectedKind, message.Kind)
+	}
+	return message, nil
+}
+
+// Recipients returns the public keys tagged in a message, in order and
+// without duplicates.
+func Recipients(message events.Event) []string {
+	recipients := []string{}
+	seen := make(map[string]struct{})
+	for _, tag := range message.Tags {
+		if len(tag) < 2 || tag[0] != "p" {
+			continue
+		}
+		if _, ok := seen[tag[1]]; ok {
+			continue
+		}
+		seen[tag[1]] = struct{}{}
+		recipients = append(recipients, tag[1])
+	}
+	return recipients
+}
+
+// InboxFilter matches the gift wraps addressed to a public key.
+func InboxFilter(publicKey string) filters.Filter {
+	return filters.Filter{
+		Kinds: []int{nip59.WrapKind},
+		Tags:  filters.TagFilters{"p": {publicKey}},
+	}
+}
+
+func recipientTags(recipients []string) []events.Tag {
+	tags := make([]events.Tag, 0, len(recipients))
+	for _, pk := range recipients {
+		tags = append(tags, events.Tag{"p", pk})
+	}
+	return tags
+}
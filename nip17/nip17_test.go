@@ -0,0 +1,130 @@
+package nip17
+
+import (
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"git.wisehodl.dev/jay/go-roots/keys"
+	"git.wisehodl.dev/jay/go-roots/nip59"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const (
+	aliceSK = "f43a0435f69529f310bbd1d6263d2fbf0977f54bfe2310cc37ae5904b83bb167"
+	bobSK   = "7f7ff03d123792d6ac594bfa67bf6d0c0ab55b6b1fdb6249303fe861f1ccba9a"
+	carolSK = "92996316beebf94171065a714cbf164d1f56d7ad9b35b329d9fc97535bf25352"
+)
+
+func publicKey(t *testing.T, sk string) string {
+	pk, err := keys.GetPublicKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pk
+}
+
+func TestChatMessage(t *testing.T) {
+	alice, bob, carol := publicKey(t, aliceSK), publicKey(t, bobSK), publicKey(t, carolSK)
+	m := ChatMessage(alice, []string{bob, carol, bob}, "hi", 1000)
+	assert.Equal(t, ChatKind, m.Kind)
+	assert.Equal(t, alice, m.PubKey)
+	assert.Equal(t, "hi", m.Content)
+	assert.Equal(t, []string{bob, carol}, Recipients(m))
+}
+
+func TestFileMessage(t *testing.T) {
+	file := File{
+		URL:                 "https://files.example.com/abc",
+		Type:                "image/jpeg",
+		EncryptionAlgorithm: "aes-gcm",
+		DecryptionKey:       "key",
+		DecryptionNonce:     "nonce",
+		Hash:                "encryptedhash",
+		OriginalHash:        "originalhash",
+		Size:                1024,
+		Dimensions:          "640x480",
+		Blurhash:            "LEHV6nWB2yk8",
+		Thumbnail:           "https://files.example.com/thumb",
+		Fallbacks:           []string{"https://a.example.com/abc", "https://b.example.com/abc"},
+	}
+	m := FileMessage("sender", []string{"recipient"}, file, 1000)
+	assert.Equal(t, FileKind, m.Kind)
+	assert.Equal(t, file.URL, m.Content)
+	assert.Equal(t, []events.Tag{
+		{"p", "recipient"},
+		{"file-type", "image/jpeg"},
+		{"encryption-algorithm", "aes-gcm"},
+		{"decryption-key", "key"},
+		{"decryption-nonce", "nonce"},
+		{"x", "encryptedhash"},
+		{"ox", "originalhash"},
+		{"size", "1024"},
+		{"dim", "640x480"},
+		{"blurhash", "LEHV6nWB2yk8"},
+		{"thumb", "https://files.example.com/thumb"},
+		{"fallback", "https://a.example.com/abc"},
+		{"fallback", "https://b.example.com/abc"},
+	}, m.Tags)
+
+	parsed, err := ParseFile(m)
+	assert.NoError(t, err)
+	assert.Equal(t, file, parsed)
+
+	// Optional fields are omitted
+	m = FileMessage("sender", []string{"recipient"}, File{URL: "https://files.example.com/abc"}, 1000)
+	assert.Equal(t, []events.Tag{{"p", "recipient"}}, m.Tags)
+
+	_, err = ParseFile(ChatMessage("sender", nil, "hi", 1000))
+	assert.ErrorContains(t, err, "unexpected event kind: kind 14, expected 15")
+}
+
+func TestWrap(t *testing.T) {
+	alice, bob, carol := publicKey(t, aliceSK), publicKey(t, bobSK), publicKey(t, carolSK)
+	m := ChatMessage(alice, []string{bob, carol}, "hi both", 1000)
+	m.Tags = append(m.Tags, events.Tag{"subject", "plans"})
+
+	wraps, err := Wrap(m, aliceSK)
+	assert.NoError(t, err)
+	assert.Len(t, wraps, 3)
+
+	expected, _ := nip59.Rumor(m)
+	for pk, sk := range map[string]string{alice: aliceSK, bob: bobSK, carol: carolSK} {
+		wrap := wraps[pk]
+		assert.Equal(t, events.Tag{"p", pk}, wrap.Tags[0])
+		opened, err := Open(wrap, sk)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, opened)
+	}
+
+	// Messages to oneself are wrapped once
+	wraps, err = Wrap(ChatMessage(alice, []string{alice}, "note to self", 1000), aliceSK)
+	assert.NoError(t, err)
+	assert.Len(t, wraps, 1)
+}
+
+func TestWrapErrors(t *testing.T) {
+	alice, bob := publicKey(t, aliceSK), publicKey(t, bobSK)
+	_, err := Wrap(events.Event{PubKey: alice, Kind: 1, Tags: []events.Tag{{"p", bob}}}, aliceSK)
+	assert.ErrorContains(t, err, "unexpected event kind: kind 1")
+
+	// The message must be from the owner of the signing key
+	_, err = Wrap(ChatMessage(bob, []string{alice}, "hi", 1000), aliceSK)
+	assert.ErrorContains(t, err, "gift wrap is invalid")
+}
+
+func TestOpenRejectsOtherKinds(t *testing.T) {
+	alice, bob := publicKey(t, aliceSK), publicKey(t, bobSK)
+	rumor := events.Event{PubKey: alice, Kind: 1, Tags: []events.Tag{}, Content: "public note"}
+	wrap, err := nip59.GiftWrap(rumor, aliceSK, bob)
+	assert.NoError(t, err)
+
+	_, err = Open(wrap, bobSK)
+	assert.ErrorContains(t, err, "unexpected event kind: kind 1")
+}
+
+func TestInboxFilter(t *testing.T) {
+	assert.Equal(t, filters.Filter{
+		Kinds: []int{1059},
+		Tags:  filters.TagFilters{"p": {"pk"}},
+	}, InboxFilter("pk"))
+}
@@ -0,0 +1,79 @@
+package nip17
+
+import (
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+)
+
+// RelayListKind is the kind of the replaceable event listing the relays a
+// user receives direct messages on.
+const RelayListKind = 10050
+
+// RelayList returns an unsigned DM relay list.
+func RelayList(publicKey string, relays []string, createdAt int) events.Event {
+	tags := make([]events.Tag, 0, len(relays))
+	for _, url := range relays {
+		tags = append(tags, events.Tag{"relay", url})
+	}
+	return events.Event{
+		PubKey:    publicKey,
+		CreatedAt: createdAt,
+		Kind:      RelayListKind,
+		Tags:      tags,
+		Content:   "",
+	}
+}
+
+// ParseRelayList returns the relays in a DM relay list, in order and without
+// duplicates.
+func ParseRelayList(e events.Event) ([]string, error) {
+	if e.Kind != RelayListKind {
+		return nil, fmt.Errorf("%w: kind %d, expected %d", errors.UnexpectedKind, e.Kind, RelayListKind)
+	}
+	relays := []string{}
+	seen := make(map[string]struct{})
+	for _, tag := range e.Tags {
+		if len(tag) < 2 || tag[0] != "relay" {
+			continue
+		}
+		if _, ok := seen[tag[1]]; ok {
+			continue
+		}
+		seen[tag[1]] = struct{}{}
+		relays = append(relays, tag[1])
+	}
+	return relays, nil
+}
+
+// RelayListFilter matches the DM relay lists of the given users.
+func RelayListFilter(publicKeys ...string) filters.Filter {
+	return filters.Filter{
+		Authors: publicKeys,
+		Kinds:   []int{RelayListKind},
+	}
+}
+
+// RelayLists returns the relays of each author's newest DM relay list among
+// the events, keyed by public key. Events of other kinds are ignored; the
+// events are expected to have been validated.
+func RelayLists(evs []events.Event) map[string][]string {
+	newest := make(map[string]events.Event)
+	for _, e := range evs {
+		if e.Kind != RelayListKind {
+			continue
+		}
+		current, ok := newest[e.PubKey]
+		if !ok || e.CreatedAt > current.CreatedAt ||
+			(e.CreatedAt == current.CreatedAt && e.ID < current.ID) {
+			newest[e.PubKey] = e
+		}
+	}
+
+	lists := make(map[string][]string, len(newest))
+	for pk, e := range newest {
+		lists[pk], _ = ParseRelayList(e)
+	}
+	return lists
+}
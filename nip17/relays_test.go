@@ -0,0 +1,52 @@
+package nip17
+
+import (
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRelayList(t *testing.T) {
+	e := RelayList("pk", []string{"wss://inbox.example.com", "wss://backup.example.com"}, 1000)
+	assert.Equal(t, RelayListKind, e.Kind)
+	assert.Equal(t, []events.Tag{
+		{"relay", "wss://inbox.example.com"},
+		{"relay", "wss://backup.example.com"},
+	}, e.Tags)
+
+	e.Tags = append(e.Tags, events.Tag{"relay", "wss://inbox.example.com"}, events.Tag{"r", "wss://other.example.com"})
+	relays, err := ParseRelayList(e)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"wss://inbox.example.com", "wss://backup.example.com"}, relays)
+
+	_, err = ParseRelayList(events.Event{Kind: 10002})
+	assert.ErrorContains(t, err, "unexpected event kind: kind 10002, expected 10050")
+}
+
+func TestRelayListFilter(t *testing.T) {
+	assert.Equal(t, filters.Filter{
+		Authors: []string{"a", "b"},
+		Kinds:   []int{10050},
+	}, RelayListFilter("a", "b"))
+}
+
+func TestRelayLists(t *testing.T) {
+	old := RelayList("alice", []string{"wss://old.example.com"}, 1000)
+	current := RelayList("alice", []string{"wss://new.example.com"}, 2000)
+	bob := RelayList("bob", []string{"wss://bob.example.com"}, 1000)
+	other := events.Event{PubKey: "carol", Kind: 10002, Tags: []events.Tag{{"relay", "wss://x.example.com"}}}
+
+	// Ties are broken by the lowest ID, as for replaceable events
+	tieA := RelayList("dave", []string{"wss://a.example.com"}, 1000)
+	tieA.ID = "01"
+	tieB := RelayList("dave", []string{"wss://b.example.com"}, 1000)
+	tieB.ID = "02"
+
+	lists := RelayLists([]events.Event{current, old, bob, other, tieB, tieA})
+	assert.Equal(t, map[string][]string{
+		"alice": {"wss://new.example.com"},
+		"bob":   {"wss://bob.example.com"},
+		"dave":  {"wss://a.example.com"},
+	}, lists)
+}
@@ -0,0 +1,138 @@
+// Package nip42 builds and verifies the kind 22242 events clients sign to
+// authenticate to relays.
+package nip42
+
+import (
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/keys"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Kind is the kind of authentication events.
+const Kind = 22242
+
+// AuthEvent returns an unsigned authentication event answering a relay's
+// challenge.
+func AuthEvent(pubKey, relayURL, challenge string, createdAt int) events.Event {
+	return events.Event{
+		PubKey:    pubKey,
+		CreatedAt: createdAt,
+		Kind:      Kind,
+		Tags: []events.Tag{
+			{"relay", relayURL},
+			{"challenge", challenge},
+		},
+		Content: "",
+	}
+}
+
+// SignAuthEvent returns an authentication event answering a relay's
+// challenge, created now and signed with the private key.
+func SignAuthEvent(privateKey, relayURL, challenge string) (events.Event, error) {
+	pubKey, err := keys.GetPublicKey(privateKey)
+	if err != nil {
+		return events.Event{}, err
+	}
+	e := AuthEvent(pubKey, relayURL, challenge, int(time.Now().Unix()))
+	if e.ID, err = events.GetID(e); err != nil {
+		return events.Event{}, err
+	}
+	if e.Sig, err = events.SignEvent(e.ID, privateKey); err != nil {
+		return events.Event{}, err
+	}
+	return e, nil
+}
+
+// VerifyAuth checks that an authentication event is valid, that the challenge
+// and relay URL are non-empty and answered by its tags, and that it was
+// created within maxSkew of the current time. It returns the authenticated
+// public key.
+func VerifyAuth(e events.Event, challenge, relayURL string, maxSkew time.Duration) (string, error) {
+	return verifyAuthAt(e, challenge, relayURL, maxSkew, time.Now())
+}
+
+func verifyAuthAt(e events.Event, challenge, relayURL string, maxSkew time.Duration, now time.Time) (string, error) {
+	if challenge == "" {
+		return "", fmt.Errorf("%w: empty challenge", errors.InvalidAuth)
+	}
+	if strings.TrimSpace(relayURL) == "" {
+		return "", fmt.Errorf("%w: empty relay url", errors.InvalidAuth)
+	}
+	if e.Kind != Kind {
+		return "", fmt.Errorf("%w: kind %d, expected %d", errors.InvalidAuth, e.Kind, Kind)
+	}
+	if err := events.Validate(e); err != nil {
+		return "", fmt.Errorf("%w: %w", errors.InvalidAuth, err)
+	}
+
+	answer, ok := tagValue(e, "challenge")
+	if !ok {
+		return "", fmt.Errorf("%w: missing challenge tag", errors.InvalidAuth)
+	}
+	if answer != challenge {
+		return "", fmt.Errorf("%w: challenge does not match", errors.InvalidAuth)
+	}
+	if relay, _ := tagValue(e, "relay"); NormalizeURL(relay) != NormalizeURL(relayURL) {
+		return "", fmt.Errorf("%w: relay does not match", errors.InvalidAuth)
+	}
+
+	skew := now.Sub(time.Unix(int64(e.CreatedAt), 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > maxSkew {
+		return "", fmt.Errorf("%w: created_at is %s from now", errors.InvalidAuth, skew)
+	}
+
+	return e.PubKey, nil
+}
+
+// tagValue returns the value of the first tag with the name, and whether
+// there is one.
+func tagValue(e events.Event, name string) (string, bool) {
+	for _, tag := range e.Tags {
+		if len(tag) >= 2 && tag[0] == name {
+			return tag[1], true
+		}
+	}
+	return "", false
+}
+
+// NormalizeURL returns a relay URL in a canonical form for comparison: the
+// scheme and host are lowercased, default ports and trailing slashes are
+// removed, and a missing scheme is taken to be wss. Strings that are not
+// URLs are returned trimmed.
+func NormalizeURL(relayURL string) string {
+	relayURL = strings.TrimSpace(relayURL)
+	if !strings.Contains(relayURL, "://") {
+		relayURL = "wss://" + relayURL
+	}
+
+	u, err := url.Parse(relayURL)
+	if err != nil || u.Host == "" {
+		return strings.TrimSpace(relayURL)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (u.Scheme == "wss" && port == "443") || (u.Scheme == "ws" && port == "80") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		// IPv6 literal
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+	u.Fragment = ""
+	return u.String()
+}
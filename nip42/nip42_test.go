@@ -0,0 +1,214 @@
+package nip42
+
+import (
+	"git.wisehodl.dev/jay/go-roots/events"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+const testSK = "f43a0435f69529f310bbd1d6263d2fbf0977f54bfe2310cc37ae5904b83bb167"
+const testPK = "cfa87f35acbde29ba1ab3ee42de527b2cad33ac487e80cf2d6405ea0042c8fef"
+
+const testRelay = "wss://relay.example.com"
+const testChallenge = "challengestring"
+
+var testNow = time.Unix(1700000000, 0)
+
+func signed(t *testing.T, e events.Event) events.Event {
+	var err error
+	if e.ID, err = events.GetID(e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Sig, err = events.SignEvent(e.ID, testSK); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestAuthEvent(t *testing.T) {
+	e := AuthEvent(testPK, testRelay, testChallenge, 1700000000)
+	assert.Equal(t, events.Event{
+		PubKey:    testPK,
+		CreatedAt: 1700000000,
+		Kind:      22242,
+		Tags:      []events.Tag{{"relay", testRelay}, {"challenge", testChallenge}},
+		Content:   "",
+	}, e)
+}
+
+func TestSignAuthEvent(t *testing.T) {
+	e, err := SignAuthEvent(testSK, testRelay, testChallenge)
+	assert.NoError(t, err)
+	assert.NoError(t, events.Validate(e))
+
+	pubKey, err := VerifyAuth(e, testChallenge, testRelay, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, testPK, pubKey)
+
+	_, err = SignAuthEvent("not a key", testRelay, testChallenge)
+	assert.Error(t, err)
+}
+
+type VerifyAuthTestCase struct {
+	name          string
+	event         func(t *testing.T) events.Event
+	relayURL      string
+	expectedError string
+}
+
+func authAt(createdAt int64, relayURL, challenge string) func(t *testing.T) events.Event {
+	return func(t *testing.T) events.Event {
+		return signed(t, AuthEvent(testPK, relayURL, challenge, int(createdAt)))
+	}
+}
+
+var verifyAuthTestCases = []VerifyAuthTestCase{
+	{
+		name:     "valid",
+		event:    authAt(testNow.Unix(), testRelay, testChallenge),
+		relayURL: testRelay,
+	},
+
+	{
+		name:     "equivalent relay url",
+		event:    authAt(testNow.Unix(), "WSS://Relay.Example.com:443/", testChallenge),
+		relayURL: testRelay,
+	},
+
+	{
+		name:     "within skew in the past",
+		event:    authAt(testNow.Unix()-600, testRelay, testChallenge),
+		relayURL: testRelay,
+	},
+
+	{
+		name:     "within skew in the future",
+		event:    authAt(testNow.Unix()+600, testRelay, testChallenge),
+		relayURL: testRelay,
+	},
+
+	{
+		name:          "too old",
+		event:         authAt(testNow.Unix()-601, testRelay, testChallenge),
+		relayURL:      testRelay,
+		expectedError: "auth event is invalid: created_at is 10m1s from now",
+	},
+
+	{
+		name:          "too far in the future",
+		event:         authAt(testNow.Unix()+601, testRelay, testChallenge),
+		relayURL:      testRelay,
+		expectedError: "auth event is invalid: created_at is 10m1s from now",
+	},
+
+	{
+		name:          "wrong challenge",
+		event:         authAt(testNow.Unix(), testRelay, "other"),
+		relayURL:      testRelay,
+		expectedError: "auth event is invalid: challenge does not match",
+	},
+
+	{
+		name:          "wrong relay",
+		event:         authAt(testNow.Unix(), "wss://other.example.com", testChallenge),
+		relayURL:      testRelay,
+		expectedError: "auth event is invalid: relay does not match",
+	},
+
+	{
+		name: "missing tags",
+		event: func(t *testing.T) events.Event {
+			e := AuthEvent(testPK, testRelay, testChallenge, int(testNow.Unix()))
+			e.Tags = []events.Tag{}
+			return signed(t, e)
+		},
+		relayURL:      testRelay,
+		expectedError: "auth event is invalid: missing challenge tag",
+	},
+
+	{
+		name: "wrong kind",
+		event: func(t *testing.T) events.Event {
+			e := AuthEvent(testPK, testRelay, testChallenge, int(testNow.Unix()))
+			e.Kind = 1
+			return signed(t, e)
+		},
+		relayURL:      testRelay,
+		expectedError: "auth event is invalid: kind 1, expected 22242",
+	},
+
+	{
+		name: "bad signature",
+		event: func(t *testing.T) events.Event {
+			e := authAt(testNow.Unix(), testRelay, testChallenge)(t)
+			e.Sig = authAt(testNow.Unix(), testRelay, "other")(t).Sig
+			return e
+		},
+		relayURL:      testRelay,
+		expectedError: "auth event is invalid: event signature is invalid",
+	},
+}
+
+func TestVerifyAuth(t *testing.T) {
+	for _, tc := range verifyAuthTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pubKey, err := verifyAuthAt(tc.event(t), testChallenge, tc.relayURL, 10*time.Minute, testNow)
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, testPK, pubKey)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedError)
+				assert.Equal(t, "", pubKey)
+			}
+		})
+	}
+}
+
+func TestVerifyAuthEmptyChallenge(t *testing.T) {
+	e := AuthEvent(testPK, testRelay, "", int(testNow.Unix()))
+	e.Tags = []events.Tag{{"relay", testRelay}}
+	_, err := verifyAuthAt(signed(t, e), "", testRelay, 10*time.Minute, testNow)
+	assert.ErrorContains(t, err, "auth event is invalid: empty challenge")
+
+	_, err = verifyAuthAt(authAt(testNow.Unix(), testRelay, "")(t), "", testRelay, 10*time.Minute, testNow)
+	assert.ErrorContains(t, err, "auth event is invalid: empty challenge")
+}
+
+func TestVerifyAuthEmptyRelayURL(t *testing.T) {
+	e := AuthEvent(testPK, "", testChallenge, int(testNow.Unix()))
+	e.Tags = []events.Tag{{"challenge", testChallenge}}
+	_, err := verifyAuthAt(signed(t, e), testChallenge, "", 10*time.Minute, testNow)
+	assert.ErrorContains(t, err, "auth event is invalid: empty relay url")
+
+	_, err = verifyAuthAt(authAt(testNow.Unix(), "", testChallenge)(t), testChallenge, " ", 10*time.Minute, testNow)
+	assert.ErrorContains(t, err, "auth event is invalid: empty relay url")
+}
+
+type NormalizeURLTestCase struct {
+	input    string
+	expected string
+}
+
+var normalizeURLTestCases = []NormalizeURLTestCase{
+	{input: "wss://relay.example.com", expected: "wss://relay.example.com"},
+	{input: "wss://relay.example.com/", expected: "wss://relay.example.com"},
+	{input: "WSS://RELAY.Example.COM", expected: "wss://relay.example.com"},
+	{input: "wss://relay.example.com:443", expected: "wss://relay.example.com"},
+	{input: "ws://relay.example.com:80/", expected: "ws://relay.example.com"},
+	{input: "ws://relay.example.com:443", expected: "ws://relay.example.com:443"},
+	{input: "relay.example.com", expected: "wss://relay.example.com"},
+	{input: " wss://relay.example.com/nostr/ ", expected: "wss://relay.example.com/nostr"},
+	{input: "wss://relay.example.com/Nostr", expected: "wss://relay.example.com/Nostr"},
+	{input: "wss://relay.example.com/?key=1", expected: "wss://relay.example.com?key=1"},
+	{input: "wss://[::1]:443", expected: "wss://[::1]"},
+	{input: "ws://127.0.0.1:7777", expected: "ws://127.0.0.1:7777"},
+}
+
+func TestNormalizeURL(t *testing.T) {
+	for _, tc := range normalizeURLTestCases {
+		t.Run(tc.input, func(t *testing.T) {
+			assert.Equal(t, tc.expected, NormalizeURL(tc.input))
+		})
+	}
+}
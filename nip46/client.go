@@ -0,0 +1,310 @@
+package nip46
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/client"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/keys"
+	"sync"
+)
+
+// Client calls a remote signer through relays.
+type Client struct {
+	privateKey string
+	publicKey  string
+	pool       *client.Pool
+	ownsPool   bool
+	sub        *client.PoolSubscription
+	done       chan struct{}
+
+	mu           sync.Mutex
+	signerPubKey string
+	userPubKey   string
+	pending      map[string]chan Response
+
+	// secret and accepted await a signer answering a nostrconnect URI
+	secret   string
+	accepted chan struct{}
+}
+
+// NewClient returns a client calling the remote signer through the pool's
+// relays, using the client's own private key. It does not connect to the
+// signer; call Connect first unless the signer has already granted the
+// client permissions.
+func NewClient(pool *client.Pool, clientPrivateKey, signerPubKey string) (*Client, error) {
+	if !events.Hex64Pattern.MatchString(signerPubKey) {
+		return nil, errors.MalformedPubKey
+	}
+	return newClient(pool, clientPrivateKey, signerPubKey, "")
+}
+
+func newClient(pool *client.Pool, clientPrivateKey, signerPubKey, secret string) (*Client, error) {
+	publicKey, err := keys.GetPublicKey(clientPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := pool.Subscribe(inboxFilter(publicKey))
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		privateKey:   clientPrivateKey,
+		publicKey:    publicKey,
+		pool:         pool,
+		sub:          sub,
+		done:         make(chan struct{}),
+		signerPubKey: signerPubKey,
+		pending:      make(map[string]chan Response),
+		secret:       secret,
+		accepted:     make(chan struct{}),
+	}
+	go c.read()
+	return c, nil
+}
+
+// Dial connects to the relays of a bunker URI and to the remote signer,
+// asking for the permissions. Close closes the relay connections.
+func Dial(ctx context.Context, uri BunkerURI, clientPrivateKey string, perms Permissions) (*Client, error) {
+	pool := client.NewPool(client.PoolOptions{})
+	for _, url := range uri.Relays {
+		if err := pool.Add(ctx, url); err != nil {
+			pool.Close()
+			return nil, err
+		}
+	}
+
+	c, err := NewClient(pool, clientPrivateKey, uri.SignerPubKey)
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+	c.ownsPool = true
+
+	if err := c.Connect(ctx, uri.Secret, perms); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Listen waits through the pool's relays for a remote signer to accept the
+// client's nostrconnect URI, which must be for the client's private key.
+func Listen(ctx context.Context, pool *client.Pool, clientPrivateKey string, uri ConnectURI) (*Client, error) {
+	c, err := newClient(pool, clientPrivateKey, "", uri.Secret)
+	if err != nil {
+		return nil, err
+	}
+	if c.publicKey != uri.ClientPubKey {
+		c.Close()
+		return nil, fmt.Errorf("%w: uri is for another client", errors.MalformedURI)
+	}
+
+	select {
+	case <-c.accepted:
+		return c, nil
+	case <-ctx.Done():
+		c.Close()
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, errors.ClientClosed
+	}
+}
+
+// PublicKey returns the client's public key.
+func (c *Client) PublicKey() string {
+	return c.publicKey
+}
+
+// SignerPubKey returns the remote signer's public key.
+func (c *Client) SignerPubKey() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.signerPubKey
+}
+
+// Close stops listening for responses, and closes the relay connections if
+// the client opened them.
+func (c *Client) Close() error {
+	c.sub.Close()
+	if c.ownsPool {
+		return c.pool.Close()
+	}
+	return nil
+}
+
+// Connect asks the remote signer to accept the client, with the secret from
+// a bunker URI if it has one, and the permissions to ask for.
+func (c *Client) Connect(ctx context.Context, secret string, perms Permissions) error {
+	_, err := c.Call(ctx, MethodConnect, c.SignerPubKey(), secret, perms.String())
+	return err
+}
+
+// Ping checks that the remote signer is answering.
+func (c *Client) Ping(ctx context.Context) error {
+	result, err := c.Call(ctx, MethodPing)
+	if err != nil {
+		return err
+	}
+	if result != "pong" {
+		return fmt.Errorf("%w: unexpected ping result %q", errors.RemoteSignerError, result)
+	}
+	return nil
+}
+
+// GetPublicKey returns the user's public key. It is asked of the remote
+// signer once, and remembered.
+func (c *Client) GetPublicKey(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	userPubKey := c.userPubKey
+	c.mu.Unlock()
+	if userPubKey != "" {
+		return userPubKey, nil
+	}
+
+	result, err := c.Call(ctx, MethodGetPublicKey)
+	if err != nil {
+		return "", err
+	}
+	if !events.Hex64Pattern.MatchString(result) {
+		return "", fmt.Errorf("%w: %w", errors.RemoteSignerError, errors.MalformedPubKey)
+	}
+	c.mu.Lock()
+	c.userPubKey = result
+	c.mu.Unlock()
+	return result, nil
+}
+
+// SignEvent asks the remote signer to sign an event as the user. The kind,
+// created_at, tags and content of the event are sent; the returned event is
+// validated and checked to have them and the user's public key.
+func (c *Client) SignEvent(ctx context.Context, e events.Event) (events.Event, error) {
+	userPubKey, err := c.GetPublicKey(ctx)
+	if err != nil {
+		return events.Event{}, err
+	}
+	if e.Tags == nil {
+		e.Tags = []events.Tag{}
+	}
+	template, err := json.Marshal(struct {
+		Kind      int          `json:"kind"`
+		Content   string       `json:"content"`
+		Tags      []events.Tag `json:"tags"`
+		CreatedAt int          `json:"created_at"`
+	}{e.Kind, e.Content, e.Tags, e.CreatedAt})
+	if err != nil {
+		return events.Event{}, err
+	}
+
+	result, err := c.Call(ctx, MethodSignEvent, string(template))
+	if err != nil {
+		return events.Event{}, err
+	}
+	var signed events.Event
+	if err := json.Unmarshal([]byte(result), &signed); err != nil {
+		return events.Event{}, fmt.Errorf("%w: %w", errors.RemoteSignerError, err)
+	}
+	if err := events.Validate(signed); err != nil {
+		return events.Event{}, fmt.Errorf("%w: %w", errors.RemoteSignerError, err)
+	}
+
+	// The signed event must be the one asked for
+	if signed.PubKey != userPubKey {
+		return events.Event{}, fmt.Errorf("%w: signed by another public key", errors.RemoteSignerError)
+	}
+	e.PubKey = userPubKey
+	if id, err := events.GetID(e); err != nil || id != signed.ID {
+		return events.Event{}, fmt.Errorf("%w: signed event differs from request", errors.RemoteSignerError)
+	}
+	return signed, nil
+}
+
+// NIP44Encrypt asks the remote signer to encrypt a plaintext from the user
+// to a third party with NIP-44.
+func (c *Client) NIP44Encrypt(ctx context.Context, thirdPartyPubKey, plaintext string) (string, error) {
+	return c.Call(ctx, MethodNIP44Encrypt, thirdPartyPubKey, plaintext)
+}
+
+// NIP44Decrypt asks the remote signer to decrypt a NIP-44 payload from a
+// third party to the user.
+func (c *Client) NIP44Decrypt(ctx context.Context, thirdPartyPubKey, payload string) (string, error) {
+	return c.Call(ctx, MethodNIP44Decrypt, thirdPartyPubKey, payload)
+}
+
+// Call sends a request to the remote signer and waits for its result. An
+// error response is returned as an error wrapping errors.RemoteSignerError.
+func (c *Client) Call(ctx context.Context, method string, params ...string) (string, error) {
+	id, err := NewRequestID()
+	if err != nil {
+		return "", err
+	}
+	if params == nil {
+		params = []string{}
+	}
+	e, err := EncryptMessage(Request{ID: id, Method: method, Params: params}, c.privateKey, c.SignerPubKey())
+	if err != nil {
+		return "", err
+	}
+
+	answer := make(chan Response, 1)
+	c.mu.Lock()
+	c.pending[id] = answer
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.pool.Publish(ctx, e); err != nil {
+		return "", err
+	}
+
+	select {
+	case resp := <-answer:
+		if resp.Error != "" {
+			return "", fmt.Errorf("%w: %s", errors.RemoteSignerError, resp.Error)
+		}
+		return resp.Result, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-c.done:
+		return "", errors.ClientClosed
+	}
+}
+
+// read routes responses from the signer to the calls waiting for them.
+func (c *Client) read() {
+	defer close(c.done)
+	for e := range c.sub.Events {
+		signerPubKey := c.SignerPubKey()
+		if signerPubKey != "" && e.PubKey != signerPubKey {
+			continue
+		}
+		var resp Response
+		if err := DecryptMessage(e, c.privateKey, &resp); err != nil {
+			continue
+		}
+
+		c.mu.Lock()
+		if c.signerPubKey == "" {
+			// A signer accepting the nostrconnect URI proves it with the secret
+			if c.secret != "" && resp.Result == c.secret {
+				c.signerPubKey = e.PubKey
+				close(c.accepted)
+			}
+			c.mu.Unlock()
+			continue
+		}
+		answer, ok := c.pending[resp.ID]
+		c.mu.Unlock()
+		if ok {
+			select {
+			case answer <- resp:
+			default:
+			}
+		}
+	}
+}
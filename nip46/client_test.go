@@ -0,0 +1,227 @@
+package nip46
+
+import (
+	"context"
+	"encoding/json"
+	"git.wisehodl.dev/jay/go-roots/client"
+	"git.wisehodl.dev/jay/go-roots/encryption/nip44"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/nip11"
+	"git.wisehodl.dev/jay/go-roots/relay"
+	"git.wisehodl.dev/jay/go-roots/store/memory"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startRelay(t *testing.T, opts relay.Options) string {
+	r := relay.New(memory.New(), opts)
+	server := httptest.NewServer(r)
+	t.Cleanup(func() {
+		r.Close()
+		server.Close()
+	})
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func newTestPool(t *testing.T, url string) *client.Pool {
+	pool := client.NewPool(client.PoolOptions{})
+	t.Cleanup(func() { pool.Close() })
+	if err := pool.Add(context.Background(), url); err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+// serve runs the signer on its own pool until the test ends, returning once
+// it is listening.
+func serve(t *testing.T, url string, opts SignerOptions) *Signer {
+	listening := make(chan struct{})
+	opts.OnListening = func() { close(listening) }
+	s, err := NewSigner(testSK, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go s.Serve(ctx, newTestPool(t, url))
+
+	select {
+	case <-listening:
+	case <-time.After(5 * time.Second):
+		t.Fatal("signer is not listening")
+	}
+	return s
+}
+
+func TestClientBunker(t *testing.T) {
+	url := startRelay(t, relay.Options{})
+	s := serve(t, url, SignerOptions{Secret: "s3cret"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, s.BunkerURI(url), clientSK, Permissions{"sign_event:1", "nip44_encrypt", "nip44_decrypt"})
+	assert.NoError(t, err)
+	defer c.Close()
+	assert.Equal(t, clientPK, c.PublicKey())
+	assert.Equal(t, testPK, c.SignerPubKey())
+
+	assert.NoError(t, c.Ping(ctx))
+
+	pk, err := c.GetPublicKey(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, testPK, pk)
+
+	signed, err := c.SignEvent(ctx, events.Event{Kind: 1, CreatedAt: 1700000000, Content: "hello"})
+	assert.NoError(t, err)
+	assert.NoError(t, events.Validate(signed))
+	assert.Equal(t, testPK, signed.PubKey)
+	assert.Equal(t, "hello", signed.Content)
+
+	_, err = c.SignEvent(ctx, events.Event{Kind: 7, CreatedAt: 1700000000, Content: "+"})
+	assert.ErrorContains(t, err, "remote signer returned an error: permission denied: sign_event for kind 7")
+
+	// A third party can read what the signer encrypts as the user
+	otherPK := publicKey(t, otherSK)
+	payload, err := c.NIP44Encrypt(ctx, otherPK, "secret message")
+	assert.NoError(t, err)
+	conversationKey, _ := nip44.ConversationKey(otherSK, testPK)
+	plaintext, err := nip44.Decrypt(payload, conversationKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret message", plaintext)
+
+	plaintext, err = c.NIP44Decrypt(ctx, otherPK, payload)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret message", plaintext)
+}
+
+func TestClientBunkerRefused(t *testing.T) {
+	url := startRelay(t, relay.Options{})
+	s := serve(t, url, SignerOptions{Secret: "s3cret"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	uri := s.BunkerURI(url)
+	uri.Secret = "wrong"
+	_, err := Dial(ctx, uri, clientSK, Permissions{})
+	assert.ErrorContains(t, err, "remote signer returned an error: permission denied: connection refused")
+}
+
+func TestClientConnectURI(t *testing.T) {
+	url := startRelay(t, relay.Options{})
+	s := serve(t, url, SignerOptions{})
+	uri := ConnectURI{
+		ClientPubKey: clientPK,
+		Relays:       []string{url},
+		Secret:       "s3cret",
+		Perms:        Permissions{"sign_event:1"},
+	}
+
+	// The signer scans the URI, and keeps answering until the client hears
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	signerPool := newTestPool(t, url)
+	go func() {
+		for ctx.Err() == nil {
+			s.Accept(ctx, signerPool, uri)
+			time.Sleep(50 * time.Millisecond)
+		}
+	}()
+
+	c, err := Listen(ctx, newTestPool(t, url), clientSK, uri)
+	assert.NoError(t, err)
+	defer c.Close()
+	assert.Equal(t, testPK, c.SignerPubKey())
+
+	perms, ok := s.Permissions(clientPK)
+	assert.True(t, ok)
+	assert.Equal(t, Permissions{"sign_event:1"}, perms)
+
+	signed, err := c.SignEvent(ctx, events.Event{Kind: 1, CreatedAt: 1700000000, Content: "hello"})
+	assert.NoError(t, err)
+	assert.NoError(t, events.Validate(signed))
+}
+
+func TestListenForAnotherClient(t *testing.T) {
+	url := startRelay(t, relay.Options{})
+	uri := ConnectURI{ClientPubKey: testPK, Relays: []string{url}, Secret: "s3cret"}
+	_, err := Listen(context.Background(), newTestPool(t, url), clientSK, uri)
+	assert.ErrorContains(t, err, "malformed connection uri: uri is for another client")
+}
+
+func TestNewClientMalformedSignerPubKey(t *testing.T) {
+	_, err := NewClient(client.NewPool(client.PoolOptions{}), clientSK, "npub")
+	assert.ErrorContains(t, err, "public key must be 64 lowercase hex characters")
+}
+
+func TestSignerPublishErrors(t *testing.T) {
+	// Requests fit the relay's content limit, but the response to a long
+	// nip44_encrypt does not
+	url := startRelay(t, relay.Options{Info: &nip11.Document{
+		Limitation: &nip11.Limitation{MaxContentLength: intPtr(2000)},
+	}})
+	failures := make(chan error, 1)
+	s := serve(t, url, SignerOptions{OnError: func(err error) { failures <- err }})
+	s.Grant(clientPK, Permissions{"nip44_encrypt"})
+
+	c, err := NewClient(newTestPool(t, url), clientSK, testPK)
+	assert.NoError(t, err)
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go c.NIP44Encrypt(ctx, publicKey(t, otherSK), strings.Repeat("x", 1000))
+
+	select {
+	case err := <-failures:
+		assert.ErrorContains(t, err, "event was rejected")
+	case <-time.After(5 * time.Second):
+		t.Fatal("publish error was not reported")
+	}
+}
+
+// impostor answers requests to the user's key, reporting the user's public
+// key but signing events with another key.
+func impostor(t *testing.T, url string) {
+	pool := newTestPool(t, url)
+	sub, err := pool.Subscribe(inboxFilter(testPK))
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-sub.EOSE
+	go func() {
+		for e := range sub.Events {
+			var req Request
+			if DecryptMessage(e, testSK, &req) != nil {
+				continue
+			}
+			resp := Response{ID: req.ID, Result: testPK}
+			if req.Method == MethodSignEvent {
+				other, _ := NewSigner(otherSK, SignerOptions{})
+				var template events.Event
+				json.Unmarshal([]byte(req.Params[0]), &template)
+				signed, _ := other.sign(template)
+				data, _ := json.Marshal(signed)
+				resp.Result = string(data)
+			}
+			answer, _ := EncryptMessage(resp, testSK, e.PubKey)
+			pool.Publish(context.Background(), answer)
+		}
+	}()
+}
+
+func TestClientRejectsOtherSigningKey(t *testing.T) {
+	url := startRelay(t, relay.Options{})
+	impostor(t, url)
+
+	c, err := NewClient(newTestPool(t, url), clientSK, testPK)
+	assert.NoError(t, err)
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = c.SignEvent(ctx, events.Event{Kind: 1, CreatedAt: 1700000000, Content: "hello"})
+	assert.ErrorContains(t, err, "remote signer returned an error: signed by another public key")
+}
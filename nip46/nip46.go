@@ -0,0 +1,163 @@
+// Package nip46 implements NIP-46 remote signing, in which a client asks a
+// remote signer, or bunker, that holds the user's key to sign events and
+// encrypt messages on its behalf.
+//
+// Requests and responses are JSON messages exchanged through relays as
+// kind 24133 events, encrypted with NIP-44 between the client's key and the
+// remote signer's key. The client's key is a throwaway key of its own; the
+// user's key never leaves the signer.
+package nip46
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/encryption/nip44"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"git.wisehodl.dev/jay/go-roots/keys"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind is the event kind of NIP-46 requests and responses.
+const Kind = 24133
+
+// Methods supported by the client and signer.
+const (
+	MethodConnect      = "connect"
+	MethodSignEvent    = "sign_event"
+	MethodGetPublicKey = "get_public_key"
+	MethodNIP44Encrypt = "nip44_encrypt"
+	MethodNIP44Decrypt = "nip44_decrypt"
+	MethodPing         = "ping"
+)
+
+// Request is a call to a remote signer.
+type Request struct {
+	ID     string   `json:"id"`
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+}
+
+// Response answers a request with the same ID. Error is empty on success.
+type Response struct {
+	ID     string `json:"id"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Permissions lists the methods a client may call, in the NIP-46 form
+// "method", or "sign_event:<kind>" to allow signing a single kind.
+type Permissions []string
+
+// ParsePermissions parses a comma-separated list of permissions.
+func ParsePermissions(s string) Permissions {
+	perms := Permissions{}
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			perms = append(perms, p)
+		}
+	}
+	return perms
+}
+
+// String encodes the permissions as a comma-separated list.
+func (p Permissions) String() string {
+	return strings.Join(p, ",")
+}
+
+// Allows reports whether the permissions allow calling a method. For
+// sign_event, kind is the kind of the event to sign. Connected clients may
+// always ping and get the public key.
+func (p Permissions) Allows(method string, kind int) bool {
+	switch method {
+	case MethodConnect, MethodPing, MethodGetPublicKey:
+		return true
+	}
+	for _, perm := range p {
+		if perm == method {
+			return true
+		}
+		if method == MethodSignEvent && perm == MethodSignEvent+":"+strconv.Itoa(kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewRequestID returns a random request ID.
+func NewRequestID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// EncryptMessage encodes a request or response as a signed kind 24133 event
+// from the sender to the recipient.
+func EncryptMessage(message any, senderPrivateKey, recipientPubKey string) (events.Event, error) {
+	senderPubKey, err := keys.GetPublicKey(senderPrivateKey)
+	if err != nil {
+		return events.Event{}, err
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		return events.Event{}, err
+	}
+	conversationKey, err := nip44.ConversationKey(senderPrivateKey, recipientPubKey)
+	if err != nil {
+		return events.Event{}, err
+	}
+	content, err := nip44.Encrypt(string(data), conversationKey)
+	if err != nil {
+		return events.Event{}, err
+	}
+
+	e := events.Event{
+		PubKey:    senderPubKey,
+		CreatedAt: int(time.Now().Unix()),
+		Kind:      Kind,
+		Tags:      []events.Tag{{"p", recipientPubKey}},
+		Content:   content,
+	}
+	if e.ID, err = events.GetID(e); err != nil {
+		return events.Event{}, err
+	}
+	if e.Sig, err = events.SignEvent(e.ID, senderPrivateKey); err != nil {
+		return events.Event{}, err
+	}
+	return e, nil
+}
+
+// DecryptMessage decodes a kind 24133 event addressed to the recipient into
+// a request or response.
+func DecryptMessage(e events.Event, recipientPrivateKey string, message any) error {
+	if e.Kind != Kind {
+		return fmt.Errorf("%w: kind %d, expected %d", errors.UnexpectedKind, e.Kind, Kind)
+	}
+	conversationKey, err := nip44.ConversationKey(recipientPrivateKey, e.PubKey)
+	if err != nil {
+		return err
+	}
+	data, err := nip44.Decrypt(e.Content, conversationKey)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(data), message); err != nil {
+		return fmt.Errorf("%w: %w", errors.MalformedMessage, err)
+	}
+	return nil
+}
+
+// inboxFilter matches the messages addressed to a public key.
+func inboxFilter(pubKey string) filters.Filter {
+	return filters.Filter{
+		Kinds: []int{Kind},
+		Tags:  filters.TagFilters{"p": {pubKey}},
+	}
+}
@@ -0,0 +1,94 @@
+package nip46
+
+import (
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/keys"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// The user's key, held by the signer, and the client's key
+const (
+	testSK   = "f43a0435f69529f310bbd1d6263d2fbf0977f54bfe2310cc37ae5904b83bb167"
+	testPK   = "cfa87f35acbde29ba1ab3ee42de527b2cad33ac487e80cf2d6405ea0042c8fef"
+	clientSK = "7f7ff03d123792d6ac594bfa67bf6d0c0ab55b6b1fdb6249303fe861f1ccba9a"
+	clientPK = "17162c921dc4d2518f9a101db33695df1afb56ab82f5ff3e5da6eec3ca5cd917"
+	otherSK  = "92996316beebf94171065a714cbf164d1f56d7ad9b35b329d9fc97535bf25352"
+)
+
+func publicKey(t *testing.T, sk string) string {
+	pk, err := keys.GetPublicKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pk
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestParsePermissions(t *testing.T) {
+	perms := ParsePermissions("nip44_encrypt, sign_event:1,,sign_event:7")
+	assert.Equal(t, Permissions{"nip44_encrypt", "sign_event:1", "sign_event:7"}, perms)
+	assert.Equal(t, "nip44_encrypt,sign_event:1,sign_event:7", perms.String())
+	assert.Equal(t, Permissions{}, ParsePermissions(""))
+}
+
+type PermissionsAllowsTestCase struct {
+	name     string
+	perms    Permissions
+	method   string
+	kind     int
+	expected bool
+}
+
+var permissionsAllowsTestCases = []PermissionsAllowsTestCase{
+	{"ping", Permissions{}, MethodPing, 0, true},
+	{"get public key", Permissions{}, MethodGetPublicKey, 0, true},
+	{"method", Permissions{"nip44_encrypt"}, MethodNIP44Encrypt, 0, true},
+	{"missing method", Permissions{"nip44_encrypt"}, MethodNIP44Decrypt, 0, false},
+	{"any kind", Permissions{"sign_event"}, MethodSignEvent, 30023, true},
+	{"listed kind", Permissions{"sign_event:1", "sign_event:7"}, MethodSignEvent, 7, true},
+	{"other kind", Permissions{"sign_event:1"}, MethodSignEvent, 4, false},
+	{"kind only for signing", Permissions{"nip44_encrypt:1"}, MethodNIP44Encrypt, 1, false},
+}
+
+func TestPermissionsAllows(t *testing.T) {
+	for _, tc := range permissionsAllowsTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.perms.Allows(tc.method, tc.kind))
+		})
+	}
+}
+
+func TestEncryptMessage(t *testing.T) {
+	req := Request{ID: "1", Method: MethodPing, Params: []string{}}
+	e, err := EncryptMessage(req, clientSK, testPK)
+	assert.NoError(t, err)
+
+	assert.NoError(t, events.Validate(e))
+	assert.Equal(t, Kind, e.Kind)
+	assert.Equal(t, clientPK, e.PubKey)
+	assert.Equal(t, []events.Tag{{"p", testPK}}, e.Tags)
+	assert.NotContains(t, e.Content, "ping")
+
+	var decrypted Request
+	assert.NoError(t, DecryptMessage(e, testSK, &decrypted))
+	assert.Equal(t, req, decrypted)
+
+	// Only the recipient can read it
+	assert.Error(t, DecryptMessage(e, otherSK, &decrypted))
+}
+
+func TestDecryptMessageErrors(t *testing.T) {
+	e, _ := EncryptMessage(Response{ID: "1", Result: "pong"}, clientSK, testPK)
+
+	wrongKind := e
+	wrongKind.Kind = 4
+	var resp Response
+	assert.ErrorContains(t, DecryptMessage(wrongKind, testSK, &resp), "unexpected event kind")
+
+	notJSON, _ := EncryptMessage("not an object", clientSK, testPK)
+	assert.ErrorContains(t, DecryptMessage(notJSON, testSK, &resp), "malformed message")
+}
@@ -0,0 +1,274 @@
+package nip46
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/client"
+	"git.wisehodl.dev/jay/go-roots/encryption/nip44"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/keys"
+	"sync"
+)
+
+// SignerOptions configures a remote signer. The zero value only serves
+// clients granted permissions with Grant.
+type SignerOptions struct {
+	// Secret, if set, authorizes the first client that connects with it, and
+	// grants it the permissions it asks for. It is put in bunker URIs.
+	Secret string
+
+	// Authorize, if set, is called when an unknown client connects without
+	// the secret. It returns the permissions to grant, or false to refuse.
+	Authorize func(clientPubKey string, requested Permissions) (Permissions, bool)
+
+	// OnListening, if set, is called by Serve once every relay has
+	// confirmed the subscription. Requests are ephemeral, so those sent
+	// earlier may be missed.
+	OnListening func()
+
+	// OnError, if set, is called by Serve with the error when a response
+	// cannot be published.
+	OnError func(error)
+}
+
+// Signer is a remote signer holding a user's private key. It answers the
+// requests of connected clients, within the permissions granted to each.
+type Signer struct {
+	privateKey string
+	publicKey  string
+	opts       SignerOptions
+
+	mu         sync.Mutex
+	clients    map[string]Permissions
+	secretUsed bool
+}
+
+// NewSigner returns a remote signer for the private key. The same key
+// signs the user's events and the signer's messages.
+func NewSigner(privateKeyHex string, opts SignerOptions) (*Signer, error) {
+	publicKey, err := keys.GetPublicKey(privateKeyHex)
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{
+		privateKey: privateKeyHex,
+		publicKey:  publicKey,
+		opts:       opts,
+		clients:    make(map[string]Permissions),
+	}, nil
+}
+
+// PublicKey returns the user's and signer's public key.
+func (s *Signer) PublicKey() string {
+	return s.publicKey
+}
+
+// BunkerURI returns a connection token for clients, with the secret from
+// the options.
+func (s *Signer) BunkerURI(relays ...string) BunkerURI {
+	return BunkerURI{SignerPubKey: s.publicKey, Relays: relays, Secret: s.opts.Secret}
+}
+
+// Grant connects a client with the permissions, replacing any it had.
+func (s *Signer) Grant(clientPubKey string, perms Permissions) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients[clientPubKey] = append(Permissions{}, perms...)
+}
+
+// Revoke disconnects a client.
+func (s *Signer) Revoke(clientPubKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clients, clientPubKey)
+}
+
+// Permissions returns the permissions of a connected client.
+func (s *Signer) Permissions(clientPubKey string) (Permissions, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	perms, ok := s.clients[clientPubKey]
+	return append(Permissions{}, perms...), ok
+}
+
+// Handle answers a request event, returning the response event to publish.
+// Requests that are refused or fail are answered with an error response; an
+// error is returned only for events that cannot be read as requests.
+func (s *Signer) Handle(e events.Event) (events.Event, error) {
+	var req Request
+	if err := DecryptMessage(e, s.privateKey, &req); err != nil {
+		return events.Event{}, err
+	}
+
+	resp := Response{ID: req.ID}
+	result, err := s.call(e.PubKey, req)
+	if err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Result = result
+	}
+	return EncryptMessage(resp, s.privateKey, e.PubKey)
+}
+
+// Serve answers requests arriving through the pool's relays until the
+// context is canceled or the pool is closed.
+func (s *Signer) Serve(ctx context.Context, pool *client.Pool) error {
+	sub, err := pool.Subscribe(inboxFilter(s.publicKey))
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	eose := sub.EOSE
+	for {
+		select {
+		case <-eose:
+			eose = nil
+			if s.opts.OnListening != nil {
+				s.opts.OnListening()
+			}
+		case e, ok := <-sub.Events:
+			if !ok {
+				return errors.ClientClosed
+			}
+			resp, err := s.Handle(e)
+			if err != nil {
+				continue
+			}
+			go func() {
+				if err := pool.Publish(ctx, resp); err != nil && s.opts.OnError != nil {
+					s.opts.OnError(err)
+				}
+			}()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Accept connects to a client that gave out a nostrconnect URI, granting
+// it the permissions it asks for, and answers with the URI's secret.
+func (s *Signer) Accept(ctx context.Context, pool *client.Pool, uri ConnectURI) error {
+	id, err := NewRequestID()
+	if err != nil {
+		return err
+	}
+	resp, err := EncryptMessage(Response{ID: id, Result: uri.Secret}, s.privateKey, uri.ClientPubKey)
+	if err != nil {
+		return err
+	}
+	s.Grant(uri.ClientPubKey, uri.Perms)
+	return pool.Publish(ctx, resp)
+}
+
+// call runs a request from a client.
+func (s *Signer) call(clientPubKey string, req Request) (string, error) {
+	if req.Method == MethodConnect {
+		return s.connect(clientPubKey, req.Params)
+	}
+
+	perms, connected := s.Permissions(clientPubKey)
+	if !connected {
+		return "", fmt.Errorf("%w: client is not connected", errors.PermissionDenied)
+	}
+
+	switch req.Method {
+	case MethodPing:
+		return "pong", nil
+
+	case MethodGetPublicKey:
+		return s.publicKey, nil
+
+	case MethodSignEvent:
+		if len(req.Params) != 1 {
+			return "", fmt.Errorf("%w: sign_event takes one parameter", errors.MalformedMessage)
+		}
+		var e events.Event
+		if err := json.Unmarshal([]byte(req.Params[0]), &e); err != nil {
+			return "", fmt.Errorf("%w: %w", errors.MalformedMessage, err)
+		}
+		if !perms.Allows(MethodSignEvent, e.Kind) {
+			return "", fmt.Errorf("%w: sign_event for kind %d", errors.PermissionDenied, e.Kind)
+		}
+		signed, err := s.sign(e)
+		if err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(signed)
+		return string(data), err
+
+	case MethodNIP44Encrypt, MethodNIP44Decrypt:
+		if !perms.Allows(req.Method, 0) {
+			return "", fmt.Errorf("%w: %s", errors.PermissionDenied, req.Method)
+		}
+		if len(req.Params) != 2 {
+			return "", fmt.Errorf("%w: %s takes two parameters", errors.MalformedMessage, req.Method)
+		}
+		conversationKey, err := nip44.ConversationKey(s.privateKey, req.Params[0])
+		if err != nil {
+			return "", err
+		}
+		if req.Method == MethodNIP44Encrypt {
+			return nip44.Encrypt(req.Params[1], conversationKey)
+		}
+		return nip44.Decrypt(req.Params[1], conversationKey)
+
+	default:
+		return "", fmt.Errorf("%w: unsupported method %q", errors.MalformedMessage, req.Method)
+	}
+}
+
+// connect authorizes a client with params [signer pubkey, secret, perms].
+func (s *Signer) connect(clientPubKey string, params []string) (string, error) {
+	if len(params) < 1 || params[0] != s.publicKey {
+		return "", fmt.Errorf("%w: connect is for another signer", errors.PermissionDenied)
+	}
+	var secret string
+	requested := Permissions{}
+	if len(params) >= 2 {
+		secret = params[1]
+	}
+	if len(params) >= 3 {
+		requested = ParsePermissions(params[2])
+	}
+
+	s.mu.Lock()
+	if _, connected := s.clients[clientPubKey]; connected {
+		s.mu.Unlock()
+		return "ack", nil
+	}
+	if s.opts.Secret != "" && secret == s.opts.Secret && !s.secretUsed {
+		s.secretUsed = true
+		s.clients[clientPubKey] = requested
+		s.mu.Unlock()
+		return "ack", nil
+	}
+	s.mu.Unlock()
+
+	if s.opts.Authorize != nil {
+		if perms, ok := s.opts.Authorize(clientPubKey, requested); ok {
+			s.Grant(clientPubKey, perms)
+			return "ack", nil
+		}
+	}
+	return "", fmt.Errorf("%w: connection refused", errors.PermissionDenied)
+}
+
+// sign signs an event template as the user, ignoring any pubkey, id and
+// signature it has.
+func (s *Signer) sign(e events.Event) (events.Event, error) {
+	e.PubKey = s.publicKey
+	if e.Tags == nil {
+		e.Tags = []events.Tag{}
+	}
+	var err error
+	if e.ID, err = events.GetID(e); err != nil {
+		return events.Event{}, err
+	}
+	if e.Sig, err = events.SignEvent(e.ID, s.privateKey); err != nil {
+		return events.Event{}, err
+	}
+	return e, nil
+}
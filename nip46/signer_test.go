@@ -0,0 +1,179 @@
+package nip46
+
+import (
+	"encoding/json"
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/encryption/nip44"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// handle sends a request from the client key to the signer and returns the
+// decrypted response.
+func handle(t *testing.T, s *Signer, sk, method string, params ...string) Response {
+	if params == nil {
+		params = []string{}
+	}
+	req, err := EncryptMessage(Request{ID: "req", Method: method, Params: params}, sk, s.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := s.Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.PublicKey(), e.PubKey)
+
+	var resp Response
+	if err := DecryptMessage(e, sk, &resp); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "req", resp.ID)
+	return resp
+}
+
+// template returns a sign_event parameter of the kind.
+func template(kind int) string {
+	return fmt.Sprintf(`{"kind":%d,"content":"hello","tags":[],"created_at":1700000000}`, kind)
+}
+
+func TestSignerConnectWithSecret(t *testing.T) {
+	s, err := NewSigner(testSK, SignerOptions{Secret: "s3cret"})
+	assert.NoError(t, err)
+	assert.Equal(t, BunkerURI{SignerPubKey: testPK, Relays: []string{"wss://r.example.com"}, Secret: "s3cret"},
+		s.BunkerURI("wss://r.example.com"))
+
+	resp := handle(t, s, clientSK, MethodConnect, testPK, "wrong", "sign_event:1")
+	assert.Equal(t, "permission denied: connection refused", resp.Error)
+
+	resp = handle(t, s, clientSK, MethodConnect, testPK, "s3cret", "sign_event:1")
+	assert.Equal(t, Response{ID: "req", Result: "ack"}, resp)
+	perms, ok := s.Permissions(clientPK)
+	assert.True(t, ok)
+	assert.Equal(t, Permissions{"sign_event:1"}, perms)
+
+	// Connecting again is acknowledged without changing permissions
+	resp = handle(t, s, clientSK, MethodConnect, testPK)
+	assert.Equal(t, "ack", resp.Result)
+	perms, _ = s.Permissions(clientPK)
+	assert.Equal(t, Permissions{"sign_event:1"}, perms)
+
+	// The secret is single use
+	resp = handle(t, s, otherSK, MethodConnect, testPK, "s3cret")
+	assert.Equal(t, "permission denied: connection refused", resp.Error)
+	_, ok = s.Permissions(publicKey(t, otherSK))
+	assert.False(t, ok)
+}
+
+func TestSignerConnectForAnotherSigner(t *testing.T) {
+	s, _ := NewSigner(testSK, SignerOptions{Secret: "s3cret"})
+	resp := handle(t, s, clientSK, MethodConnect, clientPK, "s3cret")
+	assert.Equal(t, "permission denied: connect is for another signer", resp.Error)
+
+	resp = handle(t, s, clientSK, MethodConnect)
+	assert.Equal(t, "permission denied: connect is for another signer", resp.Error)
+}
+
+func TestSignerAuthorize(t *testing.T) {
+	var asked Permissions
+	s, _ := NewSigner(testSK, SignerOptions{
+		Authorize: func(clientPubKey string, requested Permissions) (Permissions, bool) {
+			asked = requested
+			if clientPubKey != clientPK {
+				return nil, false
+			}
+			return Permissions{"nip44_encrypt"}, true
+		},
+	})
+
+	resp := handle(t, s, clientSK, MethodConnect, testPK, "", "nip44_encrypt,nip44_decrypt")
+	assert.Equal(t, "ack", resp.Result)
+	assert.Equal(t, Permissions{"nip44_encrypt", "nip44_decrypt"}, asked)
+	perms, _ := s.Permissions(clientPK)
+	assert.Equal(t, Permissions{"nip44_encrypt"}, perms)
+
+	resp = handle(t, s, otherSK, MethodConnect, testPK)
+	assert.Equal(t, "permission denied: connection refused", resp.Error)
+}
+
+func TestSignerRequiresConnection(t *testing.T) {
+	s, _ := NewSigner(testSK, SignerOptions{})
+	for _, method := range []string{MethodPing, MethodGetPublicKey, MethodSignEvent} {
+		resp := handle(t, s, clientSK, method)
+		assert.Equal(t, "permission denied: client is not connected", resp.Error, method)
+	}
+
+	s.Grant(clientPK, Permissions{})
+	assert.Equal(t, Response{ID: "req", Result: "pong"}, handle(t, s, clientSK, MethodPing))
+	assert.Equal(t, testPK, handle(t, s, clientSK, MethodGetPublicKey).Result)
+
+	s.Revoke(clientPK)
+	resp := handle(t, s, clientSK, MethodPing)
+	assert.Equal(t, "permission denied: client is not connected", resp.Error)
+}
+
+func TestSignerSignEvent(t *testing.T) {
+	s, _ := NewSigner(testSK, SignerOptions{})
+	s.Grant(clientPK, Permissions{"sign_event:1"})
+
+	resp := handle(t, s, clientSK, MethodSignEvent, template(1))
+	assert.Empty(t, resp.Error)
+	var signed events.Event
+	assert.NoError(t, json.Unmarshal([]byte(resp.Result), &signed))
+	assert.NoError(t, events.Validate(signed))
+	assert.Equal(t, testPK, signed.PubKey)
+	assert.Equal(t, 1, signed.Kind)
+	assert.Equal(t, "hello", signed.Content)
+	assert.Equal(t, 1700000000, signed.CreatedAt)
+
+	resp = handle(t, s, clientSK, MethodSignEvent, template(7))
+	assert.Equal(t, "permission denied: sign_event for kind 7", resp.Error)
+
+	resp = handle(t, s, clientSK, MethodSignEvent, "not json")
+	assert.Contains(t, resp.Error, "malformed message")
+
+	resp = handle(t, s, clientSK, MethodSignEvent)
+	assert.Equal(t, "malformed message: sign_event takes one parameter", resp.Error)
+}
+
+func TestSignerNIP44(t *testing.T) {
+	s, _ := NewSigner(testSK, SignerOptions{})
+	s.Grant(clientPK, Permissions{"nip44_encrypt"})
+	otherPK := publicKey(t, otherSK)
+
+	resp := handle(t, s, clientSK, MethodNIP44Encrypt, otherPK, "secret message")
+	assert.Empty(t, resp.Error)
+	conversationKey, err := nip44.ConversationKey(otherSK, testPK)
+	assert.NoError(t, err)
+	plaintext, err := nip44.Decrypt(resp.Result, conversationKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret message", plaintext)
+
+	resp = handle(t, s, clientSK, MethodNIP44Decrypt, otherPK, resp.Result)
+	assert.Equal(t, "permission denied: nip44_decrypt", resp.Error)
+
+	s.Grant(clientPK, Permissions{"nip44_encrypt", "nip44_decrypt"})
+	payload := handle(t, s, clientSK, MethodNIP44Encrypt, otherPK, "round trip").Result
+	resp = handle(t, s, clientSK, MethodNIP44Decrypt, otherPK, payload)
+	assert.Equal(t, Response{ID: "req", Result: "round trip"}, resp)
+
+	resp = handle(t, s, clientSK, MethodNIP44Encrypt, otherPK)
+	assert.Equal(t, "malformed message: nip44_encrypt takes two parameters", resp.Error)
+}
+
+func TestSignerUnsupportedMethod(t *testing.T) {
+	s, _ := NewSigner(testSK, SignerOptions{})
+	s.Grant(clientPK, Permissions{"nip04_encrypt"})
+	resp := handle(t, s, clientSK, "nip04_encrypt", testPK, "hello")
+	assert.Equal(t, `malformed message: unsupported method "nip04_encrypt"`, resp.Error)
+}
+
+func TestSignerHandleUnreadable(t *testing.T) {
+	s, _ := NewSigner(testSK, SignerOptions{})
+
+	// Encrypted for another key
+	e, _ := EncryptMessage(Request{ID: "req", Method: MethodPing}, clientSK, publicKey(t, otherSK))
+	_, err := s.Handle(e)
+	assert.Error(t, err)
+}
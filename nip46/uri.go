@@ -0,0 +1,135 @@
+package nip46
+
+import (
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"net/url"
+)
+
+// URI schemes of connection tokens.
+const (
+	BunkerScheme  = "bunker"
+	ConnectScheme = "nostrconnect"
+)
+
+// BunkerURI is a connection token given out by a remote signer:
+// bunker://<signer pubkey>?relay=<url>&secret=<secret>.
+type BunkerURI struct {
+	// SignerPubKey is the remote signer's public key, which may differ from
+	// the user's.
+	SignerPubKey string
+
+	// Relays are where the signer listens for requests.
+	Relays []string
+
+	// Secret, if set, authorizes the first connect request.
+	Secret string
+}
+
+// ParseBunkerURI parses a bunker:// URI.
+func ParseBunkerURI(s string) (BunkerURI, error) {
+	pubKey, query, err := parseURI(s, BunkerScheme)
+	if err != nil {
+		return BunkerURI{}, err
+	}
+	return BunkerURI{
+		SignerPubKey: pubKey,
+		Relays:       query["relay"],
+		Secret:       query.Get("secret"),
+	}, nil
+}
+
+// String encodes the URI.
+func (u BunkerURI) String() string {
+	query := url.Values{"relay": u.Relays}
+	if u.Secret != "" {
+		query.Set("secret", u.Secret)
+	}
+	return BunkerScheme + "://" + u.SignerPubKey + "?" + query.Encode()
+}
+
+// ConnectURI is a connection token given out by a client for a remote signer
+// to connect to:
+// nostrconnect://<client pubkey>?relay=<url>&secret=<secret>&perms=<perms>.
+type ConnectURI struct {
+	// ClientPubKey is the client's public key.
+	ClientPubKey string
+
+	// Relays are where the client listens for responses.
+	Relays []string
+
+	// Secret is returned by the signer to prove it received the URI.
+	Secret string
+
+	// Perms are the permissions the client asks for.
+	Perms Permissions
+
+	// Name, URL and Image optionally describe the client.
+	Name  string
+	URL   string
+	Image string
+}
+
+// ParseConnectURI parses a nostrconnect:// URI, which must have a secret.
+func ParseConnectURI(s string) (ConnectURI, error) {
+	pubKey, query, err := parseURI(s, ConnectScheme)
+	if err != nil {
+		return ConnectURI{}, err
+	}
+	if query.Get("secret") == "" {
+		return ConnectURI{}, fmt.Errorf("%w: missing secret", errors.MalformedURI)
+	}
+	return ConnectURI{
+		ClientPubKey: pubKey,
+		Relays:       query["relay"],
+		Secret:       query.Get("secret"),
+		Perms:        ParsePermissions(query.Get("perms")),
+		Name:         query.Get("name"),
+		URL:          query.Get("url"),
+		Image:        query.Get("image"),
+	}, nil
+}
+
+// String encodes the URI.
+func (u ConnectURI) String() string {
+	query := url.Values{"relay": u.Relays, "secret": {u.Secret}}
+	for key, value := range map[string]string{
+		"perms": u.Perms.String(),
+		"name":  u.Name,
+		"url":   u.URL,
+		"image": u.Image,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	return ConnectScheme + "://" + u.ClientPubKey + "?" + query.Encode()
+}
+
+// parseURI parses a connection URI with the scheme, returning its public
+// key and query. At least one ws:// or wss:// relay is required.
+func parseURI(s, scheme string) (string, url.Values, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %w", errors.MalformedURI, err)
+	}
+	if u.Scheme != scheme {
+		return "", nil, fmt.Errorf("%w: scheme must be %s", errors.MalformedURI, scheme)
+	}
+	if !events.Hex64Pattern.MatchString(u.Host) || u.User != nil || u.Path != "" {
+		return "", nil, fmt.Errorf("%w: %s", errors.MalformedURI, errors.MalformedPubKey)
+	}
+
+	query := u.Query()
+	if len(query["relay"]) == 0 {
+		return "", nil, fmt.Errorf("%w: missing relay", errors.MalformedURI)
+	}
+	for _, relay := range query["relay"] {
+		r, err := url.Parse(relay)
+		if err != nil || (r.Scheme != "ws" && r.Scheme != "wss") || r.Host == "" {
+			return "", nil, fmt.Errorf("%w: invalid relay %q", errors.MalformedURI, relay)
+		}
+	}
+	return u.Host, query, nil
+}
@@ -0,0 +1,78 @@
+package nip46
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseBunkerURI(t *testing.T) {
+	uri, err := ParseBunkerURI("bunker://" + testPK + "?relay=wss%3A%2F%2Frelay.example.com&relay=wss://other.example.com&secret=abc")
+	assert.NoError(t, err)
+	assert.Equal(t, BunkerURI{
+		SignerPubKey: testPK,
+		Relays:       []string{"wss://relay.example.com", "wss://other.example.com"},
+		Secret:       "abc",
+	}, uri)
+
+	// Round trip
+	parsed, err := ParseBunkerURI(uri.String())
+	assert.NoError(t, err)
+	assert.Equal(t, uri, parsed)
+
+	noSecret, err := ParseBunkerURI("bunker://" + testPK + "?relay=ws://localhost:7777")
+	assert.NoError(t, err)
+	assert.Equal(t, "", noSecret.Secret)
+	assert.Equal(t, "bunker://"+testPK+"?relay=ws%3A%2F%2Flocalhost%3A7777", noSecret.String())
+}
+
+func TestParseConnectURI(t *testing.T) {
+	uri, err := ParseConnectURI("nostrconnect://" + clientPK +
+		"?relay=wss://relay.example.com&secret=s3cret&perms=nip44_encrypt,sign_event:1&name=App&url=https://app.example.com&image=https://app.example.com/logo.png")
+	assert.NoError(t, err)
+	assert.Equal(t, ConnectURI{
+		ClientPubKey: clientPK,
+		Relays:       []string{"wss://relay.example.com"},
+		Secret:       "s3cret",
+		Perms:        Permissions{"nip44_encrypt", "sign_event:1"},
+		Name:         "App",
+		URL:          "https://app.example.com",
+		Image:        "https://app.example.com/logo.png",
+	}, uri)
+
+	parsed, err := ParseConnectURI(uri.String())
+	assert.NoError(t, err)
+	assert.Equal(t, uri, parsed)
+}
+
+type ParseURIErrorTestCase struct {
+	name          string
+	input         string
+	expectedError string
+}
+
+var parseBunkerURIErrorTestCases = []ParseURIErrorTestCase{
+	{"wrong scheme", "nostrconnect://" + testPK + "?relay=wss://r.example.com", "malformed connection uri: scheme must be bunker"},
+	{"short pubkey", "bunker://" + testPK[:62] + "?relay=wss://r.example.com", "malformed connection uri: public key must be 64 lowercase hex characters"},
+	{"npub", "bunker://npub10elfcs4fr0l0r8af98jlmgdh9c8tcxjvz9qkw038js35mp4dma8qzvjptg?relay=wss://r.example.com", "malformed connection uri: public key"},
+	{"path", "bunker://" + testPK + "/path?relay=wss://r.example.com", "malformed connection uri: public key"},
+	{"no relay", "bunker://" + testPK + "?secret=abc", "malformed connection uri: missing relay"},
+	{"http relay", "bunker://" + testPK + "?relay=https://r.example.com", `malformed connection uri: invalid relay "https://r.example.com"`},
+	{"not a uri", "bunker://%zz", "malformed connection uri"},
+}
+
+func TestParseBunkerURIErrors(t *testing.T) {
+	for _, tc := range parseBunkerURIErrorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseBunkerURI(tc.input)
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestParseConnectURIErrors(t *testing.T) {
+	_, err := ParseConnectURI("nostrconnect://" + clientPK + "?relay=wss://r.example.com")
+	assert.ErrorContains(t, err, "malformed connection uri: missing secret")
+
+	_, err = ParseConnectURI("bunker://" + clientPK + "?relay=wss://r.example.com&secret=abc")
+	assert.ErrorContains(t, err, "malformed connection uri: scheme must be nostrconnect")
+}
@@ -0,0 +1,215 @@
+// Package nip59 hides events inside gift wraps.
+//
+// A rumor is an unsigned event, so that it cannot be proven to others. The
+// author encrypts it to the recipient inside a kind 13 seal signed with
+// their own key, and the seal is encrypted again inside a kind 1059 gift
+// wrap signed with a single-use key. Seals and wraps carry randomized
+// timestamps, so that only the recipient learns who sent what, and when.
+package nip59
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/encryption/nip44"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/keys"
+	"math/big"
+	"time"
+)
+
+const (
+	// SealKind is the kind of seals.
+	SealKind = 13
+
+	// WrapKind is the kind of gift wraps.
+	WrapKind = 1059
+
+	// MaxTweak is how far into the past, in seconds, seal and gift wrap
+	// timestamps are randomized.
+	MaxTweak = 2 * 24 * 60 * 60
+)
+
+// rumor is the JSON form of a rumor, which has no signature.
+type rumor struct {
+	ID        string       `json:"id"`
+	PubKey    string       `json:"pubkey"`
+	CreatedAt int          `json:"created_at"`
+	Kind      int          `json:"kind"`
+	Tags      []events.Tag `json:"tags"`
+	Content   string       `json:"content"`
+}
+
+// Rumor returns the event as a rumor: its ID computed and its signature
+// removed.
+func Rumor(e events.Event) (events.Event, error) {
+	if e.Tags == nil {
+		e.Tags = []events.Tag{}
+	}
+	id, err := events.GetID(e)
+	if err != nil {
+		return events.Event{}, err
+	}
+	e.ID = id
+	e.Sig = ""
+	return e, nil
+}
+
+// RandomizeCreatedAt returns a timestamp up to MaxTweak seconds before now.
+func RandomizeCreatedAt(now time.Time) int {
+	tweak, err := rand.Int(rand.Reader, big.NewInt(MaxTweak))
+	if err != nil {
+		return int(now.Unix())
+	}
+	return int(now.Unix() - tweak.Int64())
+}
+
+// Seal encrypts a rumor to the recipient and signs the seal with the
+// sender's private key, which must be the rumor's author.
+func Seal(e events.Event, senderPrivateKey, recipientPublicKey string) (events.Event, error) {
+	senderPublicKey, err := keys.GetPublicKey(senderPrivateKey)
+	if err != nil {
+		return events.Event{}, err
+	}
+	if e.PubKey != senderPublicKey {
+		return events.Event{}, fmt.Errorf("%w: rumor pubkey is not the sender's", errors.InvalidGiftWrap)
+	}
+
+	r, err := Rumor(e)
+	if err != nil {
+		return events.Event{}, err
+	}
+	data, err := json.Marshal(rumor{
+		ID:        r.ID,
+		PubKey:    r.PubKey,
+		CreatedAt: r.CreatedAt,
+		Kind:      r.Kind,
+		Tags:      r.Tags,
+		Content:   r.Content,
+	})
+	if err != nil {
+		return events.Event{}, err
+	}
+
+	seal := events.Event{
+		PubKey:    senderPublicKey,
+		CreatedAt: RandomizeCreatedAt(time.Now()),
+		Kind:      SealKind,
+		Tags:      []events.Tag{},
+	}
+	if seal.Content, err = encrypt(data, senderPrivateKey, recipientPublicKey); err != nil {
+		return events.Event{}, err
+	}
+	return sign(seal, senderPrivateKey)
+}
+
+// Wrap encrypts a seal to the recipient inside a gift wrap signed with a
+// new random key. The wrap is tagged with the recipient's public key,
+// followed by any extra tags.
+func Wrap(seal events.Event, recipientPublicKey string, tags ...events.Tag) (events.Event, error) {
+	if seal.Kind != SealKind {
+		return events.Event{}, fmt.Errorf("%w: kind %d, expected %d", errors.InvalidGiftWrap, seal.Kind, SealKind)
+	}
+	ephemeralKey, err := keys.GeneratePrivateKey()
+	if err != nil {
+		return events.Event{}, err
+	}
+	ephemeralPublicKey, err := keys.GetPublicKey(ephemeralKey)
+	if err != nil {
+		return events.Event{}, err
+	}
+	data, err := json.Marshal(seal)
+	if err != nil {
+		return events.Event{}, err
+	}
+
+	wrap := events.Event{
+		PubKey:    ephemeralPublicKey,
+		CreatedAt: RandomizeCreatedAt(time.Now()),
+		Kind:      WrapKind,
+		Tags:      append([]events.Tag{{"p", recipientPublicKey}}, tags...),
+	}
+	if wrap.Content, err = encrypt(data, ephemeralKey, recipientPublicKey); err != nil {
+		return events.Event{}, err
+	}
+	return sign(wrap, ephemeralKey)
+}
+
+// GiftWrap seals a rumor from the sender and wraps it for the recipient.
+func GiftWrap(e events.Event, senderPrivateKey, recipientPublicKey string, tags ...events.Tag) (events.Event, error) {
+	seal, err := Seal(e, senderPrivateKey, recipientPublicKey)
+	if err != nil {
+		return events.Event{}, err
+	}
+	return Wrap(seal, recipientPublicKey, tags...)
+}
+
+// Unwrap decrypts a gift wrap addressed to the owner of the private key and
+// returns the rumor inside. It fails with an error wrapping
+// errors.InvalidGiftWrap unless the wrap and seal are validly signed, the
+// rumor's ID is correct, and the seal's author is the rumor's author.
+func Unwrap(wrap events.Event, recipientPrivateKey string) (events.Event, error) {
+	seal, err := open(wrap, WrapKind, recipientPrivateKey)
+	if err != nil {
+		return events.Event{}, err
+	}
+	r, err := open(seal, SealKind, recipientPrivateKey)
+	if err != nil {
+		return events.Event{}, err
+	}
+
+	if r.PubKey != seal.PubKey {
+		return events.Event{}, fmt.Errorf("%w: seal author does not match rumor pubkey", errors.InvalidGiftWrap)
+	}
+	id, err := events.GetID(r)
+	if err != nil || id != r.ID {
+		return events.Event{}, fmt.Errorf("%w: rumor id does not match computed id", errors.InvalidGiftWrap)
+	}
+	r.Sig = ""
+	return r, nil
+}
+
+// open validates a seal or gift wrap and decrypts the event it contains.
+func open(e events.Event, kind int, recipientPrivateKey string) (events.Event, error) {
+	if e.Kind != kind {
+		return events.Event{}, fmt.Errorf("%w: kind %d, expected %d", errors.InvalidGiftWrap, e.Kind, kind)
+	}
+	if err := events.Validate(e); err != nil {
+		return events.Event{}, fmt.Errorf("%w: %w", errors.InvalidGiftWrap, err)
+	}
+
+	key, err := nip44.ConversationKey(recipientPrivateKey, e.PubKey)
+	if err != nil {
+		return events.Event{}, err
+	}
+	data, err := nip44.Decrypt(e.Content, key)
+	if err != nil {
+		return events.Event{}, fmt.Errorf("%w: %w", errors.InvalidGiftWrap, err)
+	}
+
+	var inner events.Event
+	if err := json.Unmarshal([]byte(data), &inner); err != nil {
+		return events.Event{}, fmt.Errorf("%w: %w", errors.InvalidGiftWrap, err)
+	}
+	return inner, nil
+}
+
+func encrypt(data []byte, privateKey, publicKey string) (string, error) {
+	key, err := nip44.ConversationKey(privateKey, publicKey)
+	if err != nil {
+		return "", err
+	}
+	return nip44.Encrypt(string(data), key)
+}
+
+func sign(e events.Event, privateKey string) (events.Event, error) {
+	var err error
+	if e.ID, err = events.GetID(e); err != nil {
+		return events.Event{}, err
+	}
+	if e.Sig, err = events.SignEvent(e.ID, privateKey); err != nil {
+		return events.Event{}, err
+	}
+	return e, nil
+}
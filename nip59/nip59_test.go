@@ -0,0 +1,198 @@
+package nip59
+
+import (
+	"encoding/json"
+	"git.wisehodl.dev/jay/go-roots/encryption/nip44"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/keys"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+const testSK = "f43a0435f69529f310bbd1d6263d2fbf0977f54bfe2310cc37ae5904b83bb167"
+const testPK = "cfa87f35acbde29ba1ab3ee42de527b2cad33ac487e80cf2d6405ea0042c8fef"
+
+// Recipient and third-party keys
+const (
+	recipientSK = "7f7ff03d123792d6ac594bfa67bf6d0c0ab55b6b1fdb6249303fe861f1ccba9a"
+	otherSK     = "92996316beebf94171065a714cbf164d1f56d7ad9b35b329d9fc97535bf25352"
+)
+
+func publicKey(t *testing.T, sk string) string {
+	pk, err := keys.GetPublicKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pk
+}
+
+func testRumor() events.Event {
+	return events.Event{
+		PubKey:    testPK,
+		CreatedAt: 1700000000,
+		Kind:      14,
+		Tags:      []events.Tag{{"p", "ab"}},
+		Content:   "are you going to the party tonight?",
+	}
+}
+
+func TestRumor(t *testing.T) {
+	e := testRumor()
+	e.Sig = "stale"
+	r, err := Rumor(e)
+	assert.NoError(t, err)
+	assert.Empty(t, r.Sig)
+	expected, _ := events.GetID(e)
+	assert.Equal(t, expected, r.ID)
+
+	r, err = Rumor(events.Event{PubKey: testPK, Kind: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []events.Tag{}, r.Tags)
+}
+
+func TestRandomizeCreatedAt(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	distinct := map[int]struct{}{}
+	for i := 0; i < 50; i++ {
+		ts := RandomizeCreatedAt(now)
+		assert.LessOrEqual(t, ts, 1700000000)
+		assert.Greater(t, ts, 1700000000-MaxTweak)
+		distinct[ts] = struct{}{}
+	}
+	assert.Greater(t, len(distinct), 1)
+}
+
+func TestGiftWrapRoundTrip(t *testing.T) {
+	recipientPK := publicKey(t, recipientSK)
+	now := int(time.Now().Unix())
+
+	wrap, err := GiftWrap(testRumor(), testSK, recipientPK, events.Tag{"relay", "wss://relay.example.com"})
+	assert.NoError(t, err)
+	assert.NoError(t, events.Validate(wrap))
+	assert.Equal(t, WrapKind, wrap.Kind)
+	assert.NotEqual(t, testPK, wrap.PubKey)
+	assert.Equal(t, []events.Tag{{"p", recipientPK}, {"relay", "wss://relay.example.com"}}, wrap.Tags)
+	assert.LessOrEqual(t, wrap.CreatedAt, now+1)
+	assert.Greater(t, wrap.CreatedAt, now-MaxTweak)
+
+	rumor, err := Unwrap(wrap, recipientSK)
+	assert.NoError(t, err)
+	expected, _ := Rumor(testRumor())
+	assert.Equal(t, expected, rumor)
+
+	// Every wrap uses a new key
+	again, err := GiftWrap(testRumor(), testSK, recipientPK)
+	assert.NoError(t, err)
+	assert.NotEqual(t, wrap.PubKey, again.PubKey)
+}
+
+func TestSeal(t *testing.T) {
+	recipientPK := publicKey(t, recipientSK)
+	seal, err := Seal(testRumor(), testSK, recipientPK)
+	assert.NoError(t, err)
+	assert.NoError(t, events.Validate(seal))
+	assert.Equal(t, SealKind, seal.Kind)
+	assert.Equal(t, testPK, seal.PubKey)
+	assert.Empty(t, seal.Tags)
+
+	// The rumor inside has no signature field
+	key, _ := nip44.ConversationKey(recipientSK, testPK)
+	data, err := nip44.Decrypt(seal.Content, key)
+	assert.NoError(t, err)
+	assert.NotContains(t, data, `"sig"`)
+
+	_, err = Seal(testRumor(), otherSK, recipientPK)
+	assert.ErrorContains(t, err, "gift wrap is invalid: rumor pubkey is not the sender's")
+}
+
+// forgedSeal returns a seal signed by signer that contains a rumor
+// attributed to another author.
+func forgedSeal(t *testing.T, signer string, rumor events.Event, recipientPK string) events.Event {
+	rumor, _ = Rumor(rumor)
+	data, _ := json.Marshal(rumor)
+	content, err := encrypt(data, signer, recipientPK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seal, err := sign(events.Event{
+		PubKey:    publicKey(t, signer),
+		CreatedAt: 1700000000,
+		Kind:      SealKind,
+		Tags:      []events.Tag{},
+		Content:   content,
+	}, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return seal
+}
+
+type UnwrapErrorTestCase struct {
+	name          string
+	wrap          func(t *testing.T) events.Event
+	expectedError string
+}
+
+var unwrapErrorTestCases = []UnwrapErrorTestCase{
+	{
+		name: "wrong kind",
+		wrap: func(t *testing.T) events.Event {
+			seal, _ := Seal(testRumor(), testSK, publicKey(t, recipientSK))
+			return seal
+		},
+		expectedError: "gift wrap is invalid: kind 13, expected 1059",
+	},
+	{
+		name: "tampered wrap",
+		wrap: func(t *testing.T) events.Event {
+			wrap, _ := GiftWrap(testRumor(), testSK, publicKey(t, recipientSK))
+			wrap.Tags = append(wrap.Tags, events.Tag{"t", "tampered"})
+			return wrap
+		},
+		expectedError: "gift wrap is invalid: event id",
+	},
+	{
+		name: "wrong recipient",
+		wrap: func(t *testing.T) events.Event {
+			wrap, _ := GiftWrap(testRumor(), testSK, publicKey(t, otherSK))
+			return wrap
+		},
+		expectedError: "gift wrap is invalid: invalid mac",
+	},
+	{
+		name: "seal author does not match rumor",
+		wrap: func(t *testing.T) events.Event {
+			recipientPK := publicKey(t, recipientSK)
+			wrap, _ := Wrap(forgedSeal(t, otherSK, testRumor(), recipientPK), recipientPK)
+			return wrap
+		},
+		expectedError: "gift wrap is invalid: seal author does not match rumor pubkey",
+	},
+	{
+		name: "rumor id mismatch",
+		wrap: func(t *testing.T) events.Event {
+			recipientPK := publicKey(t, recipientSK)
+			seal := forgedSeal(t, testSK, testRumor(), recipientPK)
+			seal.Content, _ = encrypt([]byte(`{"id":"00","pubkey":"`+testPK+`","created_at":1,"kind":14,"tags":[],"content":"x"}`), testSK, recipientPK)
+			seal, _ = sign(seal, testSK)
+			wrap, _ := Wrap(seal, recipientPK)
+			return wrap
+		},
+		expectedError: "gift wrap is invalid: rumor id does not match computed id",
+	},
+}
+
+func TestUnwrapErrors(t *testing.T) {
+	for _, tc := range unwrapErrorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Unwrap(tc.wrap(t), recipientSK)
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestWrapRequiresSeal(t *testing.T) {
+	_, err := Wrap(testRumor(), testPK)
+	assert.ErrorContains(t, err, "gift wrap is invalid: kind 14, expected 13")
+}
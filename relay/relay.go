@@ -0,0 +1,523 @@
+// Package relay serves the NIP-01 relay protocol over WebSocket, backed by
+// a store.Store.
+//
+// Published events are validated, saved, and delivered to every open
+// subscription with a matching filter. Subscriptions first receive the
+// stored events that match, newest first, followed by EOSE.
+//
+// A relay information document, if given, is served over HTTP and its
+// limitation enforced, including NIP-42 authentication when it requires
+// auth.
+package relay
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"git.wisehodl.dev/jay/go-roots/messages"
+	"git.wisehodl.dev/jay/go-roots/nip11"
+	"git.wisehodl.dev/jay/go-roots/nip42"
+	"git.wisehodl.dev/jay/go-roots/store"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options configures a relay. The zero value applies no limits.
+type Options struct {
+	// MaxSubscriptions limits the open subscriptions per connection.
+	// Info's max_subscriptions takes precedence when set.
+	MaxSubscriptions int
+
+	// MaxMessageLength limits the size in bytes of incoming messages.
+	// Connections that exceed it are closed. Info's max_message_length takes
+	// precedence when set.
+	MaxMessageLength int64
+
+	// SendBuffer is the number of outgoing messages queued per connection
+	// before the connection is considered too slow and closed.
+	// Defaults to 256.
+	SendBuffer int
+
+	// Info is served as the relay information document to HTTP requests
+	// that accept application/nostr+json. The relay enforces the limits it
+	// advertises: events and filters are checked against its limitation,
+	// which also sets the subscription, message and subscription ID length
+	// limits. If it requires auth, clients must authenticate with NIP-42
+	// before publishing or subscribing.
+	Info *nip11.Document
+
+	// URL is the relay's public address, which NIP-42 authentication events
+	// must name. Defaults to ws:// and the Host of the connection request.
+	URL string
+
+	// CheckOrigin decides whether to accept a WebSocket handshake based on
+	// its Origin header. Nil accepts every origin.
+	CheckOrigin func(r *http.Request) bool
+}
+
+// Relay is an http.Handler that serves the relay protocol.
+type Relay struct {
+	store    store.Store
+	opts     Options
+	upgrader websocket.Upgrader
+
+	mu     sync.Mutex
+	conns  map[*conn]struct{}
+	closed bool
+}
+
+// maxSubscriptionIDLength is the longest subscription ID allowed by NIP-01.
+const maxSubscriptionIDLength = 64
+
+// maxAuthSkew is how far the created_at of an authentication event may be
+// from the current time.
+const maxAuthSkew = 10 * time.Minute
+
+// New returns a relay that stores events in s.
+func New(s store.Store, opts Options) *Relay {
+	if opts.SendBuffer <= 0 {
+		opts.SendBuffer = 256
+	}
+	checkOrigin := opts.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = func(*http.Request) bool { return true }
+	}
+	return &Relay{
+		store:    s,
+		opts:     opts,
+		upgrader: websocket.Upgrader{CheckOrigin: checkOrigin},
+		conns:    make(map[*conn]struct{}),
+	}
+}
+
+// ServeHTTP upgrades the request to a WebSocket connection and serves it
+// until the client disconnects or the relay is closed.
+func (r *Relay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !websocket.IsWebSocketUpgrade(req) {
+		if r.opts.Info != nil && strings.Contains(req.Header.Get("Accept"), nip11.MediaType) {
+			r.serveInfo(w)
+			return
+		}
+		http.Error(w, "this is a nostr relay, connect with a websocket client", http.StatusUpgradeRequired)
+		return
+	}
+
+	r.mu.Lock()
+	closed := r.closed
+	r.mu.Unlock()
+	if closed {
+		http.Error(w, "relay is closed", http.StatusServiceUnavailable)
+		return
+	}
+
+	ws, err := r.upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		// The upgrader has already written an error response
+		return
+	}
+	if max := r.maxMessageLength(); max > 0 {
+		ws.SetReadLimit(max)
+	}
+
+	c := newConn(ws, r.opts.SendBuffer)
+	if !r.register(c) {
+		c.close()
+		return
+	}
+	defer r.unregister(c)
+
+	go c.writeLoop()
+	if r.authRequired() {
+		c.url = r.opts.URL
+		if c.url == "" {
+			c.url = "ws://" + req.Host
+		}
+		if c.challenge, err = newChallenge(); err != nil {
+			return
+		}
+		c.send(messages.AuthChallenge{Challenge: c.challenge})
+	}
+	c.readLoop(r)
+}
+
+func (r *Relay) serveInfo(w http.ResponseWriter) {
+	data, err := nip11.MarshalJSON(*r.opts.Info)
+	if err != nil {
+		http.Error(w, "could not encode relay information", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", nip11.MediaType)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Write(data)
+}
+
+// limitation returns the limitation advertised in the information document.
+func (r *Relay) limitation() (nip11.Limitation, bool) {
+	if r.opts.Info == nil || r.opts.Info.Limitation == nil {
+		return nip11.Limitation{}, false
+	}
+	return *r.opts.Info.Limitation, true
+}
+
+// maxSubscriptions returns the limit on open subscriptions per connection.
+func (r *Relay) maxSubscriptions() int {
+	if l, ok := r.limitation(); ok && l.MaxSubscriptions != nil {
+		return *l.MaxSubscriptions
+	}
+	return r.opts.MaxSubscriptions
+}
+
+// maxMessageLength returns the limit on the size of incoming messages.
+func (r *Relay) maxMessageLength() int64 {
+	if l, ok := r.limitation(); ok && l.MaxMessageLength != nil {
+		return int64(*l.MaxMessageLength)
+	}
+	return r.opts.MaxMessageLength
+}
+
+// maxSubIDLength returns the limit on the length of subscription IDs.
+func (r *Relay) maxSubIDLength() int {
+	if l, ok := r.limitation(); ok && l.MaxSubIDLength != nil {
+		return *l.MaxSubIDLength
+	}
+	return maxSubscriptionIDLength
+}
+
+// authRequired reports whether clients must authenticate.
+func (r *Relay) authRequired() bool {
+	l, ok := r.limitation()
+	return ok && l.AuthRequired != nil && *l.AuthRequired
+}
+
+// newChallenge returns a random NIP-42 challenge.
+func newChallenge() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Close disconnects every client and rejects new connections.
+func (r *Relay) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	conns := make([]*conn, 0, len(r.conns))
+	for c := range r.conns {
+		conns = append(conns, c)
+	}
+	r.mu.Unlock()
+
+	for _, c := range conns {
+		c.close()
+	}
+	return nil
+}
+
+func (r *Relay) register(c *conn) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return false
+	}
+	r.conns[c] = struct{}{}
+	return true
+}
+
+func (r *Relay) unregister(c *conn) {
+	r.mu.Lock()
+	delete(r.conns, c)
+	r.mu.Unlock()
+	c.close()
+}
+
+func (r *Relay) handle(c *conn, data []byte) {
+	m, err := messages.Unmarshal(data)
+	if err != nil {
+		c.send(messages.Notice{Message: messages.Reason(messages.Error, err.Error())})
+		return
+	}
+
+	switch m := m.(type) {
+	case messages.Event:
+		r.handleEvent(c, m.Event)
+	case messages.Req:
+		r.handleReq(c, m)
+	case messages.Close:
+		c.unsubscribe(m.SubscriptionID)
+	case messages.Auth:
+		r.handleAuth(c, m.Event)
+	default:
+		c.send(messages.Notice{Message: messages.Reason(messages.Error, "unsupported message type "+m.Label())})
+	}
+}
+
+func (r *Relay) handleAuth(c *conn, e events.Event) {
+	if c.challenge == "" {
+		c.send(messages.OK{EventID: e.ID, Message: messages.Reason(messages.Error, "authentication is not required")})
+		return
+	}
+	pubKey, err := nip42.VerifyAuth(e, c.challenge, c.url, maxAuthSkew)
+	if err != nil {
+		c.send(messages.OK{EventID: e.ID, Message: messages.Reason(messages.Invalid, err.Error())})
+		return
+	}
+	c.authenticate(pubKey)
+	c.send(messages.OK{EventID: e.ID, Accepted: true})
+}
+
+func (r *Relay) handleEvent(c *conn, e events.Event) {
+	if r.authRequired() && !c.authenticated() {
+		c.send(messages.OK{EventID: e.ID, Message: messages.Reason(messages.AuthRequired, "authenticate to publish")})
+		return
+	}
+	if err := events.Validate(e); err != nil {
+		c.send(messages.OK{EventID: e.ID, Message: messages.Reason(messages.Invalid, err.Error())})
+		return
+	}
+	if l, ok := r.limitation(); ok {
+		if err := nip11.CheckEvent(e, l, int(time.Now().Unix())); err != nil {
+			prefix := messages.Invalid
+			if stderrors.Is(err, errors.InsufficientPoW) {
+				prefix = messages.PoW
+			}
+			c.send(messages.OK{EventID: e.ID, Message: messages.Reason(prefix, err.Error())})
+			return
+		}
+	}
+
+	err := r.store.Save(c.ctx, e)
+	switch {
+	case err == nil:
+		c.send(messages.OK{EventID: e.ID, Accepted: true})
+		r.broadcast(e)
+	case stderrors.Is(err, errors.DuplicateEvent):
+		c.send(messages.OK{EventID: e.ID, Accepted: true, Message: messages.Reason(messages.Duplicate, err.Error())})
+	case stderrors.Is(err, errors.ReplacedEvent):
+		c.send(messages.OK{EventID: e.ID, Message: messages.Reason(messages.Duplicate, err.Error())})
+	default:
+		c.send(messages.OK{EventID: e.ID, Message: messages.Reason(messages.Error, "could not save event")})
+	}
+}
+
+func (r *Relay) handleReq(c *conn, req messages.Req) {
+	id := req.SubscriptionID
+	if max := r.maxSubIDLength(); id == "" || len(id) > max {
+		c.send(messages.Closed{SubscriptionID: id, Message: messages.Reason(messages.Invalid, fmt.Sprintf("subscription id must be 1 to %d characters", max))})
+		return
+	}
+	if r.authRequired() && !c.authenticated() {
+		c.send(messages.Closed{SubscriptionID: id, Message: messages.Reason(messages.AuthRequired, "authenticate to subscribe")})
+		return
+	}
+	if len(req.Filters) == 0 {
+		c.send(messages.Closed{SubscriptionID: id, Message: messages.Reason(messages.Invalid, "at least one filter is required")})
+		return
+	}
+	sub, ok := c.subscribe(id, req.Filters, r.maxSubscriptions())
+	if !ok {
+		c.send(messages.Closed{SubscriptionID: id, Message: messages.Reason(messages.Blocked, "too many subscriptions")})
+		return
+	}
+
+	// Events matching several filters are sent once
+	stored := []events.Event{}
+	sent := make(map[string]struct{})
+	for _, f := range req.Filters {
+		if l, ok := r.limitation(); ok {
+			f = nip11.ClampFilter(f, l)
+		}
+		results, err := r.store.Query(c.ctx, f)
+		if err != nil {
+			c.unsubscribe(id)
+			c.send(messages.Closed{SubscriptionID: id, Message: messages.Reason(messages.Error, "could not query events")})
+			return
+		}
+		for _, e := range results {
+			if _, ok := sent[e.ID]; ok {
+				continue
+			}
+			sent[e.ID] = struct{}{}
+			stored = append(stored, e)
+		}
+	}
+
+	store.Sort(stored)
+	for _, e := range stored {
+		c.send(messages.Event{SubscriptionID: id, Event: e})
+	}
+	c.goLive(id, sub, sent)
+}
+
+// broadcast delivers a newly saved event to every matching subscription.
+func (r *Relay) broadcast(e events.Event) {
+	r.mu.Lock()
+	conns := make([]*conn, 0, len(r.conns))
+	for c := range r.conns {
+		conns = append(conns, c)
+	}
+	r.mu.Unlock()
+
+	for _, c := range conns {
+		c.deliver(e)
+	}
+}
+
+// conn is a client connection. Outgoing messages are queued and written by
+// a single goroutine, so that a slow client cannot block the relay.
+type conn struct {
+	ws     *websocket.Conn
+	queue  chan []byte
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// challenge and url are set before reading when auth is required
+	challenge string
+	url       string
+
+	mu     sync.Mutex
+	subs   map[string]*subscription
+	authed map[string]struct{}
+}
+
+// subscription is an open subscription. Until its stored events and EOSE
+// have been sent, matching live events are held in pending.
+type subscription struct {
+	filters []filters.Filter
+	live    bool
+	pending []events.Event
+}
+
+func newConn(ws *websocket.Conn, buffer int) *conn {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &conn{
+		ws:     ws,
+		queue:  make(chan []byte, buffer),
+		ctx:    ctx,
+		cancel: cancel,
+		subs:   make(map[string]*subscription),
+		authed: make(map[string]struct{}),
+	}
+}
+
+func (c *conn) readLoop(r *Relay) {
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		r.handle(c, data)
+	}
+}
+
+func (c *conn) writeLoop() {
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case data := <-c.queue:
+			if err := c.ws.WriteMessage(websocket.TextMessage, data); err != nil {
+				c.close()
+				return
+			}
+		}
+	}
+}
+
+// send queues a message, closing the connection if its queue is full.
+func (c *conn) send(m messages.Message) {
+	data, err := messages.Marshal(m)
+	if err != nil {
+		return
+	}
+	select {
+	case <-c.ctx.Done():
+	case c.queue <- data:
+	default:
+		c.close()
+	}
+}
+
+func (c *conn) close() {
+	c.cancel()
+	c.ws.Close()
+}
+
+// subscribe opens or replaces a subscription, unless it would exceed max.
+func (c *conn) subscribe(id string, fs []filters.Filter, max int) (*subscription, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.subs[id]; !exists && max > 0 && len(c.subs) >= max {
+		return nil, false
+	}
+	sub := &subscription{filters: fs}
+	c.subs[id] = sub
+	return sub, true
+}
+
+// goLive sends EOSE and the live events held back while stored events were
+// sent, skipping those already sent, and then delivers live events directly.
+// Nothing is sent if the subscription has since been closed or replaced.
+func (c *conn) goLive(id string, sub *subscription, sent map[string]struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.subs[id] != sub {
+		return
+	}
+	c.send(messages.EOSE{SubscriptionID: id})
+	for _, e := range sub.pending {
+		if _, ok := sent[e.ID]; !ok {
+			c.send(messages.Event{SubscriptionID: id, Event: e})
+		}
+	}
+	sub.pending = nil
+	sub.live = true
+}
+
+// authenticate records a public key the client has authenticated as.
+func (c *conn) authenticate(pubKey string) {
+	c.mu.Lock()
+	c.authed[pubKey] = struct{}{}
+	c.mu.Unlock()
+}
+
+// authenticated reports whether the client has authenticated.
+func (c *conn) authenticated() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.authed) > 0
+}
+
+func (c *conn) unsubscribe(id string) {
+	c.mu.Lock()
+	delete(c.subs, id)
+	c.mu.Unlock()
+}
+
+// deliver sends a live event to every subscription with a matching filter,
+// or holds it back for subscriptions still sending stored events.
+func (c *conn) deliver(e events.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, sub := range c.subs {
+		for _, f := range sub.filters {
+			if filters.Matches(f, e) {
+				if sub.live {
+					c.send(messages.Event{SubscriptionID: id, Event: e})
+				} else {
+					sub.pending = append(sub.pending, e)
+				}
+				break
+			}
+		}
+	}
+}
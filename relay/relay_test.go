@@ -0,0 +1,525 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"git.wisehodl.dev/jay/go-roots/messages"
+	"git.wisehodl.dev/jay/go-roots/nip11"
+	"git.wisehodl.dev/jay/go-roots/nip42"
+	"git.wisehodl.dev/jay/go-roots/store"
+	"git.wisehodl.dev/jay/go-roots/store/memory"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSK = "f43a0435f69529f310bbd1d6263d2fbf0977f54bfe2310cc37ae5904b83bb167"
+const testPK = "cfa87f35acbde29ba1ab3ee42de527b2cad33ac487e80cf2d6405ea0042c8fef"
+
+func signedEvent(t *testing.T, kind, createdAt int, content string) events.Event {
+	e := events.Event{
+		PubKey:    testPK,
+		CreatedAt: createdAt,
+		Kind:      kind,
+		Tags:      []events.Tag{},
+		Content:   content,
+	}
+	var err error
+	if e.ID, err = events.GetID(e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Sig, err = events.SignEvent(e.ID, testSK); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func startRelay(t *testing.T, opts Options) (*Relay, string) {
+	r := New(memory.New(), opts)
+	server := httptest.NewServer(r)
+	t.Cleanup(func() {
+		r.Close()
+		server.Close()
+	})
+	return r, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+type testClient struct {
+	t        *testing.T
+	ws       *websocket.Conn
+	incoming chan messages.Message
+	done     chan struct{}
+}
+
+// dial connects to the relay and reads messages in the background, since a
+// timed out read breaks a websocket connection.
+func dial(t *testing.T, url string) *testClient {
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+
+	c := &testClient{
+		t:        t,
+		ws:       ws,
+		incoming: make(chan messages.Message, 100),
+		done:     make(chan struct{}),
+	}
+	go func() {
+		defer close(c.done)
+		for {
+			_, data, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			if m, err := messages.Unmarshal(data); err == nil {
+				c.incoming <- m
+			}
+		}
+	}()
+	return c
+}
+
+func (c *testClient) send(m messages.Message) {
+	data, err := messages.Marshal(m)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.sendRaw(string(data))
+}
+
+func (c *testClient) sendRaw(data string) {
+	if err := c.ws.WriteMessage(websocket.TextMessage, []byte(data)); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *testClient) receive() messages.Message {
+	select {
+	case m := <-c.incoming:
+		return m
+	case <-time.After(2 * time.Second):
+		c.t.Fatal("timed out waiting for message")
+		return nil
+	}
+}
+
+// expectSilence asserts that no message arrives within a short window.
+func (c *testClient) expectSilence() {
+	select {
+	case m := <-c.incoming:
+		c.t.Fatalf("unexpected %s message", m.Label())
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// expectDisconnect asserts that the relay closes the connection.
+func (c *testClient) expectDisconnect() {
+	select {
+	case <-c.done:
+	case <-time.After(2 * time.Second):
+		c.t.Fatal("connection was not closed")
+	}
+}
+
+func (c *testClient) publish(e events.Event) messages.OK {
+	c.send(messages.Event{Event: e})
+	ok, isOK := c.receive().(messages.OK)
+	if !isOK {
+		c.t.Fatal("expected OK")
+	}
+	return ok
+}
+
+// subscribe opens a subscription and returns the stored events before EOSE.
+func (c *testClient) subscribe(id string, fs ...filters.Filter) []events.Event {
+	c.send(messages.Req{SubscriptionID: id, Filters: fs})
+	stored := []events.Event{}
+	for {
+		switch m := c.receive().(type) {
+		case messages.Event:
+			assert.Equal(c.t, id, m.SubscriptionID)
+			stored = append(stored, m.Event)
+		case messages.EOSE:
+			assert.Equal(c.t, id, m.SubscriptionID)
+			return stored
+		default:
+			c.t.Fatalf("unexpected %s", m.Label())
+		}
+	}
+}
+
+func TestPublish(t *testing.T) {
+	_, url := startRelay(t, Options{})
+	c := dial(t, url)
+	e := signedEvent(t, 1, 1000, "hello")
+
+	ok := c.publish(e)
+	assert.Equal(t, messages.OK{EventID: e.ID, Accepted: true}, ok)
+
+	ok = c.publish(e)
+	assert.True(t, ok.Accepted)
+	assert.Equal(t, messages.Duplicate, messages.ReasonPrefix(ok.Message))
+}
+
+func TestPublishInvalidEvent(t *testing.T) {
+	_, url := startRelay(t, Options{})
+	c := dial(t, url)
+	e := signedEvent(t, 1, 1000, "hello")
+	e.Content = "tampered"
+
+	ok := c.publish(e)
+	assert.False(t, ok.Accepted)
+	assert.Equal(t, messages.Invalid, messages.ReasonPrefix(ok.Message))
+}
+
+func TestPublishReplacedEvent(t *testing.T) {
+	_, url := startRelay(t, Options{})
+	c := dial(t, url)
+
+	assert.True(t, c.publish(signedEvent(t, 0, 2000, "new profile")).Accepted)
+	ok := c.publish(signedEvent(t, 0, 1000, "old profile"))
+	assert.False(t, ok.Accepted)
+	assert.Equal(t, messages.Duplicate, messages.ReasonPrefix(ok.Message))
+}
+
+func TestSubscribeStoredEvents(t *testing.T) {
+	_, url := startRelay(t, Options{})
+	c := dial(t, url)
+	notes := []events.Event{}
+	for i := 0; i < 5; i++ {
+		notes = append(notes, signedEvent(t, 1, 1000+i, fmt.Sprintf("note %d", i)))
+		c.publish(notes[i])
+	}
+	c.publish(signedEvent(t, 7, 2000, "+"))
+
+	stored := c.subscribe("notes", filters.Filter{Kinds: []int{1}, Limit: intPtr(3)})
+	assert.Equal(t, []events.Event{notes[4], notes[3], notes[2]}, stored)
+
+	// Events matching several filters are sent once
+	stored = c.subscribe("overlap",
+		filters.Filter{IDs: []string{notes[0].ID}},
+		filters.Filter{Kinds: []int{1}, Until: intPtr(1001)})
+	assert.Equal(t, []events.Event{notes[1], notes[0]}, stored)
+
+	// Results of several filters are merged newest first
+	stored = c.subscribe("merged",
+		filters.Filter{IDs: []string{notes[0].ID}},
+		filters.Filter{IDs: []string{notes[3].ID}},
+		filters.Filter{Kinds: []int{7}})
+	assert.Equal(t, "+", stored[0].Content)
+	assert.Equal(t, []events.Event{notes[3], notes[0]}, stored[1:])
+}
+
+// slowStore holds queries until released.
+type slowStore struct {
+	store.Store
+	querying chan struct{}
+	release  chan struct{}
+}
+
+func (s *slowStore) Query(ctx context.Context, f filters.Filter) ([]events.Event, error) {
+	s.querying <- struct{}{}
+	<-s.release
+	return s.Store.Query(ctx, f)
+}
+
+func TestLiveEventsHeldUntilEOSE(t *testing.T) {
+	slow := &slowStore{Store: memory.New(), querying: make(chan struct{}, 1), release: make(chan struct{})}
+	r := New(slow, Options{})
+	server := httptest.NewServer(r)
+	t.Cleanup(func() {
+		r.Close()
+		server.Close()
+	})
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	subscriber := dial(t, url)
+	publisher := dial(t, url)
+	subscriber.send(messages.Req{SubscriptionID: "sub", Filters: []filters.Filter{{}}})
+	<-slow.querying
+
+	// Saved and broadcast while the query is running
+	note := signedEvent(t, 1, 1000, "note")
+	assert.True(t, publisher.publish(note).Accepted)
+	subscriber.expectSilence()
+
+	close(slow.release)
+	assert.Equal(t, messages.Event{SubscriptionID: "sub", Event: note}, subscriber.receive())
+	assert.Equal(t, messages.EOSE{SubscriptionID: "sub"}, subscriber.receive())
+	subscriber.expectSilence()
+
+	live := signedEvent(t, 1, 1001, "live")
+	assert.True(t, publisher.publish(live).Accepted)
+	assert.Equal(t, messages.Event{SubscriptionID: "sub", Event: live}, subscriber.receive())
+}
+
+func TestLiveEvents(t *testing.T) {
+	_, url := startRelay(t, Options{})
+	publisher := dial(t, url)
+	subscriber := dial(t, url)
+
+	assert.Empty(t, subscriber.subscribe("live", filters.Filter{Kinds: []int{1}}))
+
+	note := signedEvent(t, 1, 1000, "live note")
+	publisher.publish(note)
+	assert.Equal(t, messages.Event{SubscriptionID: "live", Event: note}, subscriber.receive())
+
+	// Non-matching events are not delivered
+	publisher.publish(signedEvent(t, 7, 1000, "+"))
+	subscriber.expectSilence()
+
+	// Duplicates are not delivered again
+	publisher.publish(note)
+	subscriber.expectSilence()
+
+	// Closed subscriptions receive nothing
+	subscriber.send(messages.Close{SubscriptionID: "live"})
+	subscriber.expectSilence()
+	publisher.publish(signedEvent(t, 1, 1001, "after close"))
+	subscriber.expectSilence()
+}
+
+func TestLiveEphemeralEvents(t *testing.T) {
+	_, url := startRelay(t, Options{})
+	publisher := dial(t, url)
+	subscriber := dial(t, url)
+	subscriber.subscribe("ephemeral", filters.Filter{Kinds: []int{20001}})
+
+	e := signedEvent(t, 20001, 1000, "ephemeral")
+	assert.True(t, publisher.publish(e).Accepted)
+	assert.Equal(t, messages.Event{SubscriptionID: "ephemeral", Event: e}, subscriber.receive())
+
+	// Ephemeral events are not stored
+	assert.Empty(t, publisher.subscribe("stored", filters.Filter{Kinds: []int{20001}}))
+}
+
+func TestReplaceSubscription(t *testing.T) {
+	_, url := startRelay(t, Options{})
+	publisher := dial(t, url)
+	subscriber := dial(t, url)
+	subscriber.subscribe("sub", filters.Filter{Kinds: []int{1}})
+	subscriber.subscribe("sub", filters.Filter{Kinds: []int{7}})
+
+	publisher.publish(signedEvent(t, 1, 1000, "note"))
+	subscriber.expectSilence()
+
+	reaction := signedEvent(t, 7, 1000, "+")
+	publisher.publish(reaction)
+	assert.Equal(t, messages.Event{SubscriptionID: "sub", Event: reaction}, subscriber.receive())
+}
+
+type ReqErrorTestCase struct {
+	name     string
+	message  string
+	expected messages.Message
+}
+
+var reqErrorTestCases = []ReqErrorTestCase{
+	{
+		name:     "empty subscription id",
+		message:  `["REQ","",{}]`,
+		expected: messages.Closed{SubscriptionID: "", Message: "invalid: subscription id must be 1 to 64 characters"},
+	},
+
+	{
+		name:     "long subscription id",
+		message:  `["REQ","` + strings.Repeat("x", 65) + `",{}]`,
+		expected: messages.Closed{SubscriptionID: strings.Repeat("x", 65), Message: "invalid: subscription id must be 1 to 64 characters"},
+	},
+
+	{
+		name:     "no filters",
+		message:  `["REQ","sub"]`,
+		expected: messages.Closed{SubscriptionID: "sub", Message: "invalid: at least one filter is required"},
+	},
+
+	{
+		name:     "malformed message",
+		message:  `["REQ"]`,
+		expected: messages.Notice{Message: "error: malformed message"},
+	},
+
+	{
+		name:     "unknown message",
+		message:  `["HELLO"]`,
+		expected: messages.Notice{Message: "error: unknown message type"},
+	},
+
+	{
+		name:     "relay message",
+		message:  `["EOSE","sub"]`,
+		expected: messages.Notice{Message: "error: unsupported message type EOSE"},
+	},
+}
+
+func TestReqErrors(t *testing.T) {
+	_, url := startRelay(t, Options{})
+	c := dial(t, url)
+	for _, tc := range reqErrorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c.sendRaw(tc.message)
+			assert.Equal(t, tc.expected, c.receive())
+		})
+	}
+}
+
+func TestMaxSubscriptions(t *testing.T) {
+	_, url := startRelay(t, Options{MaxSubscriptions: 2})
+	c := dial(t, url)
+	c.subscribe("one", filters.Filter{})
+	c.subscribe("two", filters.Filter{})
+
+	c.send(messages.Req{SubscriptionID: "three", Filters: []filters.Filter{{}}})
+	closed := c.receive().(messages.Closed)
+	assert.Equal(t, "three", closed.SubscriptionID)
+	assert.Equal(t, messages.Blocked, messages.ReasonPrefix(closed.Message))
+
+	// Replacing an open subscription is allowed
+	c.subscribe("two", filters.Filter{Kinds: []int{1}})
+
+	c.send(messages.Close{SubscriptionID: "one"})
+	c.subscribe("three", filters.Filter{})
+}
+
+func TestMaxMessageLength(t *testing.T) {
+	_, url := startRelay(t, Options{MaxMessageLength: 100})
+	c := dial(t, url)
+	c.sendRaw(`["NOTICE","` + strings.Repeat("x", 200) + `"]`)
+	c.expectDisconnect()
+}
+
+func TestPlainHTTP(t *testing.T) {
+	r := New(memory.New(), Options{})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusUpgradeRequired, w.Code)
+}
+
+func TestClose(t *testing.T) {
+	r, url := startRelay(t, Options{})
+	c := dial(t, url)
+	c.subscribe("sub", filters.Filter{})
+
+	assert.NoError(t, r.Close())
+	c.expectDisconnect()
+
+	_, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.Error(t, err)
+}
+
+func TestInfoDocument(t *testing.T) {
+	info := &nip11.Document{Name: "test relay", SupportedNIPs: []int{1, 11}}
+	r := New(memory.New(), Options{Info: info})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", nip11.MediaType)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, nip11.MediaType, w.Header().Get("Content-Type"))
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.JSONEq(t, `{"name":"test relay","supported_nips":[1,11]}`, w.Body.String())
+}
+
+func TestLimitation(t *testing.T) {
+	_, url := startRelay(t, Options{Info: &nip11.Document{
+		Limitation: &nip11.Limitation{MaxContentLength: intPtr(10), MaxLimit: intPtr(2)},
+	}})
+	c := dial(t, url)
+
+	ok := c.publish(signedEvent(t, 1, 1000, "this content is too long"))
+	assert.False(t, ok.Accepted)
+	assert.Equal(t, messages.Invalid, messages.ReasonPrefix(ok.Message))
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, c.publish(signedEvent(t, 1, 1000+i, "short")).Accepted)
+	}
+	assert.Len(t, c.subscribe("clamped", filters.Filter{Limit: intPtr(10)}), 2)
+}
+
+func TestPoWLimitation(t *testing.T) {
+	_, url := startRelay(t, Options{Info: &nip11.Document{
+		Limitation: &nip11.Limitation{MinPowDifficulty: intPtr(64)},
+	}})
+	c := dial(t, url)
+
+	ok := c.publish(signedEvent(t, 1, 1000, "no work"))
+	assert.False(t, ok.Accepted)
+	assert.Equal(t, messages.PoW, messages.ReasonPrefix(ok.Message))
+}
+
+func TestLimitationOverridesOptions(t *testing.T) {
+	_, url := startRelay(t, Options{
+		MaxSubscriptions: 5,
+		MaxMessageLength: 10000,
+		Info: &nip11.Document{Limitation: &nip11.Limitation{
+			MaxSubscriptions: intPtr(1),
+			MaxMessageLength: intPtr(100),
+			MaxSubIDLength:   intPtr(4),
+		}},
+	})
+	c := dial(t, url)
+
+	c.send(messages.Req{SubscriptionID: "toolong", Filters: []filters.Filter{{}}})
+	assert.Equal(t, messages.Closed{SubscriptionID: "toolong", Message: "invalid: subscription id must be 1 to 4 characters"}, c.receive())
+
+	c.subscribe("one", filters.Filter{})
+	c.send(messages.Req{SubscriptionID: "two", Filters: []filters.Filter{{}}})
+	closed := c.receive().(messages.Closed)
+	assert.Equal(t, messages.Blocked, messages.ReasonPrefix(closed.Message))
+
+	c.sendRaw(`["NOTICE","` + strings.Repeat("x", 200) + `"]`)
+	c.expectDisconnect()
+}
+
+func TestAuthRequired(t *testing.T) {
+	_, url := startRelay(t, Options{Info: &nip11.Document{
+		Limitation: &nip11.Limitation{AuthRequired: boolPtr(true)},
+	}})
+	c := dial(t, url)
+	challenge := c.receive().(messages.AuthChallenge)
+	assert.NotEmpty(t, challenge.Challenge)
+
+	note := signedEvent(t, 1, 1000, "note")
+	refused := c.publish(note)
+	assert.False(t, refused.Accepted)
+	assert.Equal(t, messages.AuthRequired, messages.ReasonPrefix(refused.Message))
+
+	c.send(messages.Req{SubscriptionID: "sub", Filters: []filters.Filter{{}}})
+	closed := c.receive().(messages.Closed)
+	assert.Equal(t, messages.AuthRequired, messages.ReasonPrefix(closed.Message))
+
+	// Answering another challenge fails
+	wrong, err := nip42.SignAuthEvent(testSK, url, "other")
+	assert.NoError(t, err)
+	c.send(messages.Auth{Event: wrong})
+	assert.False(t, c.receive().(messages.OK).Accepted)
+
+	auth, err := nip42.SignAuthEvent(testSK, url, challenge.Challenge)
+	assert.NoError(t, err)
+	c.send(messages.Auth{Event: auth})
+	assert.Equal(t, messages.OK{EventID: auth.ID, Accepted: true}, c.receive())
+
+	assert.True(t, c.publish(note).Accepted)
+	assert.Equal(t, []events.Event{note}, c.subscribe("sub", filters.Filter{}))
+}
+
+func TestAuthNotRequired(t *testing.T) {
+	_, url := startRelay(t, Options{})
+	c := dial(t, url)
+	auth, err := nip42.SignAuthEvent(testSK, url, "challenge")
+	assert.NoError(t, err)
+	c.send(messages.Auth{Event: auth})
+	ok := c.receive().(messages.OK)
+	assert.False(t, ok.Accepted)
+}
@@ -0,0 +1,438 @@
+// Package file provides a durable implementation of store.Store backed by an
+// append-only event log.
+//
+// Every save and delete is appended to a single segment file as a record.
+// On open, the log is replayed into an in-memory index, so the whole
+// current event set is held in memory; the store suits small relays and
+// offline archives. Compact rewrites the log without replaced and deleted
+// events.
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"git.wisehodl.dev/jay/go-roots/store"
+	"git.wisehodl.dev/jay/go-roots/store/memory"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Format selects the record encoding of the log.
+type Format int
+
+const (
+	// JSONL writes one JSON array per line: ["EVENT", <event>] or
+	// ["DELETE", <id>].
+	JSONL Format = iota
+
+	// Binary writes length-prefixed records: a 4-byte big-endian payload
+	// length, a 1-byte operation, the payload (event JSON or ID), and a
+	// 4-byte big-endian CRC-32 of the operation and payload.
+	Binary
+)
+
+// Options configures a store.
+type Options struct {
+	// Format is the record encoding. It must match the existing log.
+	Format Format
+
+	// Sync flushes the log to stable storage after every write.
+	Sync bool
+}
+
+// Store is an event store backed by an append-only log file.
+type Store struct {
+	mu    sync.RWMutex
+	path  string
+	opts  Options
+	file  *os.File
+	index *memory.Store
+
+	// err is set when the store is closed or a write fails, after which
+	// the in-memory index may disagree with the log.
+	err error
+}
+
+var _ store.Store = (*Store)(nil)
+
+const (
+	opSave   byte = 1
+	opDelete byte = 2
+
+	// maxRecordSize bounds binary record payloads so that a damaged length
+	// prefix is not mistaken for a huge record.
+	maxRecordSize = 64 << 20
+)
+
+type record struct {
+	op    byte
+	event events.Event
+	id    string
+}
+
+// Open opens or creates the log at path and builds the index by replaying
+// it. A record cut short or failing its checksum at the end of the log, as
+// left by a crash during a write, is truncated away. A damaged record before
+// the end returns errors.CorruptLog.
+func Open(path string, opts Options) (*Store, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{path: path, opts: opts, file: f, index: memory.New()}
+	end, err := s.replay()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if err := f.Truncate(end); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(end, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// replay applies every complete record to the index and returns the offset
+// just past the last one.
+func (s *Store) replay() (int64, error) {
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	r := bufio.NewReader(s.file)
+	ctx := context.Background()
+
+	var offset int64
+	for {
+		rec, n, err := readRecord(r, s.opts.Format)
+		if err == io.EOF {
+			return offset, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		switch rec.op {
+		case opSave:
+			// Records were accepted when written, so replaying them in
+			// order cannot fail
+			s.index.Save(ctx, rec.event)
+		case opDelete:
+			s.index.Delete(ctx, rec.id)
+		}
+		offset += n
+	}
+}
+
+// Save validates the event and appends it to the log.
+func (s *Store) Save(ctx context.Context, e events.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := events.Validate(e); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+
+	if err := s.index.Save(ctx, e); err != nil {
+		return err
+	}
+	if events.IsEphemeral(e.Kind) {
+		return nil
+	}
+	return s.append(record{op: opSave, event: e})
+}
+
+// Delete appends a deletion of the event with the given ID to the log.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+
+	if err := s.index.Delete(ctx, id); err != nil {
+		return err
+	}
+	return s.append(record{op: opDelete, id: id})
+}
+
+// Query returns the matching events, newest first.
+func (s *Store) Query(ctx context.Context, f filters.Filter) ([]events.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.index.Query(ctx, f)
+}
+
+// Count returns the number of matching events, ignoring the filter's Limit.
+func (s *Store) Count(ctx context.Context, f filters.Filter) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.err != nil {
+		return 0, s.err
+	}
+	return s.index.Count(ctx, f)
+}
+
+// Compact rewrites the log so that it holds only the currently stored
+// events, oldest first. The new log replaces the old one atomically.
+func (s *Store) Compact(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+
+	current, err := s.index.Query(ctx, filters.Filter{})
+	if err != nil {
+		return err
+	}
+
+	tmpPath := s.path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	w := bufio.NewWriter(tmp)
+	for i := len(current) - 1; i >= 0; i-- {
+		data, err := encodeRecord(record{op: opSave, event: current[i]}, s.opts.Format)
+		if err == nil {
+			_, err = w.Write(data)
+		}
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	// The renamed file is now the log; continue appending to it
+	s.file.Close()
+	s.file = tmp
+
+	// The rename is durable once the directory is flushed
+	return syncDir(filepath.Dir(s.path))
+}
+
+// syncDir flushes a directory's entries to stable storage.
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// Close closes the log file. Later operations return errors.StoreClosed.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == errors.StoreClosed {
+		return nil
+	}
+	s.err = errors.StoreClosed
+	return s.file.Close()
+}
+
+// append writes a record at the end of the log. If the write fails, the
+// log is truncated back to its previous length and the store is failed,
+// since the index already reflects the record.
+func (s *Store) append(rec record) error {
+	data, err := encodeRecord(rec, s.opts.Format)
+	if err != nil {
+		s.err = err
+		return err
+	}
+
+	offset, err := s.file.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = s.file.Write(data)
+	}
+	if err == nil && s.opts.Sync {
+		err = s.file.Sync()
+	}
+	if err != nil {
+		s.file.Truncate(offset)
+		s.err = err
+		return err
+	}
+	return nil
+}
+
+func encodeRecord(rec record, format Format) ([]byte, error) {
+	var payload []byte
+	var err error
+	if rec.op == opSave {
+		payload, err = json.Marshal(rec.event)
+	} else {
+		payload, err = json.Marshal(rec.id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if format == JSONL {
+		label := `["EVENT",`
+		if rec.op == opDelete {
+			label = `["DELETE",`
+		}
+		line := append([]byte(label), payload...)
+		return append(line, ']', '\n'), nil
+	}
+
+	if rec.op == opDelete {
+		payload = []byte(rec.id)
+	}
+	data := make([]byte, 4, 4+1+len(payload)+4)
+	binary.BigEndian.PutUint32(data, uint32(len(payload)))
+	data = append(data, rec.op)
+	data = append(data, payload...)
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(data[4:])), nil
+}
+
+// readRecord reads the next record and its encoded size. It returns io.EOF
+// at the end of the log, including when the final record is incomplete or,
+// in the binary format, fails its checksum or has a length prefix longer
+// than the rest of the log.
+func readRecord(r *bufio.Reader, format Format) (record, int64, error) {
+	if format == JSONL {
+		return readJSONLRecord(r)
+	}
+	return readBinaryRecord(r)
+}
+
+func readJSONLRecord(r *bufio.Reader) (record, int64, error) {
+	line, err := r.ReadBytes('\n')
+	if err == io.EOF {
+		// A line without a newline was cut short
+		return record{}, 0, io.EOF
+	}
+	if err != nil {
+		return record{}, 0, err
+	}
+
+	var parts []json.RawMessage
+	if err := json.Unmarshal(line, &parts); err != nil || len(parts) != 2 {
+		return record{}, 0, errors.CorruptLog
+	}
+	var label string
+	if err := json.Unmarshal(parts[0], &label); err != nil {
+		return record{}, 0, errors.CorruptLog
+	}
+
+	rec := record{}
+	switch label {
+	case "EVENT":
+		rec.op = opSave
+		err = json.Unmarshal(parts[1], &rec.event)
+	case "DELETE":
+		rec.op = opDelete
+		err = json.Unmarshal(parts[1], &rec.id)
+	default:
+		err = errors.CorruptLog
+	}
+	if err != nil {
+		return record{}, 0, errors.CorruptLog
+	}
+	return rec, int64(len(line)), nil
+}
+
+func readBinaryRecord(r *bufio.Reader) (record, int64, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return record{}, 0, truncated(err)
+	}
+	size := binary.BigEndian.Uint32(header)
+	if size > maxRecordSize {
+		// A torn final record may have a length prefix longer than the
+		// rest of the log
+		if _, err := io.CopyN(io.Discard, r, int64(size)+4); err == io.EOF {
+			return record{}, 0, io.EOF
+		}
+		return record{}, 0, errors.CorruptLog
+	}
+
+	body := make([]byte, int(size)+4)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return record{}, 0, truncated(err)
+	}
+	payload := body[:size]
+	checksum := crc32.ChecksumIEEE(append([]byte{header[4]}, payload...))
+	if checksum != binary.BigEndian.Uint32(body[size:]) {
+		// The final record may have been torn by a crash during the write
+		if _, err := r.Peek(1); err == io.EOF {
+			return record{}, 0, io.EOF
+		}
+		return record{}, 0, errors.CorruptLog
+	}
+
+	rec := record{op: header[4]}
+	switch rec.op {
+	case opSave:
+		dec := json.NewDecoder(bytes.NewReader(payload))
+		if err := dec.Decode(&rec.event); err != nil {
+			return record{}, 0, errors.CorruptLog
+		}
+	case opDelete:
+		rec.id = string(payload)
+	default:
+		return record{}, 0, errors.CorruptLog
+	}
+	return rec, int64(len(header) + len(body)), nil
+}
+
+// truncated maps a short read to io.EOF, since the record was cut short.
+func truncated(err error) error {
+	if err == io.ErrUnexpectedEOF {
+		return io.EOF
+	}
+	return err
+}
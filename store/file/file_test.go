@@ -0,0 +1,310 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"git.wisehodl.dev/jay/go-roots/store"
+	"git.wisehodl.dev/jay/go-roots/store/storetest"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testSK = "f43a0435f69529f310bbd1d6263d2fbf0977f54bfe2310cc37ae5904b83bb167"
+const testPK = "cfa87f35acbde29ba1ab3ee42de527b2cad33ac487e80cf2d6405ea0042c8fef"
+
+var formats = map[string]Format{
+	"jsonl":  JSONL,
+	"binary": Binary,
+}
+
+func signedEvent(t *testing.T, kind, createdAt int, tags []events.Tag, content string) events.Event {
+	e := events.Event{
+		PubKey:    testPK,
+		CreatedAt: createdAt,
+		Kind:      kind,
+		Tags:      tags,
+		Content:   content,
+	}
+	id, err := events.GetID(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.ID = id
+	e.Sig, err = events.SignEvent(id, testSK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func signedNotes(t *testing.T, n int) []events.Event {
+	notes := make([]events.Event, n)
+	for i := range notes {
+		notes[i] = signedEvent(t, 1, 1000+i, []events.Tag{}, fmt.Sprintf("note %d", i))
+	}
+	return notes
+}
+
+func openTestStore(t *testing.T, path string, format Format) *Store {
+	s, err := Open(path, Options{Format: format})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func queryIDs(t *testing.T, s *Store, f filters.Filter) []string {
+	results, err := s.Query(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, e := range results {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func idsOf(evs ...events.Event) []string {
+	ids := []string{}
+	for _, e := range evs {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func TestPersistence(t *testing.T) {
+	for name, format := range formats {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), "events.log")
+			notes := signedNotes(t, 3)
+			profile := signedEvent(t, 0, 500, []events.Tag{}, "old profile")
+			newProfile := signedEvent(t, 0, 600, []events.Tag{}, "new profile")
+
+			s := openTestStore(t, path, format)
+			for _, e := range append(notes, profile, newProfile) {
+				assert.NoError(t, s.Save(ctx, e))
+			}
+			assert.NoError(t, s.Delete(ctx, notes[1].ID))
+			assert.NoError(t, s.Close())
+
+			s = openTestStore(t, path, format)
+			assert.Equal(t, idsOf(notes[2], notes[0], newProfile), queryIDs(t, s, filters.Filter{}))
+
+			count, err := s.Count(ctx, filters.Filter{Kinds: []int{1}})
+			assert.NoError(t, err)
+			assert.Equal(t, 2, count)
+
+			assert.ErrorContains(t, s.Save(ctx, profile), "event is replaced by a newer version")
+			assert.ErrorContains(t, s.Save(ctx, notes[0]), "event is already stored")
+		})
+	}
+}
+
+func TestSaveRejectsInvalidEvent(t *testing.T) {
+	s := openTestStore(t, filepath.Join(t.TempDir(), "events.log"), JSONL)
+	e := signedNotes(t, 1)[0]
+	e.Content = "tampered"
+
+	err := s.Save(context.Background(), e)
+	assert.ErrorContains(t, err, "does not match computed id")
+}
+
+func TestEphemeralEventsNotLogged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	s := openTestStore(t, path, JSONL)
+
+	assert.NoError(t, s.Save(context.Background(), signedEvent(t, 20001, 1000, []events.Tag{}, "")))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), info.Size())
+}
+
+func TestCompact(t *testing.T) {
+	for name, format := range formats {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), "events.log")
+			notes := signedNotes(t, 4)
+
+			s := openTestStore(t, path, format)
+			for _, e := range notes {
+				assert.NoError(t, s.Save(ctx, e))
+			}
+			for i := 0; i < 5; i++ {
+				profile := signedEvent(t, 0, 500+i, []events.Tag{}, "profile")
+				assert.NoError(t, s.Save(ctx, profile))
+			}
+			assert.NoError(t, s.Delete(ctx, notes[0].ID))
+			expectedIDs := queryIDs(t, s, filters.Filter{})
+
+			before, _ := os.Stat(path)
+			assert.NoError(t, s.Compact(ctx))
+			after, _ := os.Stat(path)
+			assert.Less(t, after.Size(), before.Size())
+			assert.Equal(t, expectedIDs, queryIDs(t, s, filters.Filter{}))
+
+			// Appends continue on the compacted log
+			extra := signedEvent(t, 1, 2000, []events.Tag{}, "after compaction")
+			assert.NoError(t, s.Save(ctx, extra))
+			assert.NoError(t, s.Close())
+
+			s = openTestStore(t, path, format)
+			assert.Equal(t, append(idsOf(extra), expectedIDs...), queryIDs(t, s, filters.Filter{}))
+
+			_, err := os.Stat(path + ".compact")
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestCrashRecovery(t *testing.T) {
+	for name, format := range formats {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+			path := filepath.Join(dir, "events.log")
+			notes := signedNotes(t, 4)
+
+			s := openTestStore(t, path, format)
+			for _, e := range notes[:2] {
+				assert.NoError(t, s.Save(ctx, e))
+			}
+			s.Close()
+			intact, _ := os.ReadFile(path)
+
+			s = openTestStore(t, path, format)
+			assert.NoError(t, s.Save(ctx, notes[2]))
+			s.Close()
+			full, _ := os.ReadFile(path)
+
+			// Cut the last record at every possible point, starting inside
+			// the length prefix of the binary format
+			var torn [][]byte
+			for cut := len(intact) + 1; cut < len(full); cut++ {
+				torn = append(torn, full[:cut])
+			}
+			if format == Binary {
+				// A torn length prefix may be garbage longer than the log
+				for cut := len(intact) + 1; cut < len(full); cut++ {
+					data := bytes.Clone(full[:cut])
+					for i := len(intact); i < min(cut, len(intact)+4); i++ {
+						data[i] = 0xff
+					}
+					torn = append(torn, data)
+				}
+			}
+
+			for i, data := range torn {
+				damaged := filepath.Join(dir, fmt.Sprintf("damaged-%d.log", i))
+				assert.NoError(t, os.WriteFile(damaged, data, 0o644))
+
+				s := openTestStore(t, damaged, format)
+				assert.Equal(t, idsOf(notes[1], notes[0]), queryIDs(t, s, filters.Filter{}), "torn log %d", i)
+
+				// The torn record is discarded and new records follow the intact ones
+				assert.NoError(t, s.Save(ctx, notes[3]))
+				s.Close()
+				repaired, _ := os.ReadFile(damaged)
+				assert.Equal(t, intact, repaired[:len(intact)])
+
+				s = openTestStore(t, damaged, format)
+				assert.Equal(t, idsOf(notes[3], notes[1], notes[0]), queryIDs(t, s, filters.Filter{}))
+			}
+		})
+	}
+}
+
+func TestTornFinalRecord(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.log")
+	notes := signedNotes(t, 3)
+
+	s := openTestStore(t, path, Binary)
+	assert.NoError(t, s.Save(ctx, notes[0]))
+	s.Close()
+	intact, _ := os.ReadFile(path)
+
+	s = openTestStore(t, path, Binary)
+	assert.NoError(t, s.Save(ctx, notes[1]))
+	s.Close()
+
+	// Damage the payload of the last record, leaving its length intact
+	data, _ := os.ReadFile(path)
+	data[len(intact)+10] ^= 0xff
+	assert.NoError(t, os.WriteFile(path, data, 0o644))
+
+	s = openTestStore(t, path, Binary)
+	assert.Equal(t, idsOf(notes[0]), queryIDs(t, s, filters.Filter{}))
+	assert.NoError(t, s.Save(ctx, notes[2]))
+	s.Close()
+
+	s = openTestStore(t, path, Binary)
+	assert.Equal(t, idsOf(notes[2], notes[0]), queryIDs(t, s, filters.Filter{}))
+}
+
+func TestCorruptRecord(t *testing.T) {
+	for name, format := range formats {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "events.log")
+			s := openTestStore(t, path, format)
+			for _, e := range signedNotes(t, 2) {
+				assert.NoError(t, s.Save(context.Background(), e))
+			}
+			s.Close()
+
+			// Damage the first record, leaving its length intact
+			data, _ := os.ReadFile(path)
+			data[10] ^= 0xff
+			assert.NoError(t, os.WriteFile(path, data, 0o644))
+
+			_, err := Open(path, Options{Format: format})
+			assert.ErrorContains(t, err, "event log is corrupt")
+		})
+	}
+}
+
+func TestClosedStore(t *testing.T) {
+	ctx := context.Background()
+	s := openTestStore(t, filepath.Join(t.TempDir(), "events.log"), JSONL)
+	assert.NoError(t, s.Close())
+	assert.NoError(t, s.Close())
+
+	assert.ErrorContains(t, s.Save(ctx, signedNotes(t, 1)[0]), "store is closed")
+	assert.ErrorContains(t, s.Delete(ctx, "id"), "store is closed")
+	_, err := s.Query(ctx, filters.Filter{})
+	assert.ErrorContains(t, err, "store is closed")
+	_, err = s.Count(ctx, filters.Filter{})
+	assert.ErrorContains(t, err, "store is closed")
+	assert.ErrorContains(t, s.Compact(ctx), "store is closed")
+}
+
+func TestCanceledContext(t *testing.T) {
+	s := openTestStore(t, filepath.Join(t.TempDir(), "events.log"), JSONL)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, s.Save(ctx, signedNotes(t, 1)[0]), context.Canceled)
+	assert.ErrorIs(t, s.Delete(ctx, "id"), context.Canceled)
+	_, err := s.Query(ctx, filters.Filter{})
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = s.Count(ctx, filters.Filter{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestConformance(t *testing.T) {
+	for name, format := range formats {
+		t.Run(name, func(t *testing.T) {
+			storetest.Run(t, func(t *testing.T) store.Store {
+				return openTestStore(t, filepath.Join(t.TempDir(), "events.log"), format)
+			})
+		})
+	}
+}
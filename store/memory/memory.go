@@ -0,0 +1,295 @@
+// Package memory provides a concurrency-safe, in-memory implementation of
+// store.Store, intended for tests and as a reference for other backends.
+package memory
+
+import (
+	"context"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"git.wisehodl.dev/jay/go-roots/store"
+	"sort"
+	"sync"
+)
+
+type idSet map[string]struct{}
+
+type tagKey struct {
+	name  string
+	value string
+}
+
+// Store is an in-memory event store. Events are indexed by ID, author, kind,
+// creation time and single-letter tag values. The zero value is not usable;
+// create stores with New.
+type Store struct {
+	mu sync.RWMutex
+
+	byID          map[string]events.Event
+	byAuthor      map[string]idSet
+	byKind        map[int]idSet
+	byTag         map[tagKey]idSet
+	byReplacement map[string]string
+
+	// timeline holds every stored event ID, newest first.
+	timeline []string
+}
+
+var _ store.Store = (*Store)(nil)
+
+// New returns an empty store.
+func New() *Store {
+	return &Store{
+		byID:          make(map[string]events.Event),
+		byAuthor:      make(map[string]idSet),
+		byKind:        make(map[int]idSet),
+		byTag:         make(map[tagKey]idSet),
+		byReplacement: make(map[string]string),
+	}
+}
+
+// Save stores a copy of the event. The event is not validated.
+func (s *Store) Save(ctx context.Context, e events.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if events.IsEphemeral(e.Kind) {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.byID[e.ID]; ok {
+		return errors.DuplicateEvent
+	}
+
+	key, replaceable := store.ReplacementKey(e)
+	if replaceable {
+		if oldID, ok := s.byReplacement[key]; ok {
+			if !store.Supersedes(e, s.byID[oldID]) {
+				return errors.ReplacedEvent
+			}
+			s.remove(oldID)
+		}
+		s.byReplacement[key] = e.ID
+	}
+
+	s.insert(store.Clone(e))
+	return nil
+}
+
+// Delete removes the event with the given ID.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.byID[id]; !ok {
+		return errors.EventNotFound
+	}
+	s.remove(id)
+	return nil
+}
+
+// Query returns copies of the matching events, newest first.
+func (s *Store) Query(ctx context.Context, f filters.Filter) ([]events.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	results := []events.Event{}
+	for _, id := range s.candidates(f) {
+		e := s.byID[id]
+		if filters.Matches(f, e) {
+			results = append(results, store.Clone(e))
+		}
+	}
+	store.Sort(results)
+	return store.Limit(results, f), nil
+}
+
+// Count returns the number of matching events, ignoring the filter's Limit.
+func (s *Store) Count(ctx context.Context, f filters.Filter) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, id := range s.candidates(f) {
+		if filters.Matches(f, s.byID[id]) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// candidates returns the IDs of a superset of the events matching the
+// filter, using the most selective index the filter allows. Prefix
+// conditions cannot use the ID and author indexes.
+func (s *Store) candidates(f filters.Filter) []string {
+	var best idSet
+	consider := func(set idSet) {
+		if best == nil || len(set) < len(best) {
+			best = set
+		}
+	}
+
+	if len(f.IDs) > 0 && allFull(f.IDs) {
+		set := make(idSet)
+		for _, id := range f.IDs {
+			if _, ok := s.byID[id]; ok {
+				set[id] = struct{}{}
+			}
+		}
+		consider(set)
+	}
+
+	if len(f.Authors) > 0 && allFull(f.Authors) {
+		consider(union(len(f.Authors), func(i int) idSet {
+			return s.byAuthor[f.Authors[i]]
+		}))
+	}
+
+	if len(f.Kinds) > 0 {
+		consider(union(len(f.Kinds), func(i int) idSet {
+			return s.byKind[f.Kinds[i]]
+		}))
+	}
+
+	for name, values := range f.Tags {
+		if len(name) != 1 || len(values) == 0 {
+			continue
+		}
+		consider(union(len(values), func(i int) idSet {
+			return s.byTag[tagKey{name, values[i]}]
+		}))
+	}
+
+	// Without a usable index, scan the time range of the timeline
+	if best == nil {
+		return s.timeRange(f.Since, f.Until)
+	}
+
+	ids := make([]string, 0, len(best))
+	for id := range best {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// timeRange returns the timeline IDs created within the inclusive bounds.
+func (s *Store) timeRange(since, until *int) []string {
+	start, end := 0, len(s.timeline)
+	if until != nil {
+		start = sort.Search(len(s.timeline), func(i int) bool {
+			return s.byID[s.timeline[i]].CreatedAt <= *until
+		})
+	}
+	if since != nil {
+		end = sort.Search(len(s.timeline), func(i int) bool {
+			return s.byID[s.timeline[i]].CreatedAt < *since
+		})
+	}
+	if start >= end {
+		return nil
+	}
+	return s.timeline[start:end]
+}
+
+func (s *Store) insert(e events.Event) {
+	s.byID[e.ID] = e
+	addTo(s.byAuthor, e.PubKey, e.ID)
+	addTo(s.byKind, e.Kind, e.ID)
+	for _, key := range indexedTags(e) {
+		addTo(s.byTag, key, e.ID)
+	}
+
+	i := sort.Search(len(s.timeline), func(i int) bool {
+		return !store.Supersedes(s.byID[s.timeline[i]], e)
+	})
+	s.timeline = append(s.timeline, "")
+	copy(s.timeline[i+1:], s.timeline[i:])
+	s.timeline[i] = e.ID
+}
+
+func (s *Store) remove(id string) {
+	e := s.byID[id]
+
+	i := sort.Search(len(s.timeline), func(i int) bool {
+		return !store.Supersedes(s.byID[s.timeline[i]], e)
+	})
+	s.timeline = append(s.timeline[:i], s.timeline[i+1:]...)
+
+	removeFrom(s.byAuthor, e.PubKey, id)
+	removeFrom(s.byKind, e.Kind, id)
+	for _, key := range indexedTags(e) {
+		removeFrom(s.byTag, key, id)
+	}
+	if key, ok := store.ReplacementKey(e); ok && s.byReplacement[key] == id {
+		delete(s.byReplacement, key)
+	}
+	delete(s.byID, id)
+}
+
+// indexedTags returns the single-letter tag name and value pairs of an event.
+func indexedTags(e events.Event) []tagKey {
+	keys := []tagKey{}
+	for _, tag := range e.Tags {
+		if len(tag) >= 2 && len(tag[0]) == 1 {
+			keys = append(keys, tagKey{tag[0], tag[1]})
+		}
+	}
+	return keys
+}
+
+func addTo[K comparable](index map[K]idSet, key K, id string) {
+	set, ok := index[key]
+	if !ok {
+		set = make(idSet)
+		index[key] = set
+	}
+	set[id] = struct{}{}
+}
+
+func removeFrom[K comparable](index map[K]idSet, key K, id string) {
+	set := index[key]
+	delete(set, id)
+	if len(set) == 0 {
+		delete(index, key)
+	}
+}
+
+func union(n int, get func(i int) idSet) idSet {
+	if n == 1 {
+		if set := get(0); set != nil {
+			return set
+		}
+		return idSet{}
+	}
+	result := make(idSet)
+	for i := 0; i < n; i++ {
+		for id := range get(i) {
+			result[id] = struct{}{}
+		}
+	}
+	return result
+}
+
+func allFull(values []string) bool {
+	for _, v := range values {
+		if !events.Hex64Pattern.MatchString(v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,384 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"git.wisehodl.dev/jay/go-roots/store"
+	"git.wisehodl.dev/jay/go-roots/store/storetest"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"sync"
+	"testing"
+)
+
+var testEvents []events.Event
+
+func init() {
+	data, err := os.ReadFile("../../filters/testdata/test_events.json")
+	if err != nil {
+		panic(err)
+	}
+	if err := json.Unmarshal(data, &testEvents); err != nil {
+		panic(err)
+	}
+}
+
+func newTestStore(t *testing.T) *Store {
+	s := New()
+	for _, e := range testEvents {
+		if err := s.Save(context.Background(), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func shortIDs(evs []events.Event) []string {
+	ids := []string{}
+	for _, e := range evs {
+		ids = append(ids, e.ID[:8])
+	}
+	return ids
+}
+
+type QueryTestCase struct {
+	name        string
+	filter      filters.Filter
+	expectedIDs []string
+}
+
+var queryTestCases = []QueryTestCase{
+	{
+		name:   "empty filter",
+		filter: filters.Filter{},
+		expectedIDs: []string{
+			"d39e6f3f", "4b03b69a", "4a15d963", "3a122100", "7a5d83d4",
+			"5e4c64f1", "e67fa7b8", "562bc378", "e751d41f",
+		},
+	},
+
+	{
+		name: "full id",
+		filter: filters.Filter{IDs: []string{
+			"e67fa7b84df6b0bb4c57f8719149de77f58955d7849da1be10b2267c72daad8b",
+		}},
+		expectedIDs: []string{"e67fa7b8"},
+	},
+
+	{
+		name:        "id prefixes",
+		filter:      filters.Filter{IDs: []string{"562bc378", "5e4c64f1"}},
+		expectedIDs: []string{"5e4c64f1", "562bc378"},
+	},
+
+	{
+		name: "full author",
+		filter: filters.Filter{Authors: []string{
+			"d877e187934bd942a71221b50ff2b426bd0777991b41b6c749119805dc40bcbe",
+		}},
+		expectedIDs: []string{"e67fa7b8", "562bc378", "e751d41f"},
+	},
+
+	{
+		name:        "author prefix",
+		filter:      filters.Filter{Authors: []string{"9e4b726a"}},
+		expectedIDs: []string{"3a122100", "7a5d83d4", "5e4c64f1"},
+	},
+
+	{
+		name:        "kinds",
+		filter:      filters.Filter{Kinds: []int{0}},
+		expectedIDs: []string{"4a15d963", "5e4c64f1", "e751d41f"},
+	},
+
+	{
+		name:        "time range",
+		filter:      filters.Filter{Since: intPtr(3000), Until: intPtr(5000)},
+		expectedIDs: []string{"7a5d83d4", "5e4c64f1", "e67fa7b8"},
+	},
+
+	{
+		name:        "empty time range",
+		filter:      filters.Filter{Since: intPtr(5000), Until: intPtr(3000)},
+		expectedIDs: []string{},
+	},
+
+	{
+		name: "single-letter tag",
+		filter: filters.Filter{Tags: filters.TagFilters{
+			"e": {"5c83da77af1dec6d7289834998ad7aafbd9e2191396d75ec3cc27f5a77226f36"},
+		}},
+		expectedIDs: []string{"562bc378"},
+	},
+
+	{
+		name:        "multi-letter tag",
+		filter:      filters.Filter{Tags: filters.TagFilters{"power": {"fire"}}},
+		expectedIDs: []string{"4a15d963"},
+	},
+
+	{
+		name:        "limit",
+		filter:      filters.Filter{Kinds: []int{1, 2}, Limit: intPtr(3)},
+		expectedIDs: []string{"d39e6f3f", "4b03b69a", "3a122100"},
+	},
+
+	{
+		name:        "zero limit",
+		filter:      filters.Filter{Limit: intPtr(0)},
+		expectedIDs: []string{},
+	},
+
+	{
+		name: "combined",
+		filter: filters.Filter{
+			Authors: []string{"e719e8f8"},
+			Kinds:   []int{0},
+			Since:   intPtr(5000),
+			Tags:    filters.TagFilters{"power": {"fire"}},
+		},
+		expectedIDs: []string{"4a15d963"},
+	},
+}
+
+func TestQuery(t *testing.T) {
+	s := newTestStore(t)
+	for _, tc := range queryTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := s.Query(context.Background(), tc.filter)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedIDs, shortIDs(results))
+
+			count, err := s.Count(context.Background(), tc.filter)
+			assert.NoError(t, err)
+			if tc.filter.Limit == nil {
+				assert.Equal(t, len(tc.expectedIDs), count)
+			}
+		})
+	}
+}
+
+func TestCountIgnoresLimit(t *testing.T) {
+	s := newTestStore(t)
+	count, err := s.Count(context.Background(), filters.Filter{Limit: intPtr(1)})
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(testEvents), count)
+}
+
+func TestSaveDuplicate(t *testing.T) {
+	s := newTestStore(t)
+	err := s.Save(context.Background(), testEvents[1])
+	assert.ErrorContains(t, err, "event is already stored")
+}
+
+func TestDelete(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	assert.NoError(t, s.Delete(ctx, testEvents[1].ID))
+	results, _ := s.Query(ctx, filters.Filter{Kinds: []int{1}})
+	assert.Equal(t, []string{"4b03b69a", "7a5d83d4"}, shortIDs(results))
+
+	results, _ = s.Query(ctx, filters.Filter{Tags: filters.TagFilters{
+		"e": {"5c83da77af1dec6d7289834998ad7aafbd9e2191396d75ec3cc27f5a77226f36"},
+	}})
+	assert.Empty(t, results)
+
+	err := s.Delete(ctx, testEvents[1].ID)
+	assert.ErrorContains(t, err, "event not found")
+
+	// A deleted event can be saved again
+	assert.NoError(t, s.Save(ctx, testEvents[1]))
+}
+
+type ReplacementTestCase struct {
+	name        string
+	saved       []events.Event
+	expectedIDs []string
+	errors      []string
+}
+
+var replacementTestCases = []ReplacementTestCase{
+	{
+		name: "newer replaceable replaces older",
+		saved: []events.Event{
+			{ID: "a1", PubKey: "pk", Kind: 0, CreatedAt: 100},
+			{ID: "a2", PubKey: "pk", Kind: 0, CreatedAt: 200},
+		},
+		expectedIDs: []string{"a2"},
+		errors:      []string{"", ""},
+	},
+
+	{
+		name: "older replaceable rejected",
+		saved: []events.Event{
+			{ID: "a2", PubKey: "pk", Kind: 10002, CreatedAt: 200},
+			{ID: "a1", PubKey: "pk", Kind: 10002, CreatedAt: 100},
+		},
+		expectedIDs: []string{"a2"},
+		errors:      []string{"", "event is replaced by a newer version"},
+	},
+
+	{
+		name: "same timestamp keeps lowest id",
+		saved: []events.Event{
+			{ID: "b", PubKey: "pk", Kind: 3, CreatedAt: 100},
+			{ID: "a", PubKey: "pk", Kind: 3, CreatedAt: 100},
+			{ID: "c", PubKey: "pk", Kind: 3, CreatedAt: 100},
+		},
+		expectedIDs: []string{"a"},
+		errors:      []string{"", "", "event is replaced by a newer version"},
+	},
+
+	{
+		name: "replaceable per author",
+		saved: []events.Event{
+			{ID: "a", PubKey: "pk1", Kind: 0, CreatedAt: 100},
+			{ID: "b", PubKey: "pk2", Kind: 0, CreatedAt: 200},
+		},
+		expectedIDs: []string{"b", "a"},
+		errors:      []string{"", ""},
+	},
+
+	{
+		name: "addressable per d tag",
+		saved: []events.Event{
+			{ID: "a", PubKey: "pk", Kind: 30023, CreatedAt: 100, Tags: []events.Tag{{"d", "one"}}},
+			{ID: "b", PubKey: "pk", Kind: 30023, CreatedAt: 200, Tags: []events.Tag{{"d", "two"}}},
+			{ID: "c", PubKey: "pk", Kind: 30023, CreatedAt: 300, Tags: []events.Tag{{"d", "one"}}},
+		},
+		expectedIDs: []string{"c", "b"},
+		errors:      []string{"", "", ""},
+	},
+
+	{
+		name: "addressable without d tag",
+		saved: []events.Event{
+			{ID: "a", PubKey: "pk", Kind: 30000, CreatedAt: 100},
+			{ID: "b", PubKey: "pk", Kind: 30000, CreatedAt: 200, Tags: []events.Tag{{"d", ""}}},
+		},
+		expectedIDs: []string{"b"},
+		errors:      []string{"", ""},
+	},
+
+	{
+		name: "regular events kept",
+		saved: []events.Event{
+			{ID: "a", PubKey: "pk", Kind: 1, CreatedAt: 100},
+			{ID: "b", PubKey: "pk", Kind: 1, CreatedAt: 200},
+		},
+		expectedIDs: []string{"b", "a"},
+		errors:      []string{"", ""},
+	},
+
+	{
+		name: "ephemeral events not stored",
+		saved: []events.Event{
+			{ID: "a", PubKey: "pk", Kind: 20001, CreatedAt: 100},
+		},
+		expectedIDs: []string{},
+		errors:      []string{""},
+	},
+}
+
+func TestReplacement(t *testing.T) {
+	for _, tc := range replacementTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := New()
+			ctx := context.Background()
+			for i, e := range tc.saved {
+				err := s.Save(ctx, e)
+				if tc.errors[i] == "" {
+					assert.NoError(t, err)
+				} else {
+					assert.ErrorContains(t, err, tc.errors[i])
+				}
+			}
+
+			results, err := s.Query(ctx, filters.Filter{})
+			assert.NoError(t, err)
+			actualIDs := []string{}
+			for _, e := range results {
+				actualIDs = append(actualIDs, e.ID)
+			}
+			assert.Equal(t, tc.expectedIDs, actualIDs)
+		})
+	}
+}
+
+func TestReplacedEventRemovedFromIndexes(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+	s.Save(ctx, events.Event{ID: "a", PubKey: "pk", Kind: 0, CreatedAt: 100, Tags: []events.Tag{{"t", "old"}}})
+	s.Save(ctx, events.Event{ID: "b", PubKey: "pk", Kind: 0, CreatedAt: 200})
+
+	results, _ := s.Query(ctx, filters.Filter{Tags: filters.TagFilters{"t": {"old"}}})
+	assert.Empty(t, results)
+
+	// Deleting the current version allows an older version again
+	assert.NoError(t, s.Delete(ctx, "b"))
+	assert.NoError(t, s.Save(ctx, events.Event{ID: "a", PubKey: "pk", Kind: 0, CreatedAt: 100}))
+}
+
+func TestStoredEventsAreIsolated(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+	e := events.Event{ID: "a", Kind: 1, Tags: []events.Tag{{"t", "original"}}}
+	s.Save(ctx, e)
+	e.Tags[0][1] = "changed"
+
+	results, _ := s.Query(ctx, filters.Filter{})
+	results[0].Tags[0][1] = "changed again"
+
+	results, _ = s.Query(ctx, filters.Filter{})
+	assert.Equal(t, "original", results[0].Tags[0][1])
+}
+
+func TestCanceledContext(t *testing.T) {
+	s := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, s.Save(ctx, testEvents[0]), context.Canceled)
+	assert.ErrorIs(t, s.Delete(ctx, testEvents[0].ID), context.Canceled)
+	_, err := s.Query(ctx, filters.Filter{})
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = s.Count(ctx, filters.Filter{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+	var wg sync.WaitGroup
+
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				id := fmt.Sprintf("%02d%03d", w, i)
+				s.Save(ctx, events.Event{ID: id, Kind: 1, CreatedAt: i})
+				s.Query(ctx, filters.Filter{Kinds: []int{1}, Limit: intPtr(10)})
+				if i%2 == 0 {
+					s.Delete(ctx, id)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	count, err := s.Count(ctx, filters.Filter{})
+	assert.NoError(t, err)
+	assert.Equal(t, 400, count)
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return New()
+	})
+}
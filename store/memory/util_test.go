@@ -0,0 +1,5 @@
+package memory
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -0,0 +1,96 @@
+// Package store defines the interface shared by event stores and the
+// NIP-01 storage rules they have in common: replacement of replaceable and
+// addressable events, and newest-first result ordering.
+package store
+
+import (
+	"context"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"sort"
+	"strconv"
+)
+
+// Store persists events and answers filter queries.
+//
+// Save stores an event. It returns errors.DuplicateEvent if the event is
+// already stored and errors.ReplacedEvent if a newer version of a replaceable
+// or addressable event is stored; saving a newer version removes the older
+// one. Ephemeral events are accepted but not stored.
+//
+// Delete removes the event with the given ID, returning errors.EventNotFound
+// if it is not stored.
+//
+// Query returns the stored events that match the filter, newest first, with
+// ties ordered by lowest ID, truncated to the filter's Limit. Count returns
+// the number of matching events, ignoring Limit.
+type Store interface {
+	Save(ctx context.Context, e events.Event) error
+	Delete(ctx context.Context, id string) error
+	Query(ctx context.Context, f filters.Filter) ([]events.Event, error)
+	Count(ctx context.Context, f filters.Filter) (int, error)
+}
+
+// ReplacementKey returns the key shared by all versions of a replaceable or
+// addressable event: the kind and pubkey, plus the "d" tag value for
+// addressable events. The boolean result is false for other kinds.
+func ReplacementKey(e events.Event) (string, bool) {
+	switch {
+	case events.IsReplaceable(e.Kind):
+		return strconv.Itoa(e.Kind) + ":" + e.PubKey, true
+	case events.IsAddressable(e.Kind):
+		return strconv.Itoa(e.Kind) + ":" + e.PubKey + ":" + DTag(e), true
+	default:
+		return "", false
+	}
+}
+
+// DTag returns the value of the first "d" tag of the event, or an empty
+// string if it has none.
+func DTag(e events.Event) string {
+	for _, tag := range e.Tags {
+		if len(tag) >= 2 && tag[0] == "d" {
+			return tag[1]
+		}
+	}
+	return ""
+}
+
+// Supersedes reports whether event a replaces event b when both share a
+// replacement key: a is newer, or equally new with a lower ID.
+func Supersedes(a, b events.Event) bool {
+	if a.CreatedAt != b.CreatedAt {
+		return a.CreatedAt > b.CreatedAt
+	}
+	return a.ID < b.ID
+}
+
+// Sort orders events newest first, with ties ordered by lowest ID.
+func Sort(evs []events.Event) {
+	sort.Slice(evs, func(i, j int) bool {
+		return Supersedes(evs[i], evs[j])
+	})
+}
+
+// Limit truncates sorted query results to the filter's Limit, if set.
+// A negative limit is ignored.
+func Limit(evs []events.Event, f filters.Filter) []events.Event {
+	if f.Limit != nil && *f.Limit >= 0 && *f.Limit < len(evs) {
+		return evs[:*f.Limit]
+	}
+	return evs
+}
+
+// Clone returns a deep copy of the event, so stores can keep events
+// isolated from later changes by callers.
+func Clone(e events.Event) events.Event {
+	if e.Tags != nil {
+		tags := make([]events.Tag, len(e.Tags))
+		for i, tag := range e.Tags {
+			tags[i] = make(events.Tag, len(tag))
+			copy(tags[i], tag)
+		}
+		e.Tags = tags
+	}
+	return e
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type ReplacementKeyTestCase struct {
+	name     string
+	event    events.Event
+	expected string
+	ok       bool
+}
+
+var replacementKeyTestCases = []ReplacementKeyTestCase{
+	{
+		name:  "regular",
+		event: events.Event{PubKey: "pk", Kind: 1},
+	},
+
+	{
+		name:  "ephemeral",
+		event: events.Event{PubKey: "pk", Kind: 20000},
+	},
+
+	{
+		name:     "replaceable",
+		event:    events.Event{PubKey: "pk", Kind: 10002, Tags: []events.Tag{{"d", "ignored"}}},
+		expected: "10002:pk",
+		ok:       true,
+	},
+
+	{
+		name:     "addressable",
+		event:    events.Event{PubKey: "pk", Kind: 30023, Tags: []events.Tag{{"d", "slug"}, {"d", "second"}}},
+		expected: "30023:pk:slug",
+		ok:       true,
+	},
+
+	{
+		name:     "addressable without d tag",
+		event:    events.Event{PubKey: "pk", Kind: 30023},
+		expected: "30023:pk:",
+		ok:       true,
+	},
+}
+
+func TestReplacementKey(t *testing.T) {
+	for _, tc := range replacementKeyTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			key, ok := ReplacementKey(tc.event)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, key)
+		})
+	}
+}
+
+func TestSortAndLimit(t *testing.T) {
+	evs := []events.Event{
+		{ID: "b", CreatedAt: 1},
+		{ID: "c", CreatedAt: 2},
+		{ID: "a", CreatedAt: 1},
+	}
+	Sort(evs)
+	assert.Equal(t, "c", evs[0].ID)
+	assert.Equal(t, "a", evs[1].ID)
+	assert.Equal(t, "b", evs[2].ID)
+
+	limit := 2
+	assert.Len(t, Limit(evs, filters.Filter{Limit: &limit}), 2)
+	assert.Len(t, Limit(evs, filters.Filter{}), 3)
+	limit = -1
+	assert.Len(t, Limit(evs, filters.Filter{Limit: &limit}), 3)
+}
+
+func TestClone(t *testing.T) {
+	e := events.Event{ID: "a", Tags: []events.Tag{{"t", "value"}, {}}}
+	c := Clone(e)
+	c.Tags[0][1] = "changed"
+
+	assert.Equal(t, "value", e.Tags[0][1])
+	assert.Equal(t, events.Tag{}, c.Tags[1])
+}
@@ -0,0 +1,521 @@
+// Package storetest provides a conformance test suite for store.Store
+// implementations.
+//
+// Backends call Run from their own tests. The suite checks every Query and
+// Count result against a reference newest-first scan with filters.Matches,
+// after applying NIP-01 replacement and deletions to the saved events.
+package storetest
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"git.wisehodl.dev/jay/go-roots/errors"
+	"git.wisehodl.dev/jay/go-roots/events"
+	"git.wisehodl.dev/jay/go-roots/filters"
+	"git.wisehodl.dev/jay/go-roots/keys"
+	"git.wisehodl.dev/jay/go-roots/store"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// Factory returns a new, empty store for a single test. Stores that need
+// cleanup should register it with t.Cleanup.
+type Factory func(t *testing.T) store.Store
+
+// testEventsJSON is a copy of filters/testdata/test_events.json, so that the
+// suite works outside of this repository.
+//
+//go:embed testdata/test_events.json
+var testEventsJSON []byte
+
+// Keys that sign generated events.
+var signers = []string{
+	"1784be782585dfa97712afe12585d13ee608b624cf564116fa143c31a124d31e",
+	"03d0611c41048a9108a75bf5d023180b5cf2d2d24e2e6b83def29de977315bb3",
+	"7547dd630c04fde72bff3b99c481c683479966cb758f0b367b08fc971ead18f0",
+}
+
+// Run runs the conformance suite against stores created by newStore.
+func Run(t *testing.T, newStore Factory) {
+	t.Run("TestEvents", func(t *testing.T) { testTestEvents(t, newStore) })
+	t.Run("GeneratedFilters", func(t *testing.T) { testGeneratedFilters(t, newStore) })
+	t.Run("Duplicate", func(t *testing.T) { testDuplicate(t, newStore) })
+	t.Run("Replacement", func(t *testing.T) { testReplacement(t, newStore) })
+	t.Run("Deletion", func(t *testing.T) { testDeletion(t, newStore) })
+	t.Run("LimitOrdering", func(t *testing.T) { testLimitOrdering(t, newStore) })
+	t.Run("ConcurrentWriters", func(t *testing.T) { testConcurrentWriters(t, newStore) })
+}
+
+// reference tracks the events a conforming store holds, and answers
+// queries by scanning them. It restates the NIP-01 rules rather than using
+// the helpers in package store, so that it can catch mistakes in them.
+type reference struct {
+	mu     sync.Mutex
+	stored map[string]events.Event
+}
+
+func newReference() *reference {
+	return &reference{stored: make(map[string]events.Event)}
+}
+
+func (r *reference) save(e events.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.stored[e.ID]; ok {
+		return errors.DuplicateEvent
+	}
+	if e.Kind >= 20000 && e.Kind < 30000 {
+		return nil
+	}
+	for id, old := range r.stored {
+		if !sameAddress(e, old) {
+			continue
+		}
+		if old.CreatedAt > e.CreatedAt || (old.CreatedAt == e.CreatedAt && old.ID < e.ID) {
+			return errors.ReplacedEvent
+		}
+		delete(r.stored, id)
+	}
+	r.stored[e.ID] = e
+	return nil
+}
+
+func (r *reference) delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.stored[id]; !ok {
+		return errors.EventNotFound
+	}
+	delete(r.stored, id)
+	return nil
+}
+
+func (r *reference) query(f filters.Filter) []events.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	matched := []events.Event{}
+	for _, e := range r.stored {
+		if filters.Matches(f, e) {
+			matched = append(matched, e)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].CreatedAt != matched[j].CreatedAt {
+			return matched[i].CreatedAt > matched[j].CreatedAt
+		}
+		return matched[i].ID < matched[j].ID
+	})
+	if f.Limit != nil && *f.Limit >= 0 && *f.Limit < len(matched) {
+		matched = matched[:*f.Limit]
+	}
+	return matched
+}
+
+func (r *reference) count(f filters.Filter) int {
+	f.Limit = nil
+	return len(r.query(f))
+}
+
+// checkFilter compares the store's Query and Count results with the reference.
+func checkFilter(t *testing.T, s store.Store, ref *reference, f filters.Filter) bool {
+	t.Helper()
+	ctx := context.Background()
+
+	results, err := s.Query(ctx, f)
+	if err != nil {
+		t.Errorf("query %s: %v", describe(f), err)
+		return false
+	}
+	expected := ids(ref.query(f))
+	if actual := ids(results); !equalIDs(expected, actual) {
+		t.Errorf("query %s:\nexpected %v\nactual   %v", describe(f), expected, actual)
+		return false
+	}
+
+	count, err := s.Count(ctx, f)
+	if err != nil {
+		t.Errorf("count %s: %v", describe(f), err)
+		return false
+	}
+	if expected := ref.count(f); count != expected {
+		t.Errorf("count %s: expected %d, actual %d", describe(f), expected, count)
+		return false
+	}
+	return true
+}
+
+func saveAll(t *testing.T, s store.Store, ref *reference, evs []events.Event) {
+	t.Helper()
+	for _, e := range evs {
+		expected := ref.save(e)
+		actual := s.Save(context.Background(), e)
+		if !sameError(expected, actual) {
+			t.Fatalf("save %s: expected error %v, actual %v", e.ID, expected, actual)
+		}
+	}
+}
+
+func testTestEvents(t *testing.T, newStore Factory) {
+	var testEvents []events.Event
+	if err := json.Unmarshal(testEventsJSON, &testEvents); err != nil {
+		t.Fatal(err)
+	}
+	s := newStore(t)
+	ref := newReference()
+	for _, e := range testEvents {
+		err := s.Save(context.Background(), e)
+
+		// One test event has an ID that does not match its content. Stores
+		// may reject invalid events, in which case the reference skips them.
+		if err != nil && events.Validate(e) != nil {
+			continue
+		}
+		if expected := ref.save(e); !sameError(expected, err) {
+			t.Fatalf("save %s: expected error %v, actual %v", e.ID, expected, err)
+		}
+	}
+
+	testFilters := []filters.Filter{
+		{},
+		{IDs: []string{}},
+		{IDs: []string{"e751d41f"}},
+		{IDs: []string{"e67fa7b84df6b0bb4c57f8719149de77f58955d7849da1be10b2267c72daad8b"}},
+		{IDs: []string{"562bc378", "5e4c64f1"}},
+		{IDs: []string{"ffff"}},
+		{Authors: []string{"d877e187"}},
+		{Authors: []string{"d877e187934bd942a71221b50ff2b426bd0777991b41b6c749119805dc40bcbe"}},
+		{Authors: []string{"D877E187"}},
+		{Kinds: []int{0}},
+		{Kinds: []int{1, 2}},
+		{Since: intPtr(5000)},
+		{Until: intPtr(3000)},
+		{Since: intPtr(3000), Until: intPtr(3000)},
+		{Limit: intPtr(3)},
+		{Limit: intPtr(0)},
+		{Tags: filters.TagFilters{"e": {"5c83da77af1dec6d7289834998ad7aafbd9e2191396d75ec3cc27f5a77226f36"}}},
+		{Tags: filters.TagFilters{"p": {}}},
+		{Tags: filters.TagFilters{"emoji": {"🌊"}}},
+		{Tags: filters.TagFilters{"category": {"music"}}},
+		{Tags: filters.TagFilters{"category": {"art"}}},
+		{Authors: []string{"e719e8f8"}, Kinds: []int{0}, Since: intPtr(5000), Tags: filters.TagFilters{"power": {"fire"}}},
+	}
+	for _, f := range testFilters {
+		checkFilter(t, s, ref, f)
+	}
+}
+
+func testGeneratedFilters(t *testing.T, newStore Factory) {
+	r := rand.New(rand.NewSource(1))
+	corpus := generateCorpus(t, r, 300)
+	s := newStore(t)
+	ref := newReference()
+	saveAll(t, s, ref, corpus)
+
+	for i := 0; i < 500; i++ {
+		if !checkFilter(t, s, ref, generateFilter(r, corpus)) {
+			return
+		}
+	}
+}
+
+func testDuplicate(t *testing.T, newStore Factory) {
+	s := newStore(t)
+	e := signedEvent(t, signers[0], 1, 1000, nil, "duplicate")
+	ctx := context.Background()
+
+	if err := s.Save(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(ctx, e); !stderrors.Is(err, errors.DuplicateEvent) {
+		t.Errorf("expected %v, actual %v", errors.DuplicateEvent, err)
+	}
+}
+
+func testReplacement(t *testing.T, newStore Factory) {
+	s := newStore(t)
+	ref := newReference()
+	d := func(value string) []events.Tag { return []events.Tag{{"d", value}} }
+
+	saveAll(t, s, ref, []events.Event{
+		// Replaceable: newer replaces older, older is rejected afterwards
+		signedEvent(t, signers[0], 0, 1000, nil, "profile v1"),
+		signedEvent(t, signers[0], 0, 2000, nil, "profile v2"),
+		signedEvent(t, signers[0], 0, 1500, nil, "profile stale"),
+		signedEvent(t, signers[1], 0, 1000, nil, "other author"),
+		signedEvent(t, signers[0], 10002, 1000, nil, "relay list"),
+
+		// Replaceable with equal timestamps keeps the lowest ID
+		signedEvent(t, signers[0], 3, 1000, nil, "contacts a"),
+		signedEvent(t, signers[0], 3, 1000, nil, "contacts b"),
+		signedEvent(t, signers[0], 3, 1000, nil, "contacts c"),
+
+		// Addressable: replaced per d tag
+		signedEvent(t, signers[0], 30023, 1000, d("one"), "article one v1"),
+		signedEvent(t, signers[0], 30023, 1000, d("two"), "article two"),
+		signedEvent(t, signers[0], 30023, 2000, d("one"), "article one v2"),
+		signedEvent(t, signers[0], 30023, 500, d("one"), "article one stale"),
+		signedEvent(t, signers[0], 30023, 1000, nil, "article without d"),
+		signedEvent(t, signers[0], 30023, 2000, d(""), "article with empty d"),
+
+		// Ephemeral events are not stored
+		signedEvent(t, signers[0], 20001, 1000, nil, "ephemeral"),
+	})
+
+	checkFilter(t, s, ref, filters.Filter{})
+	checkFilter(t, s, ref, filters.Filter{Kinds: []int{0}})
+	checkFilter(t, s, ref, filters.Filter{Tags: filters.TagFilters{"d": {"one"}}})
+}
+
+func testDeletion(t *testing.T, newStore Factory) {
+	r := rand.New(rand.NewSource(2))
+	corpus := generateCorpus(t, r, 100)
+	s := newStore(t)
+	ref := newReference()
+	saveAll(t, s, ref, corpus)
+	ctx := context.Background()
+
+	for _, e := range corpus[:50] {
+		expected := ref.delete(e.ID)
+		if actual := s.Delete(ctx, e.ID); !sameError(expected, actual) {
+			t.Fatalf("delete %s: expected error %v, actual %v", e.ID, expected, actual)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		if !checkFilter(t, s, ref, generateFilter(r, corpus)) {
+			return
+		}
+	}
+
+	// Deleted events can be saved again
+	saveAll(t, s, ref, corpus[:10])
+	checkFilter(t, s, ref, filters.Filter{})
+}
+
+func testLimitOrdering(t *testing.T, newStore Factory) {
+	s := newStore(t)
+	ref := newReference()
+
+	// Many events share timestamps, so ties must be ordered by ID
+	evs := []events.Event{}
+	for i := 0; i < 30; i++ {
+		evs = append(evs, signedEvent(t, signers[i%3], 1, 1000+i/5, nil, fmt.Sprintf("tie %d", i)))
+	}
+	saveAll(t, s, ref, evs)
+
+	for limit := 0; limit <= 31; limit++ {
+		checkFilter(t, s, ref, filters.Filter{Limit: intPtr(limit)})
+		checkFilter(t, s, ref, filters.Filter{Since: intPtr(1002), Limit: intPtr(limit)})
+	}
+}
+
+func testConcurrentWriters(t *testing.T, newStore Factory) {
+	s := newStore(t)
+	ref := newReference()
+	ctx := context.Background()
+	const writers = 8
+
+	// Each writer saves its own notes and competes on one replaceable event
+	batches := make([][]events.Event, writers)
+	for w := range batches {
+		for i := 0; i < 20; i++ {
+			content := fmt.Sprintf("writer %d note %d", w, i)
+			batches[w] = append(batches[w], signedEvent(t, signers[w%3], 1, 1000+i, nil, content))
+		}
+		profile := signedEvent(t, signers[0], 0, 1000+w, nil, fmt.Sprintf("profile %d", w))
+		batches[w] = append(batches[w], profile)
+	}
+
+	var wg sync.WaitGroup
+	for _, batch := range batches {
+		wg.Add(1)
+		go func(batch []events.Event) {
+			defer wg.Done()
+			for _, e := range batch {
+				err := s.Save(ctx, e)
+				if err != nil && !stderrors.Is(err, errors.ReplacedEvent) {
+					t.Errorf("save %s: %v", e.ID, err)
+				}
+				s.Query(ctx, filters.Filter{Kinds: []int{1}, Limit: intPtr(5)})
+			}
+		}(batch)
+	}
+	wg.Wait()
+
+	// The final state does not depend on the order of saves
+	for _, batch := range batches {
+		for _, e := range batch {
+			ref.save(e)
+		}
+	}
+	checkFilter(t, s, ref, filters.Filter{})
+	checkFilter(t, s, ref, filters.Filter{Kinds: []int{0}})
+}
+
+// generateCorpus builds signed events with shared authors, colliding
+// timestamps, replaceable and addressable kinds, and a small tag vocabulary,
+// so that generated filters select interesting subsets.
+func generateCorpus(t *testing.T, r *rand.Rand, n int) []events.Event {
+	kinds := []int{0, 1, 1, 1, 3, 7, 10002, 30023}
+	tagNames := []string{"e", "p", "t", "d", "emoji", "subject"}
+	tagValues := []string{"nostr", "Nostr", "100%", "a_b", "🌊", "", "5c83da77af1dec6d7289834998ad7aafbd9e2191396d75ec3cc27f5a77226f36"}
+
+	corpus := make([]events.Event, n)
+	for i := range corpus {
+		tags := []events.Tag{}
+		for j := r.Intn(4); j > 0; j-- {
+			tag := events.Tag{tagNames[r.Intn(len(tagNames))], tagValues[r.Intn(len(tagValues))]}
+			if r.Intn(4) == 0 {
+				tag = append(tag, tagValues[r.Intn(len(tagValues))])
+			}
+			tags = append(tags, tag)
+		}
+		signer := signers[r.Intn(len(signers))]
+		kind := kinds[r.Intn(len(kinds))]
+		corpus[i] = signedEvent(t, signer, kind, 1000+r.Intn(30)*100, tags, fmt.Sprintf("event %d", i))
+	}
+	return corpus
+}
+
+func generateFilter(r *rand.Rand, corpus []events.Event) filters.Filter {
+	f := filters.Filter{}
+	pick := func() events.Event { return corpus[r.Intn(len(corpus))] }
+	prefix := func(s string) string { return s[:r.Intn(len(s)+1)] }
+
+	if r.Intn(4) == 0 {
+		f.IDs = []string{}
+		for j := r.Intn(3); j > 0; j-- {
+			if r.Intn(2) == 0 {
+				f.IDs = append(f.IDs, pick().ID)
+			} else {
+				f.IDs = append(f.IDs, prefix(pick().ID))
+			}
+		}
+	}
+	if r.Intn(3) == 0 {
+		if r.Intn(2) == 0 {
+			f.Authors = []string{pick().PubKey}
+		} else {
+			f.Authors = []string{prefix(pick().PubKey)}
+		}
+	}
+	if r.Intn(3) == 0 {
+		f.Kinds = []int{pick().Kind, pick().Kind}
+	}
+	if r.Intn(3) == 0 {
+		f.Since = intPtr(1000 + r.Intn(30)*100)
+	}
+	if r.Intn(3) == 0 {
+		f.Until = intPtr(1000 + r.Intn(30)*100)
+	}
+	if r.Intn(3) == 0 {
+		f.Limit = intPtr(r.Intn(10))
+	}
+	if r.Intn(2) == 0 {
+		f.Tags = filters.TagFilters{}
+		for j := r.Intn(3); j > 0; j-- {
+			e := pick()
+			if len(e.Tags) == 0 {
+				f.Tags["t"] = append(f.Tags["t"], "nostr")
+				continue
+			}
+			tag := e.Tags[r.Intn(len(e.Tags))]
+			f.Tags[tag[0]] = append(f.Tags[tag[0]], tag[1])
+		}
+	}
+	return f
+}
+
+func signedEvent(t *testing.T, sk string, kind, createdAt int, tags []events.Tag, content string) events.Event {
+	t.Helper()
+	pk, err := keys.GetPublicKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags == nil {
+		tags = []events.Tag{}
+	}
+	e := events.Event{
+		PubKey:    pk,
+		CreatedAt: createdAt,
+		Kind:      kind,
+		Tags:      tags,
+		Content:   content,
+	}
+	if e.ID, err = events.GetID(e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Sig, err = events.SignEvent(e.ID, sk); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// sameAddress reports whether two events are versions of the same
+// replaceable or addressable event.
+func sameAddress(a, b events.Event) bool {
+	if a.PubKey != b.PubKey || a.Kind != b.Kind {
+		return false
+	}
+	replaceable := a.Kind == 0 || a.Kind == 3 || (a.Kind >= 10000 && a.Kind < 20000)
+	addressable := a.Kind >= 30000 && a.Kind < 40000
+	if replaceable {
+		return true
+	}
+	return addressable && firstD(a) == firstD(b)
+}
+
+func firstD(e events.Event) string {
+	for _, tag := range e.Tags {
+		if len(tag) >= 2 && tag[0] == "d" {
+			return tag[1]
+		}
+	}
+	return ""
+}
+
+func describe(f filters.Filter) string {
+	data, err := filters.MarshalJSON(f)
+	if err != nil {
+		return fmt.Sprintf("%+v", f)
+	}
+	return string(data)
+}
+
+func ids(evs []events.Event) []string {
+	result := make([]string, len(evs))
+	for i, e := range evs {
+		result[i] = e.ID
+	}
+	return result
+}
+
+func equalIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameError(expected, actual error) bool {
+	if expected == nil || actual == nil {
+		return expected == actual
+	}
+	return stderrors.Is(actual, expected)
+}
+
+func intPtr(i int) *int {
+	return &i
+}
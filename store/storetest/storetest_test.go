@@ -0,0 +1,15 @@
+package storetest
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+// TestEmbeddedTestEvents guards the embedded copy of the filter test events
+// against drifting from the original.
+func TestEmbeddedTestEvents(t *testing.T) {
+	original, err := os.ReadFile("../../filters/testdata/test_events.json")
+	assert.NoError(t, err)
+	assert.Equal(t, string(original), string(testEventsJSON))
+}